@@ -4,33 +4,48 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 
+	"github.com/anilbolat/simple-bank/apierror"
 	db "github.com/anilbolat/simple-bank/db/sqlc"
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 type createAccountRequest struct {
-	Owner    string `json:"owner" binding:"required"`
-	Currency string `json:"currency" binding:"required,oneof=USD EUR"`
+	Currency string `json:"currency" binding:"required,currency"`
 }
 
 func (server *Server) createAccount(ctx *gin.Context) {
 	var req createAccountRequest
 	err := ctx.ShouldBindJSON(&req) // request is in ctx (gin).
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err)) // writes the status and the data into response
+		respondInvalid(ctx, err) // writes the status and the problem into response
 		return
 	}
 
-	account, err := server.store.CreateAccount(ctx, db.CreateAccountParams{
-		Owner:    req.Owner,
+	authPayload := getAuthPayload(ctx)
+	account, err := server.store.CreateAccountTx(ctx, db.CreateAccountParams{
+		Owner:    authPayload.Username,
 		Balance:  0,
 		Currency: req.Currency,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code.Name() {
+			case "foreign_key_violation":
+				detail := fmt.Sprintf("owner %s does not exist", authPayload.Username)
+				respondError(ctx, apierror.New(http.StatusForbidden, apierror.CodeOwnerNotFound, detail))
+				return
+			case "unique_violation":
+				detail := fmt.Sprintf("owner %s already has an open %s account", authPayload.Username, req.Currency)
+				respondError(ctx, apierror.New(http.StatusForbidden, apierror.CodeAccountAlreadyExists, detail))
+				return
+			}
+		}
+
+		respondError(ctx, err)
 		return
 	}
 
@@ -45,22 +60,26 @@ func (server *Server) getAccount(ctx *gin.Context) {
 	var req getAccountRequest
 	err := ctx.ShouldBindUri(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		respondInvalid(ctx, err)
 		return
 	}
 
 	account, err := server.store.GetAccount(ctx, req.ID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			errNotFound := fmt.Errorf("account ID %d does not exist", req.ID)
-			log.Printf("%v", errNotFound.Error())
-			ctx.JSON(http.StatusNotFound, errorResponse(errNotFound))
+			detail := fmt.Sprintf("account ID %d does not exist", req.ID)
+			respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeAccountNotFound, detail))
 			return
 		}
 
 		errServer := fmt.Errorf("error occurred for account ID %d: %w", req.ID, err)
-		log.Printf("%v", errServer.Error())
-		ctx.JSON(http.StatusInternalServerError, errorResponse(errServer))
+		respondError(ctx, errServer)
+		return
+	}
+
+	authPayload := getAuthPayload(ctx)
+	if account.Owner != authPayload.Username {
+		respondError(ctx, errUnauthorizedAccount)
 		return
 	}
 
@@ -68,28 +87,94 @@ func (server *Server) getAccount(ctx *gin.Context) {
 }
 
 type ListAccountRequest struct {
-	PageID   int32 `form:"page_id" binding:"required,min=1"`
-	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+	pageRequest
+}
+
+type listAccountResponse struct {
+	Accounts   []db.Account `json:"accounts"`
+	NextCursor string       `json:"next_cursor,omitempty"`
 }
 
+// listAccount lists the accounts of the user. Paged by offset, it responds with just the accounts as before.
 func (server *Server) listAccount(ctx *gin.Context) {
 	var req ListAccountRequest
 	err := ctx.ShouldBindQuery(&req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		respondInvalid(ctx, err)
+		return
+	}
+
+	authPayload := getAuthPayload(ctx)
+	if req.byOffset() {
+		accounts, err := server.store.ListAccounts(ctx, db.ListAccountsParams{
+			Owner:  authPayload.Username,
+			Limit:  req.PageSize,
+			Offset: req.offset(),
+		})
+		if err != nil {
+			errServer := fmt.Errorf("error occurred while listing accounts: %w", err)
+			respondError(ctx, errServer)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, accounts)
 		return
 	}
 
-	accounts, err := server.store.ListAccounts(ctx, db.ListAccountsParams{
-		Limit:  req.PageSize,
-		Offset: (req.PageID - 1) * req.PageSize,
+	scope := "accounts:" + authPayload.Username
+	after, err := server.after(req.pageRequest, scope)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	accounts, err := server.store.ListAccountsAfter(ctx, db.ListAccountsAfterParams{
+		Owner:          authPayload.Username,
+		AfterCreatedAt: after.CreatedAt,
+		AfterID:        after.ID,
+		Limit:          req.keysetLimit(),
 	})
 	if err != nil {
 		errServer := fmt.Errorf("error occurred while listing accounts: %w", err)
-		log.Printf("%v", errServer.Error())
-		ctx.JSON(http.StatusInternalServerError, errorResponse(errServer))
+		respondError(ctx, errServer)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, accounts)
+	rsp := listAccountResponse{Accounts: accounts}
+	if len(accounts) > int(req.PageSize) {
+		rsp.Accounts = accounts[:req.PageSize]
+		last := rsp.Accounts[req.PageSize-1]
+		rsp.NextCursor, err = server.nextCursor(scope, last.CreatedAt, last.ID)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// getOwnedAccount gets the account and checks it belongs to the authenticated user.
+// If not, it writes the error response and returns false.
+func (server *Server) getOwnedAccount(ctx *gin.Context, accountID int64) (db.Account, bool) {
+	account, err := server.store.GetAccount(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			detail := fmt.Sprintf("account ID %d does not exist", accountID)
+			respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeAccountNotFound, detail))
+			return account, false
+		}
+
+		errServer := fmt.Errorf("error occurred for account ID %d: %w", accountID, err)
+		respondError(ctx, errServer)
+		return account, false
+	}
+
+	authPayload := getAuthPayload(ctx)
+	if account.Owner != authPayload.Username {
+		respondError(ctx, errUnauthorizedAccount)
+		return account, false
+	}
+
+	return account, true
 }
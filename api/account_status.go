@@ -0,0 +1,105 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/gin-gonic/gin"
+)
+
+type accountStatusURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type changeAccountStatusRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// freezeAccount stops all transfers from and to an account until it is unfrozen.
+func (server *Server) freezeAccount(ctx *gin.Context) {
+	server.changeAccountStatus(ctx, db.AccountStatusFrozen)
+}
+
+// unfreezeAccount makes a frozen account active again.
+func (server *Server) unfreezeAccount(ctx *gin.Context) {
+	server.changeAccountStatus(ctx, db.AccountStatusActive)
+}
+
+// closeAccount closes an account for good. Only an account with a zero balance can be closed.
+func (server *Server) closeAccount(ctx *gin.Context) {
+	server.changeAccountStatus(ctx, db.AccountStatusClosed)
+}
+
+// changeAccountStatus moves the account to the status, recording the reason and the banker making the change.
+func (server *Server) changeAccountStatus(ctx *gin.Context, status string) {
+	var uri accountStatusURI
+	err := ctx.ShouldBindUri(&uri)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	var req changeAccountStatusRequest
+	err = ctx.ShouldBindJSON(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	authPayload := getAuthPayload(ctx)
+	result, err := server.store.ChangeAccountStatusTx(ctx, db.ChangeAccountStatusTxParams{
+		AccountID: uri.ID,
+		Status:    status,
+		Reason:    req.Reason,
+		ChangedBy: authPayload.Username,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			detail := fmt.Sprintf("account ID %d does not exist", uri.ID)
+			respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeAccountNotFound, detail))
+			return
+		}
+
+		errServer := fmt.Errorf("error occurred while changing the status of account ID %d to %s: %w", uri.ID, status, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// listAccountStatusChanges lists the status changes of an account, oldest first.
+func (server *Server) listAccountStatusChanges(ctx *gin.Context) {
+	var uri accountStatusURI
+	err := ctx.ShouldBindUri(&uri)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	_, err = server.store.GetAccount(ctx, uri.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			detail := fmt.Sprintf("account ID %d does not exist", uri.ID)
+			respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeAccountNotFound, detail))
+			return
+		}
+
+		errServer := fmt.Errorf("error occurred for account ID %d: %w", uri.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	changes, err := server.store.ListAccountStatusChanges(ctx, uri.ID)
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while listing status changes of account ID %d: %w", uri.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, changes)
+}
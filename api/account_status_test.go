@@ -0,0 +1,355 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	mockdb "github.com/anilbolat/simple-bank/db/mock"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/token"
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeAccountStatusAPI(t *testing.T) {
+	// given
+	user, _ := randomUser(t)
+	banker, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	reason := "suspicious activity"
+
+	bankerAuth := func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+		addAuthorization(t, request, tokenMaker, authorizationTypeBearer, banker.Username, util.BankerRole, time.Minute)
+	}
+
+	testCases := []struct {
+		name            string
+		action          string
+		accountID       int64
+		body            gin.H
+		setupAuthFn     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		stubFn          func(store *mockdb.MockStore)
+		checkResponseFn func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:        "Freeze",
+			action:      "freeze",
+			accountID:   account.ID,
+			body:        gin.H{"reason": reason},
+			setupAuthFn: bankerAuth,
+			stubFn: func(store *mockdb.MockStore) {
+				arg := db.ChangeAccountStatusTxParams{
+					AccountID: account.ID,
+					Status:    db.AccountStatusFrozen,
+					Reason:    reason,
+					ChangedBy: banker.Username,
+				}
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(changeAccountStatusResult(account, db.AccountStatusFrozen, reason, banker.Username), nil)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res db.ChangeAccountStatusTxResult
+				err := json.NewDecoder(recorder.Body).Decode(&res)
+				require.NoError(t, err)
+				require.Equal(t, db.AccountStatusFrozen, res.Account.Status)
+				require.Equal(t, db.AccountStatusActive, res.Change.FromStatus)
+				require.Equal(t, db.AccountStatusFrozen, res.Change.ToStatus)
+				require.Equal(t, reason, res.Change.Reason)
+				require.Equal(t, banker.Username, res.Change.ChangedBy)
+			},
+		},
+		{
+			name:        "Unfreeze",
+			action:      "unfreeze",
+			accountID:   account.ID,
+			body:        gin.H{"reason": reason},
+			setupAuthFn: bankerAuth,
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ChangeAccountStatusTxParams) (db.ChangeAccountStatusTxResult, error) {
+						require.Equal(t, db.AccountStatusActive, arg.Status)
+						return changeAccountStatusResult(account, db.AccountStatusActive, reason, banker.Username), nil
+					})
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:        "Close",
+			action:      "close",
+			accountID:   account.ID,
+			body:        gin.H{"reason": reason},
+			setupAuthFn: bankerAuth,
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ChangeAccountStatusTxParams) (db.ChangeAccountStatusTxResult, error) {
+						require.Equal(t, db.AccountStatusClosed, arg.Status)
+						return changeAccountStatusResult(account, db.AccountStatusClosed, reason, banker.Username), nil
+					})
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:        "CloseWithBalance",
+			action:      "close",
+			accountID:   account.ID,
+			body:        gin.H{"reason": reason},
+			setupAuthFn: bankerAuth,
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{}, db.ErrAccountBalanceNotZero)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeAccountBalanceNotZero)
+			},
+		},
+		{
+			name:        "InvalidTransition",
+			action:      "unfreeze",
+			accountID:   account.ID,
+			body:        gin.H{"reason": reason},
+			setupAuthFn: bankerAuth,
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{}, db.ErrInvalidStatusTransition)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeInvalidStatusTransition)
+			},
+		},
+		{
+			name:        "NotFound",
+			action:      "freeze",
+			accountID:   account.ID,
+			body:        gin.H{"reason": reason},
+			setupAuthFn: bankerAuth,
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{}, sql.ErrNoRows)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeAccountNotFound)
+			},
+		},
+		{
+			name:        "NoReason",
+			action:      "freeze",
+			accountID:   account.ID,
+			body:        gin.H{},
+			setupAuthFn: bankerAuth,
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeValidationFailed)
+			},
+		},
+		{
+			name:        "InvalidID",
+			action:      "freeze",
+			accountID:   0,
+			body:        gin.H{"reason": reason},
+			setupAuthFn: bankerAuth,
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeValidationFailed)
+			},
+		},
+		{
+			name:      "NotBanker",
+			action:    "freeze",
+			accountID: account.ID,
+			body:      gin.H{"reason": reason},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:        "InternalError",
+			action:      "freeze",
+			accountID:   account.ID,
+			body:        gin.H{"reason": reason},
+			setupAuthFn: bankerAuth,
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ChangeAccountStatusTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ChangeAccountStatusTxResult{}, sql.ErrConnDone)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeInternal)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			// stub
+			tc.stubFn(store)
+
+			// test
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/admin/accounts/%d/%s", tc.accountID, tc.action)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+			tc.setupAuthFn(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+
+			// assert
+			tc.checkResponseFn(t, recorder)
+		})
+	}
+}
+
+func TestListAccountStatusChangesAPI(t *testing.T) {
+	// given
+	user, _ := randomUser(t)
+	banker, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	changes := []db.AccountStatusChange{
+		changeAccountStatusResult(account, db.AccountStatusFrozen, "suspicious activity", banker.Username).Change,
+	}
+
+	testCases := []struct {
+		name            string
+		stubFn          func(store *mockdb.MockStore)
+		checkResponseFn func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListAccountStatusChanges(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(changes, nil)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res []db.AccountStatusChange
+				err := json.NewDecoder(recorder.Body).Decode(&res)
+				require.NoError(t, err)
+				require.Len(t, res, 1)
+				require.Equal(t, changes[0].ToStatus, res[0].ToStatus)
+				require.Equal(t, changes[0].Reason, res[0].Reason)
+			},
+		},
+		{
+			name: "NotFound",
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().ListAccountStatusChanges(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeAccountNotFound)
+			},
+		},
+		{
+			name: "InternalError",
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListAccountStatusChanges(gomock.Any(), gomock.Any()).Times(1).Return(nil, sql.ErrConnDone)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeInternal)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			// stub
+			tc.stubFn(store)
+
+			// test
+			url := fmt.Sprintf("/admin/accounts/%d/status_changes", account.ID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, banker.Username, util.BankerRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+
+			// assert
+			tc.checkResponseFn(t, recorder)
+		})
+	}
+}
+
+func changeAccountStatusResult(account db.Account, status, reason, changedBy string) db.ChangeAccountStatusTxResult {
+	changed := account
+	changed.Status = status
+
+	return db.ChangeAccountStatusTxResult{
+		Account: changed,
+		Change: db.AccountStatusChange{
+			ID:         util.RandomInt(1, 1000),
+			AccountID:  account.ID,
+			FromStatus: account.Status,
+			ToStatus:   status,
+			Reason:     reason,
+			ChangedBy:  changedBy,
+			CreatedAt:  time.Now(),
+		},
+	}
+}
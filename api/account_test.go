@@ -7,27 +7,40 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/anilbolat/simple-bank/apierror"
+	"github.com/anilbolat/simple-bank/cursor"
 	mockdb "github.com/anilbolat/simple-bank/db/mock"
 	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/token"
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetAccountAPI(t *testing.T) {
 	// given
-	account := randomAccount()
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
 
 	testCases := []struct {
 		name            string
 		accountID       int64
+		setupAuthFn     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
 		stubFn          func(store *mockdb.MockStore)
 		checkResponseFn func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
 			name:      "OK",
 			accountID: account.ID,
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
 			stubFn: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
@@ -39,9 +52,43 @@ func TestGetAccountAPI(t *testing.T) {
 				assertAccountInResponse(t, recorder.Body, account)
 			},
 		},
+		{
+			name:      "UnauthorizedUser",
+			accountID: account.ID,
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeAccountNotOwned)
+			},
+		},
+		{
+			name:      "NoAuthorization",
+			accountID: account.ID,
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name:      "NotFound",
 			accountID: account.ID,
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
 			stubFn: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
@@ -50,12 +97,15 @@ func TestGetAccountAPI(t *testing.T) {
 			},
 			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusNotFound, recorder.Code)
-				assertErrorInResponse(t, recorder.Body, "does not exist")
+				assertErrorInResponse(t, recorder, apierror.CodeAccountNotFound)
 			},
 		},
 		{
 			name:      "InternalError",
 			accountID: account.ID,
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
 			stubFn: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
@@ -64,12 +114,15 @@ func TestGetAccountAPI(t *testing.T) {
 			},
 			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
-				assertErrorInResponse(t, recorder.Body, "error occurred for account ID")
+				assertErrorInResponse(t, recorder, apierror.CodeInternal)
 			},
 		},
 		{
 			name:      "InvalidID",
 			accountID: 0,
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
 			stubFn: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Any()).
@@ -77,7 +130,7 @@ func TestGetAccountAPI(t *testing.T) {
 			},
 			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
-				assertErrorInResponse(t, recorder.Body, "Field validation for 'ID' failed")
+				assertErrorInResponse(t, recorder, apierror.CodeValidationFailed)
 			},
 		},
 	}
@@ -88,7 +141,7 @@ func TestGetAccountAPI(t *testing.T) {
 			defer ctrl.Finish()
 			store := mockdb.NewMockStore(ctrl)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			// stub
@@ -97,6 +150,126 @@ func TestGetAccountAPI(t *testing.T) {
 			// test
 			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/accounts/%d", tc.accountID), nil)
 			require.NoError(t, err)
+			tc.setupAuthFn(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+
+			// assert
+			tc.checkResponseFn(t, recorder)
+		})
+	}
+}
+
+func TestCreateAccountAPI(t *testing.T) {
+	// given
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	account.Balance = 0
+
+	testCases := []struct {
+		name            string
+		body            gin.H
+		setupAuthFn     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		stubFn          func(store *mockdb.MockStore)
+		checkResponseFn func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"currency": account.Currency,
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				arg := db.CreateAccountParams{
+					Owner:    user.Username,
+					Balance:  0,
+					Currency: account.Currency,
+				}
+				store.EXPECT().
+					CreateAccountTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				assertAccountInResponse(t, recorder.Body, account)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			body: gin.H{
+				"currency": account.Currency,
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccountTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "DuplicateCurrency",
+			body: gin.H{
+				"currency": account.Currency,
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccountTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Account{}, &pq.Error{Code: "23505"})
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeAccountAlreadyExists)
+			},
+		},
+		{
+			name: "InvalidCurrency",
+			body: gin.H{
+				"currency": "XYZ",
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccountTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeValidationFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			// stub
+			tc.stubFn(store)
+
+			// test
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/accounts", bytes.NewReader(data))
+			require.NoError(t, err)
+			tc.setupAuthFn(t, request, server.tokenMaker)
 			server.router.ServeHTTP(recorder, request)
 
 			// assert
@@ -113,11 +286,251 @@ func assertAccountInResponse(t *testing.T, resBody *bytes.Buffer, expectedAccoun
 	require.Equal(t, expectedAccount, actualAccount)
 }
 
-func assertErrorInResponse(t *testing.T, resBody *bytes.Buffer, expectedError string) {
-	var actualError struct {
-		Error string `json:"error"`
-	}
-	err := json.NewDecoder(resBody).Decode(&actualError)
+// assertErrorInResponse checks that the response is problem details with the expected code.
+func assertErrorInResponse(t *testing.T, recorder *httptest.ResponseRecorder, expectedCode apierror.Code) {
+	require.Equal(t, apierror.ContentType, recorder.Header().Get("Content-Type"))
+
+	var problem apierror.Problem
+	err := json.NewDecoder(recorder.Body).Decode(&problem)
 	require.NoError(t, err)
-	require.Contains(t, actualError.Error, expectedError)
+	require.Equal(t, expectedCode, problem.Code)
+	require.Equal(t, recorder.Code, problem.Status)
+	require.NotEmpty(t, problem.RequestID)
+	if expectedCode == apierror.CodeInternal {
+		// internal errors are only logged
+		require.Equal(t, "internal server error", problem.Detail)
+	}
+}
+
+func TestListAccountAPI(t *testing.T) {
+	// given
+	user, _ := randomUser(t)
+	scope := "accounts:" + user.Username
+	createdAt := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	n := 6
+	accounts := make([]db.Account, n)
+	for i := range accounts {
+		accounts[i] = randomAccount(user.Username)
+		accounts[i].CreatedAt = createdAt.Add(time.Duration(i) * time.Minute)
+	}
+
+	testCases := []struct {
+		name            string
+		queryFn         func(t *testing.T, cursors *cursor.Signer) url.Values
+		setupAuthFn     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		stubFn          func(store *mockdb.MockStore)
+		checkResponseFn func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer)
+	}{
+		{
+			name: "ByOffset",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_id": {"2"}, "page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Eq(db.ListAccountsParams{Owner: user.Username, Limit: 5, Offset: 5})).
+					Times(1).
+					Return(accounts[5:], nil)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var gotAccounts []db.Account
+				err := json.NewDecoder(recorder.Body).Decode(&gotAccounts)
+				require.NoError(t, err)
+				require.Equal(t, accounts[5:], gotAccounts)
+			},
+		},
+		{
+			name: "FirstPage",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccountsAfter(gomock.Any(), gomock.Eq(db.ListAccountsAfterParams{Owner: user.Username, Limit: 6})).
+					Times(1).
+					Return(accounts, nil)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp listAccountResponse
+				err := json.NewDecoder(recorder.Body).Decode(&rsp)
+				require.NoError(t, err)
+				require.Equal(t, accounts[:5], rsp.Accounts)
+
+				next, err := cursors.Decode(rsp.NextCursor, scope)
+				require.NoError(t, err)
+				require.Equal(t, accounts[4].ID, next.ID)
+				require.True(t, accounts[4].CreatedAt.Equal(next.CreatedAt))
+			},
+		},
+		{
+			name: "LastPage",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				token, err := cursors.Encode(cursor.Cursor{Scope: scope, CreatedAt: accounts[4].CreatedAt, ID: accounts[4].ID})
+				require.NoError(t, err)
+				return url.Values{"page_size": {"5"}, "cursor": {token}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccountsAfter(gomock.Any(), gomock.Eq(db.ListAccountsAfterParams{
+						Owner:          user.Username,
+						AfterCreatedAt: accounts[4].CreatedAt,
+						AfterID:        accounts[4].ID,
+						Limit:          6,
+					})).
+					Times(1).
+					Return(accounts[5:], nil)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp map[string]json.RawMessage
+				err := json.NewDecoder(recorder.Body).Decode(&rsp)
+				require.NoError(t, err)
+				require.Contains(t, rsp, "accounts")
+				require.NotContains(t, rsp, "next_cursor")
+			},
+		},
+		{
+			name: "InvalidCursor",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"5"}, "cursor": {"invalid"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccountsAfter(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeInvalidCursor)
+			},
+		},
+		{
+			name: "CursorOfOtherUser",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				token, err := cursors.Encode(cursor.Cursor{Scope: "accounts:other_user", CreatedAt: createdAt, ID: 1})
+				require.NoError(t, err)
+				return url.Values{"page_size": {"5"}, "cursor": {token}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccountsAfter(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeInvalidCursor)
+			},
+		},
+		{
+			name: "CursorWithPageID",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				token, err := cursors.Encode(cursor.Cursor{Scope: scope, CreatedAt: createdAt, ID: 1})
+				require.NoError(t, err)
+				return url.Values{"page_id": {"1"}, "page_size": {"5"}, "cursor": {token}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListAccountsAfter(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeValidationFailed)
+			},
+		},
+		{
+			name: "InvalidPageSize",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"50"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListAccountsAfter(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeValidationFailed)
+			},
+		},
+		{
+			name: "InternalError",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccountsAfter(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, sql.ErrConnDone)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeInternal)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListAccountsAfter(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			// stub
+			tc.stubFn(store)
+
+			// test
+			path := "/accounts?" + tc.queryFn(t, server.cursors).Encode()
+			request, err := http.NewRequest(http.MethodGet, path, nil)
+			require.NoError(t, err)
+			tc.setupAuthFn(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+
+			// assert
+			tc.checkResponseFn(t, recorder, server.cursors)
+		})
+	}
 }
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/gin-gonic/gin"
+)
+
+// auditLogScope is the scope of the cursors of the audit log, which is the same list for every banker.
+const auditLogScope = "audit_log"
+
+// listAuditLogRequest filters the audit log, every filter left empty matching all entries.
+// The audit log is paged by keyset only, oldest entry first.
+type listAuditLogRequest struct {
+	Actor        string    `form:"actor"`
+	Action       string    `form:"action"`
+	ResourceType string    `form:"resource_type" binding:"required_with=ResourceID"`
+	ResourceID   string    `form:"resource_id"`
+	RequestID    string    `form:"request_id"`
+	From         time.Time `form:"from"`
+	To           time.Time `form:"to" binding:"omitempty,gtfield=From"`
+	PageSize     int32     `form:"page_size" binding:"required,min=5,max=10"`
+	Cursor       string    `form:"cursor"`
+}
+
+type listAuditLogResponse struct {
+	Entries    []db.AuditLog `json:"entries"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// listAuditLog lists the entries of the audit log matching the filters, for the bankers.
+func (server *Server) listAuditLog(ctx *gin.Context) {
+	var req listAuditLogRequest
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	page := pageRequest{PageSize: req.PageSize, Cursor: req.Cursor}
+	after, err := server.after(page, auditLogScope)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	entries, err := server.store.ListAuditLog(ctx, db.ListAuditLogParams{
+		AfterID:      after.ID,
+		Actor:        optionalString(req.Actor),
+		Action:       optionalString(req.Action),
+		ResourceType: optionalString(req.ResourceType),
+		ResourceID:   optionalString(req.ResourceID),
+		RequestID:    optionalString(req.RequestID),
+		CreatedFrom:  optionalTime(req.From),
+		CreatedTo:    optionalTime(req.To),
+		Limit:        page.keysetLimit(),
+	})
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while listing the audit log: %w", err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	rsp := listAuditLogResponse{Entries: entries}
+	if len(entries) > int(req.PageSize) {
+		rsp.Entries = entries[:req.PageSize]
+		last := rsp.Entries[req.PageSize-1]
+		rsp.NextCursor, err = server.nextCursor(auditLogScope, last.CreatedAt, last.ID)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// optionalString is null for an empty filter.
+func optionalString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// optionalTime is null for a filter left out.
+func optionalTime(value time.Time) sql.NullTime {
+	return sql.NullTime{Time: value, Valid: !value.IsZero()}
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	"github.com/anilbolat/simple-bank/cursor"
+	mockdb "github.com/anilbolat/simple-bank/db/mock"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/token"
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAuditLogAPI(t *testing.T) {
+	// given
+	banker, _ := randomUser(t)
+	createdAt := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	from := createdAt.Add(-time.Hour)
+	to := createdAt.Add(time.Hour)
+
+	n := 6
+	entries := make([]db.AuditLog, n)
+	for i := range entries {
+		entries[i] = randomAuditLog(int64(i+1), createdAt.Add(time.Duration(i)*time.Minute))
+	}
+
+	testCases := []struct {
+		name            string
+		queryFn         func(t *testing.T, cursors *cursor.Signer) url.Values
+		setupAuthFn     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		stubFn          func(store *mockdb.MockStore)
+		checkResponseFn func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer)
+	}{
+		{
+			name: "FirstPage",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, banker.Username, util.BankerRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAuditLog(gomock.Any(), gomock.Eq(db.ListAuditLogParams{Limit: 6})).
+					Times(1).
+					Return(entries, nil)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp listAuditLogResponse
+				err := json.NewDecoder(recorder.Body).Decode(&rsp)
+				require.NoError(t, err)
+				require.Len(t, rsp.Entries, 5)
+				require.Equal(t, entries[4].Hash, rsp.Entries[4].Hash)
+
+				next, err := cursors.Decode(rsp.NextCursor, auditLogScope)
+				require.NoError(t, err)
+				require.Equal(t, entries[4].ID, next.ID)
+			},
+		},
+		{
+			name: "Filters",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				token, err := cursors.Encode(cursor.Cursor{Scope: auditLogScope, CreatedAt: entries[4].CreatedAt, ID: entries[4].ID})
+				require.NoError(t, err)
+				return url.Values{
+					"actor":         {banker.Username},
+					"action":        {db.AuditAccountStatusChange},
+					"resource_type": {db.ResourceAccount},
+					"resource_id":   {"42"},
+					"request_id":    {"request-1"},
+					"from":          {from.Format(time.RFC3339)},
+					"to":            {to.Format(time.RFC3339)},
+					"page_size":     {"5"},
+					"cursor":        {token},
+				}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, banker.Username, util.BankerRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				arg := db.ListAuditLogParams{
+					AfterID:      entries[4].ID,
+					Actor:        sql.NullString{String: banker.Username, Valid: true},
+					Action:       sql.NullString{String: db.AuditAccountStatusChange, Valid: true},
+					ResourceType: sql.NullString{String: db.ResourceAccount, Valid: true},
+					ResourceID:   sql.NullString{String: "42", Valid: true},
+					RequestID:    sql.NullString{String: "request-1", Valid: true},
+					CreatedFrom:  sql.NullTime{Time: from, Valid: true},
+					CreatedTo:    sql.NullTime{Time: to, Valid: true},
+					Limit:        6,
+				}
+				store.EXPECT().
+					ListAuditLog(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(entries[5:], nil)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp map[string]json.RawMessage
+				err := json.NewDecoder(recorder.Body).Decode(&rsp)
+				require.NoError(t, err)
+				require.Contains(t, rsp, "entries")
+				require.NotContains(t, rsp, "next_cursor")
+			},
+		},
+		{
+			name: "ResourceIDWithoutType",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"resource_id": {"42"}, "page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, banker.Username, util.BankerRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAuditLog(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeValidationFailed)
+			},
+		},
+		{
+			name: "ToBeforeFrom",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"from": {to.Format(time.RFC3339)}, "to": {from.Format(time.RFC3339)}, "page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, banker.Username, util.BankerRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAuditLog(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeValidationFailed)
+			},
+		},
+		{
+			name: "InvalidCursor",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"5"}, "cursor": {"invalid"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, banker.Username, util.BankerRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAuditLog(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeInvalidCursor)
+			},
+		},
+		{
+			name: "Depositor",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, banker.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAuditLog(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeForbidden)
+			},
+		},
+		{
+			name: "InternalError",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, banker.Username, util.BankerRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAuditLog(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, sql.ErrConnDone)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeInternal)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			// stub
+			tc.stubFn(store)
+
+			// test
+			path := "/admin/audit_log?" + tc.queryFn(t, server.cursors).Encode()
+			request, err := http.NewRequest(http.MethodGet, path, nil)
+			require.NoError(t, err)
+			tc.setupAuthFn(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+
+			// assert
+			tc.checkResponseFn(t, recorder, server.cursors)
+		})
+	}
+}
+
+func TestAuditActorAPI(t *testing.T) {
+	// given
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	// the changes are made with the authenticated user as the actor
+	var actor db.AuditActor
+	store.EXPECT().
+		CreateAccountTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
+			actor = db.AuditActorFrom(ctx)
+			return account, nil
+		})
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	// test
+	body := bytes.NewBufferString(fmt.Sprintf(`{"currency":%q}`, account.Currency))
+	request, err := http.NewRequest(http.MethodPost, "/accounts", body)
+	require.NoError(t, err)
+	request.RemoteAddr = "192.0.2.1:1234"
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+
+	// assert
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, db.AuditActor{Username: user.Username, ClientIP: "192.0.2.1"}, actor)
+}
+
+func randomAuditLog(id int64, createdAt time.Time) db.AuditLog {
+	after := json.RawMessage(fmt.Sprintf(`{"id":%d}`, id))
+	return db.AuditLog{
+		ID:           id,
+		Actor:        util.RandomOwner(),
+		Action:       db.AuditAccountCreate,
+		ResourceType: db.ResourceAccount,
+		ResourceID:   fmt.Sprint(id),
+		After:        &after,
+		RequestID:    util.RandomString(16),
+		CreatedAt:    createdAt,
+		PrevHash:     util.RandomString(64),
+		Hash:         util.RandomString(64),
+	}
+}
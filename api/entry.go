@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/gin-gonic/gin"
+)
+
+type listEntriesRequest struct {
+	pageRequest
+}
+
+type listEntriesResponse struct {
+	Entries    []db.Entry `json:"entries"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+func (server *Server) listEntries(ctx *gin.Context) {
+	var uriReq getAccountRequest
+	err := ctx.ShouldBindUri(&uriReq)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	var req listEntriesRequest
+	err = ctx.ShouldBindQuery(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	if _, valid := server.getOwnedAccount(ctx, uriReq.ID); !valid {
+		return
+	}
+
+	if req.byOffset() {
+		entries, err := server.store.ListEntries(ctx, db.ListEntriesParams{
+			AccountID: uriReq.ID,
+			Limit:     req.PageSize,
+			Offset:    req.offset(),
+		})
+		if err != nil {
+			errServer := fmt.Errorf("error occurred while listing entries of account ID %d: %w", uriReq.ID, err)
+			respondError(ctx, errServer)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, entries)
+		return
+	}
+
+	scope := fmt.Sprintf("entries:%d", uriReq.ID)
+	after, err := server.after(req.pageRequest, scope)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	entries, err := server.store.ListEntriesAfter(ctx, db.ListEntriesAfterParams{
+		AccountID:      uriReq.ID,
+		AfterCreatedAt: after.CreatedAt,
+		AfterID:        after.ID,
+		Limit:          req.keysetLimit(),
+	})
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while listing entries of account ID %d: %w", uriReq.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	rsp := listEntriesResponse{Entries: entries}
+	if len(entries) > int(req.PageSize) {
+		rsp.Entries = entries[:req.PageSize]
+		last := rsp.Entries[req.PageSize-1]
+		rsp.NextCursor, err = server.nextCursor(scope, last.CreatedAt, last.ID)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
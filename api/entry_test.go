@@ -0,0 +1,197 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	"github.com/anilbolat/simple-bank/cursor"
+	mockdb "github.com/anilbolat/simple-bank/db/mock"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/token"
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListEntriesAPI(t *testing.T) {
+	// given
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	scope := fmt.Sprintf("entries:%d", account.ID)
+	createdAt := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	entries := make([]db.Entry, 6)
+	for i := range entries {
+		entries[i] = db.Entry{
+			ID:        int64(i + 1),
+			AccountID: account.ID,
+			Amount:    util.RandomMoney(),
+			CreatedAt: createdAt.Add(time.Duration(i) * time.Minute),
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		queryFn         func(t *testing.T, cursors *cursor.Signer) url.Values
+		setupAuthFn     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		stubFn          func(store *mockdb.MockStore)
+		checkResponseFn func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer)
+	}{
+		{
+			name: "ByOffset",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_id": {"1"}, "page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					ListEntries(gomock.Any(), gomock.Eq(db.ListEntriesParams{AccountID: account.ID, Limit: 5, Offset: 0})).
+					Times(1).
+					Return(entries[:5], nil)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var gotEntries []db.Entry
+				err := json.NewDecoder(recorder.Body).Decode(&gotEntries)
+				require.NoError(t, err)
+				require.Equal(t, entries[:5], gotEntries)
+			},
+		},
+		{
+			name: "NextPage",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				token, err := cursors.Encode(cursor.Cursor{Scope: scope, CreatedAt: createdAt, ID: 0})
+				require.NoError(t, err)
+				return url.Values{"page_size": {"5"}, "cursor": {token}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					ListEntriesAfter(gomock.Any(), gomock.Eq(db.ListEntriesAfterParams{
+						AccountID:      account.ID,
+						AfterCreatedAt: createdAt,
+						AfterID:        0,
+						Limit:          6,
+					})).
+					Times(1).
+					Return(entries, nil)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp listEntriesResponse
+				err := json.NewDecoder(recorder.Body).Decode(&rsp)
+				require.NoError(t, err)
+				require.Equal(t, entries[:5], rsp.Entries)
+
+				next, err := cursors.Decode(rsp.NextCursor, scope)
+				require.NoError(t, err)
+				require.Equal(t, entries[4].ID, next.ID)
+			},
+		},
+		{
+			name: "CursorOfOtherAccount",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				token, err := cursors.Encode(cursor.Cursor{Scope: fmt.Sprintf("entries:%d", account.ID+1), CreatedAt: createdAt})
+				require.NoError(t, err)
+				return url.Values{"page_size": {"5"}, "cursor": {token}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListEntriesAfter(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeInvalidCursor)
+			},
+		},
+		{
+			name: "UnauthorizedUser",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListEntriesAfter(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeAccountNotOwned)
+			},
+		},
+		{
+			name: "NotFound",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().ListEntriesAfter(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			queryFn: func(t *testing.T, cursors *cursor.Signer) url.Values {
+				return url.Values{"page_size": {"5"}}
+			},
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListEntriesAfter(gomock.Any(), gomock.Any()).Times(1).Return(nil, sql.ErrConnDone)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder, cursors *cursor.Signer) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeInternal)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			// stub
+			tc.stubFn(store)
+
+			// test
+			path := fmt.Sprintf("/accounts/%d/entries?%s", account.ID, tc.queryFn(t, server.cursors).Encode())
+			request, err := http.NewRequest(http.MethodGet, path, nil)
+			require.NoError(t, err)
+			tc.setupAuthFn(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+
+			// assert
+			tc.checkResponseFn(t, recorder, server.cursors)
+		})
+	}
+}
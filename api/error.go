@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	"github.com/anilbolat/simple-bank/cursor"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/export"
+	"github.com/anilbolat/simple-bank/fx"
+	"github.com/anilbolat/simple-bank/logging"
+	"github.com/anilbolat/simple-bank/token"
+	"github.com/gin-gonic/gin"
+)
+
+// domainErrors maps the errors of the other packages to the status and the code they are sent with,
+// whatever the handler. The detail is the message of the sentinel error, never the message of the error chain,
+// which may tell about the internals. A missing row is not mapped here: each handler tells what was not found.
+var domainErrors = []struct {
+	err    error
+	status int
+	code   apierror.Code
+}{
+	{db.ErrInsufficientFunds, http.StatusUnprocessableEntity, apierror.CodeInsufficientFunds},
+	{db.ErrCurrencyMismatch, http.StatusBadRequest, apierror.CodeCurrencyMismatch},
+	{db.ErrIdempotencyKeyReused, http.StatusConflict, apierror.CodeIdempotencyKeyReused},
+	{db.ErrAccountNotActive, http.StatusUnprocessableEntity, apierror.CodeAccountNotActive},
+	{db.ErrInvalidStatusTransition, http.StatusConflict, apierror.CodeInvalidStatusTransition},
+	{db.ErrAccountBalanceNotZero, http.StatusConflict, apierror.CodeAccountBalanceNotZero},
+	{db.ErrTransferAlreadyReversed, http.StatusConflict, apierror.CodeTransferAlreadyReversed},
+	{db.ErrInvalidReversalAmount, http.StatusUnprocessableEntity, apierror.CodeInvalidReversalAmount},
+	{db.ErrReversalNotReversible, http.StatusConflict, apierror.CodeReversalNotReversible},
+	{db.ErrInvalidSchedule, http.StatusBadRequest, apierror.CodeInvalidSchedule},
+	{fx.ErrRateNotFound, http.StatusUnprocessableEntity, apierror.CodeExchangeRateNotFound},
+	{cursor.ErrInvalidCursor, http.StatusBadRequest, apierror.CodeInvalidCursor},
+	{export.ErrUnknownFormat, http.StatusBadRequest, apierror.CodeUnknownFormat},
+	{token.ErrExpiredToken, http.StatusUnauthorized, apierror.CodeTokenExpired},
+	{token.ErrInvalidToken, http.StatusUnauthorized, apierror.CodeInvalidToken},
+}
+
+// toAPIError returns err as an apierror.Error. An apierror.Error in the chain of err is returned as it is,
+// known domain errors are mapped to their status and code, and anything else is an internal error.
+func toAPIError(err error) *apierror.Error {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	for _, domainErr := range domainErrors {
+		if errors.Is(err, domainErr.err) {
+			return apierror.Wrap(err, domainErr.status, domainErr.code, domainErr.err.Error())
+		}
+	}
+
+	return apierror.Internal(err)
+}
+
+// respondError aborts the request with the error as problem details and logs it.
+// Errors that are not an apierror.Error or a known domain error are internal errors, their message is only logged.
+func respondError(ctx *gin.Context, err error) {
+	apiErr := toAPIError(err)
+
+	switch {
+	case apiErr.Status >= http.StatusInternalServerError:
+		slog.ErrorContext(ctx, apiErr.Error(), "code", apiErr.Code)
+	case apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden:
+		slog.WarnContext(ctx, apiErr.Error(), "code", apiErr.Code)
+	default:
+		slog.InfoContext(ctx, apiErr.Error(), "code", apiErr.Code)
+	}
+
+	ctx.Header("Content-Type", apierror.ContentType)
+	ctx.AbortWithStatusJSON(apiErr.Status, apiErr.Problem(ctx.Request.URL.Path, logging.RequestID(ctx)))
+}
+
+// respondInvalid responds with the error of a request that could not be bound.
+func respondInvalid(ctx *gin.Context, err error) {
+	respondError(ctx, apierror.Invalid(err))
+}
+
+// requestFieldName names the fields in validation errors as the client sends them,
+// taking the name from the json, form or uri tag.
+func requestFieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
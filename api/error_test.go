@@ -0,0 +1,170 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	mockdb "github.com/anilbolat/simple-bank/db/mock"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/fx"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorResponses(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name            string
+		method          string
+		url             string
+		body            string
+		checkResponseFn func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "ValidationFields",
+			method: http.MethodPost,
+			url:    "/transfers",
+			body:   `{"from_account_id": 1, "to_account_id": 1, "amount": -5, "currency": "XYZ"}`,
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+				var problem apierror.Problem
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+				require.Equal(t, apierror.CodeValidationFailed, problem.Code)
+				require.Equal(t, []apierror.FieldError{
+					{Field: "to_account_id", Rule: "nefield", Message: "must differ from FromAccountID"},
+					{Field: "amount", Rule: "gt", Message: "must be greater than 0"},
+					{Field: "currency", Rule: "currency", Message: "is not a supported currency"},
+				}, problem.Errors)
+			},
+		},
+		{
+			name:   "MalformedBody",
+			method: http.MethodPost,
+			url:    "/transfers",
+			body:   `{"from_account_id": `,
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeMalformedRequest)
+			},
+		},
+		{
+			name:   "WrongFieldType",
+			method: http.MethodPost,
+			url:    "/transfers",
+			body:   `{"from_account_id": "one"}`,
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeMalformedRequest)
+			},
+		},
+		{
+			name:   "NoRoute",
+			method: http.MethodGet,
+			url:    "/unknown",
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeNotFound)
+			},
+		},
+		{
+			name:   "NoMethod",
+			method: http.MethodDelete,
+			url:    "/transfers",
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeMethodNotAllowed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(tc.method, tc.url, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponseFn(t, recorder)
+		})
+	}
+}
+
+func TestToAPIError(t *testing.T) {
+	errNotOwned := apierror.New(http.StatusUnauthorized, apierror.CodeAccountNotOwned, "account does not belong to the authenticated user")
+
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   apierror.Code
+		expectedDetail string
+	}{
+		{
+			name:           "apiError",
+			err:            fmt.Errorf("checking owner: %w", errNotOwned),
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   apierror.CodeAccountNotOwned,
+			expectedDetail: "account does not belong to the authenticated user",
+		},
+		{
+			name:           "insufficientFunds",
+			err:            fmt.Errorf("transfer: %w", db.ErrInsufficientFunds),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   apierror.CodeInsufficientFunds,
+			expectedDetail: db.ErrInsufficientFunds.Error(),
+		},
+		{
+			name:           "rateNotFound",
+			err:            fmt.Errorf("%w: USD/EUR", fx.ErrRateNotFound),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   apierror.CodeExchangeRateNotFound,
+			expectedDetail: fx.ErrRateNotFound.Error(),
+		},
+		{
+			// a missing row the handler did not map is a bug, not a resource the client asked for
+			name:           "noRows",
+			err:            sql.ErrNoRows,
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   apierror.CodeInternal,
+			expectedDetail: "internal server error",
+		},
+		{
+			name:           "internal",
+			err:            fmt.Errorf("error occurred for account ID 1: %w", errors.New(`pq: relation "accounts" does not exist`)),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   apierror.CodeInternal,
+			expectedDetail: "internal server error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			apiErr := toAPIError(tc.err)
+			if tc.expectedCode != apierror.CodeAccountNotOwned {
+				// the cause is kept for the logs
+				require.ErrorIs(t, apiErr, tc.err)
+			}
+
+			require.Equal(t, tc.expectedStatus, apiErr.Status)
+			require.Equal(t, tc.expectedCode, apiErr.Code)
+			require.Equal(t, tc.expectedDetail, apiErr.Detail)
+		})
+	}
+}
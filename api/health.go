@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/anilbolat/simple-bank/health"
+	"github.com/gin-gonic/gin"
+)
+
+// statusShuttingDown is the readiness status once the server started shutting down.
+const statusShuttingDown = "shutting_down"
+
+// dbHealth is reported by the db check.
+type dbHealth struct {
+	MigrationVersion   int64  `json:"migration_version"`
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+}
+
+// RegisterHealthCheck adds a check to the readiness of the server.
+func (server *Server) RegisterHealthCheck(name string, check health.CheckFunc) {
+	server.health.Register(name, check)
+}
+
+// checkDB pings the db and reports the migration version and the connection pool.
+// A migration that failed halfway makes the db unhealthy.
+func (server *Server) checkDB(ctx context.Context) (interface{}, error) {
+	err := server.store.Ping(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, dirty, err := server.store.MigrationVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get migration version: %w", err)
+	}
+
+	stats := server.store.Stats()
+	details := dbHealth{
+		MigrationVersion:   version,
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+	}
+	if dirty {
+		return details, fmt.Errorf("migration %d is dirty", version)
+	}
+
+	return details, nil
+}
+
+// liveness only tells the process is serving requests, it checks no dependency.
+func (server *Server) liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// readiness runs the health checks. It fails while shutting down, so that no new traffic is routed here.
+func (server *Server) readiness(ctx *gin.Context) {
+	if server.shuttingDown.Load() {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": statusShuttingDown})
+		return
+	}
+
+	report := server.health.Run(ctx)
+	if report.Status != health.StatusUp {
+		ctx.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/anilbolat/simple-bank/db/mock"
+	"github.com/anilbolat/simple-bank/health"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLivenessAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().Ping(gomock.Any()).Times(0)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/healthz", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestReadinessAPI(t *testing.T) {
+	testCases := []struct {
+		name            string
+		setupFn         func(t *testing.T, server *Server)
+		stubFn          func(store *mockdb.MockStore)
+		checkResponseFn func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "OK",
+			setupFn: func(t *testing.T, server *Server) {},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(int64(6), false, nil)
+				store.EXPECT().Stats().Times(1).Return(sql.DBStats{MaxOpenConnections: 10, OpenConnections: 2, InUse: 1, Idle: 1})
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				report := readReport(t, recorder)
+				require.Equal(t, health.StatusUp, report.Status)
+
+				var details dbHealth
+				require.NoError(t, json.Unmarshal(report.Checks["db"].Details, &details))
+				require.Equal(t, int64(6), details.MigrationVersion)
+				require.Equal(t, 2, details.OpenConnections)
+				require.Equal(t, 1, details.InUse)
+			},
+		},
+		{
+			name:    "PingFails",
+			setupFn: func(t *testing.T, server *Server) {},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(sql.ErrConnDone)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				report := readReport(t, recorder)
+				require.Equal(t, health.StatusDown, report.Status)
+				require.Equal(t, sql.ErrConnDone.Error(), report.Checks["db"].Error)
+			},
+		},
+		{
+			name: "PingTimesOut",
+			setupFn: func(t *testing.T, server *Server) {
+				server.health = health.NewRegistry(10 * time.Millisecond)
+				server.RegisterHealthCheck("db", server.checkDB)
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).DoAndReturn(func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				})
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				report := readReport(t, recorder)
+				require.Contains(t, report.Checks["db"].Error, context.DeadlineExceeded.Error())
+			},
+		},
+		{
+			name:    "DirtyMigration",
+			setupFn: func(t *testing.T, server *Server) {},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(int64(6), true, nil)
+				store.EXPECT().Stats().Times(1).Return(sql.DBStats{})
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				report := readReport(t, recorder)
+				require.Equal(t, "migration 6 is dirty", report.Checks["db"].Error)
+			},
+		},
+		{
+			name: "RegisteredCheckFails",
+			setupFn: func(t *testing.T, server *Server) {
+				server.RegisterHealthCheck("broker", func(ctx context.Context) (interface{}, error) {
+					return nil, errors.New("broker is down")
+				})
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(int64(6), false, nil)
+				store.EXPECT().Stats().Times(1).Return(sql.DBStats{})
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				report := readReport(t, recorder)
+				require.Equal(t, health.StatusDown, report.Status)
+				require.Equal(t, health.StatusUp, report.Checks["db"].Status)
+				require.Equal(t, "broker is down", report.Checks["broker"].Error)
+			},
+		},
+		{
+			name: "ShuttingDown",
+			setupFn: func(t *testing.T, server *Server) {
+				require.NoError(t, server.Shutdown(context.Background()))
+			},
+			stubFn: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(0)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				require.Contains(t, recorder.Body.String(), statusShuttingDown)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			// stub
+			tc.setupFn(t, server)
+			tc.stubFn(store)
+
+			// test
+			request, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+			require.NoError(t, err)
+			server.router.ServeHTTP(recorder, request)
+
+			// assert
+			tc.checkResponseFn(t, recorder)
+		})
+	}
+}
+
+// reportResponse is a health.Report with the details of the checks left to decode.
+type reportResponse struct {
+	Status string `json:"status"`
+	Checks map[string]struct {
+		Status  string          `json:"status"`
+		Error   string          `json:"error"`
+		Details json.RawMessage `json:"details"`
+	} `json:"checks"`
+}
+
+func readReport(t *testing.T, recorder *httptest.ResponseRecorder) reportResponse {
+	var report reportResponse
+	err := json.NewDecoder(recorder.Body).Decode(&report)
+	require.NoError(t, err)
+	return report
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/logging"
+	"github.com/anilbolat/simple-bank/token"
+	"github.com/anilbolat/simple-bank/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	authorizationHeaderKey  = "authorization"
+	authorizationTypeBearer = "bearer"
+	authorizationPayloadKey = "authorization_payload"
+	requestIDHeaderKey      = "X-Request-ID"
+	maxRequestIDLength      = 128
+)
+
+var errUnauthorizedAccount = apierror.New(http.StatusUnauthorized, apierror.CodeAccountNotOwned,
+	"account does not belong to the authenticated user")
+
+// tracingMiddleware creates a span for every request, continuing the trace given in the W3C traceparent header.
+// The span is named after the route rather than the path, so that requests of a route can be grouped.
+func tracingMiddleware(tracer trace.Tracer) gin.HandlerFunc {
+	propagator := tracing.Propagator()
+
+	return func(ctx *gin.Context) {
+		requestCtx := propagator.Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+
+		route := ctx.FullPath()
+		spanName := ctx.Request.Method
+		if route != "" {
+			spanName += " " + route
+		}
+		requestCtx, span := tracer.Start(requestCtx, spanName,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(ctx.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(ctx.Request.URL.Path),
+				semconv.ClientAddress(ctx.ClientIP()),
+			),
+		)
+		defer span.End()
+
+		ctx.Request = ctx.Request.WithContext(requestCtx)
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// requestIDMiddleware puts the request ID into the request ctx and the response header.
+// The ID given by the client in the X-Request-ID header is kept if it is valid, otherwise a new one is generated.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(requestIDHeaderKey)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		ctx.Request = ctx.Request.WithContext(logging.WithRequestID(ctx.Request.Context(), requestID))
+		ctx.Header(requestIDHeaderKey, requestID)
+		ctx.Next()
+	}
+}
+
+// validRequestID only accepts IDs that cannot break or forge log lines.
+func validRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range requestID {
+		isAlphanumeric := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphanumeric && !strings.ContainsRune("-_.:", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// accessLogMiddleware logs every request once it is served, server errors at error level and client errors at warn level.
+func accessLogMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		slog.LogAttrs(ctx, level, "request served",
+			slog.String("method", ctx.Request.Method),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.String("route", ctx.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", ctx.ClientIP()),
+			slog.Int("size", ctx.Writer.Size()),
+		)
+	}
+}
+
+// recoveryMiddleware turns a panic in a handler into an internal server error, logging it with its stack.
+func recoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, recovered any) {
+		slog.ErrorContext(ctx, "panic while serving the request", "panic", recovered, "stack", string(debug.Stack()))
+		respondError(ctx, fmt.Errorf("panic: %v", recovered))
+	})
+}
+
+// authMiddleware aborts the request unless it carries a valid bearer access token, a refresh token being rejected.
+// The verified token payload is stored in the context under authorizationPayloadKey,
+// and the changes made while serving the request are audited as made by its user.
+func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
+		if len(authorizationHeader) == 0 {
+			respondError(ctx, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthenticated, "authorization header is not provided"))
+			return
+		}
+
+		fields := strings.Fields(authorizationHeader)
+		if len(fields) < 2 {
+			respondError(ctx, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthenticated, "invalid authorization header format"))
+			return
+		}
+
+		authorizationType := strings.ToLower(fields[0])
+		if authorizationType != authorizationTypeBearer {
+			detail := fmt.Sprintf("unsupported authorization type %s", authorizationType)
+			respondError(ctx, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthenticated, detail))
+			return
+		}
+
+		accessToken := fields[1]
+		payload, err := tokenMaker.VerifyToken(accessToken, token.TokenTypeAccessToken)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+
+		ctx.Set(authorizationPayloadKey, payload)
+		setAuditActor(ctx, payload.Username)
+		ctx.Next()
+	}
+}
+
+// roleMiddleware aborts the request unless the authenticated user has one of the allowed roles.
+// It must run after authMiddleware.
+func roleMiddleware(allowedRoles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authPayload := getAuthPayload(ctx)
+		for _, role := range allowedRoles {
+			if authPayload.Role == role {
+				ctx.Next()
+				return
+			}
+		}
+
+		detail := fmt.Sprintf("role %s is not allowed to access this resource", authPayload.Role)
+		respondError(ctx, apierror.New(http.StatusForbidden, apierror.CodeForbidden, detail))
+	}
+}
+
+// setAuditActor puts the user into the request ctx, the store audits the changes made with it as made by the user
+// from the client IP.
+func setAuditActor(ctx *gin.Context, username string) {
+	actor := db.AuditActor{Username: username, ClientIP: ctx.ClientIP()}
+	ctx.Request = ctx.Request.WithContext(db.WithAuditActor(ctx.Request.Context(), actor))
+}
+
+func getAuthPayload(ctx *gin.Context) *token.Payload {
+	return ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+}
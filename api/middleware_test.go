@@ -0,0 +1,390 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	mockdb "github.com/anilbolat/simple-bank/db/mock"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/logging"
+	"github.com/anilbolat/simple-bank/token"
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func addAuthorization(
+	t *testing.T,
+	request *http.Request,
+	tokenMaker token.Maker,
+	authorizationType string,
+	username string,
+	role string,
+	duration time.Duration,
+) {
+	accessToken, payload, err := tokenMaker.CreateToken(username, role, duration, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+
+	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, accessToken)
+	request.Header.Set(authorizationHeaderKey, authorizationHeader)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	username := "user"
+
+	testCases := []struct {
+		name            string
+		setupAuthFn     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		checkResponseFn func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, username, util.DepositorRole, time.Minute)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeUnauthenticated)
+			},
+		},
+		{
+			name: "UnsupportedAuthorization",
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, "unsupported", username, util.DepositorRole, time.Minute)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeUnauthenticated)
+			},
+		},
+		{
+			name: "InvalidAuthorizationFormat",
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, "", username, util.DepositorRole, time.Minute)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeUnauthenticated)
+			},
+		},
+		{
+			name: "RefreshToken",
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				refreshToken, _, err := tokenMaker.CreateToken(username, util.DepositorRole, time.Minute, token.TokenTypeRefreshToken)
+				require.NoError(t, err)
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, refreshToken))
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeInvalidToken)
+			},
+		},
+		{
+			name: "ExpiredToken",
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, username, util.DepositorRole, -time.Minute)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeTokenExpired)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+
+			authPath := "/auth"
+			server.router.GET(
+				authPath,
+				authMiddleware(server.tokenMaker),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, gin.H{})
+				},
+			)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			tc.setupAuthFn(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+
+			tc.checkResponseFn(t, recorder)
+		})
+	}
+}
+
+func TestRoleMiddleware(t *testing.T) {
+	username := "user"
+
+	testCases := []struct {
+		name            string
+		setupAuthFn     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		checkResponseFn func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, username, util.BankerRole, time.Minute)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "RoleNotAllowed",
+			setupAuthFn: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, username, util.DepositorRole, time.Minute)
+			},
+			checkResponseFn: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				assertErrorInResponse(t, recorder, apierror.CodeForbidden)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+
+			authPath := "/auth"
+			server.router.GET(
+				authPath,
+				authMiddleware(server.tokenMaker),
+				roleMiddleware(util.BankerRole),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, gin.H{})
+				},
+			)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			tc.setupAuthFn(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+
+			tc.checkResponseFn(t, recorder)
+		})
+	}
+}
+
+// captureLogs makes the default logger write JSON lines into the returned buffer until the test ends.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buffer bytes.Buffer
+	logger, err := logging.New(&buffer, "debug", "json")
+	require.NoError(t, err)
+
+	defaultLogger := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	return &buffer
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	testCases := []struct {
+		name             string
+		requestID        string
+		checkRequestIDFn func(t *testing.T, requestID string)
+	}{
+		{
+			name:      "generated",
+			requestID: "",
+			checkRequestIDFn: func(t *testing.T, requestID string) {
+				_, err := uuid.Parse(requestID)
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:      "givenByClient",
+			requestID: "client-id_1.2:3",
+			checkRequestIDFn: func(t *testing.T, requestID string) {
+				require.Equal(t, "client-id_1.2:3", requestID)
+			},
+		},
+		{
+			name:      "invalidReplaced",
+			requestID: "forged\" msg=\"x",
+			checkRequestIDFn: func(t *testing.T, requestID string) {
+				_, err := uuid.Parse(requestID)
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:      "tooLongReplaced",
+			requestID: strings.Repeat("a", maxRequestIDLength+1),
+			checkRequestIDFn: func(t *testing.T, requestID string) {
+				_, err := uuid.Parse(requestID)
+				require.NoError(t, err)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			user, _ := randomUser(t)
+			logs := captureLogs(t)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetAccount(gomock.Any(), int64(1)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/accounts/1", nil)
+			require.NoError(t, err)
+			if tc.requestID != "" {
+				request.Header.Set(requestIDHeaderKey, tc.requestID)
+			}
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+
+			// when
+			server.router.ServeHTTP(recorder, request)
+
+			// then
+			require.Equal(t, http.StatusNotFound, recorder.Code)
+			requestID := recorder.Header().Get(requestIDHeaderKey)
+			tc.checkRequestIDFn(t, requestID)
+
+			var response struct {
+				RequestID string `json:"request_id"`
+			}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			require.Equal(t, requestID, response.RequestID)
+
+			// the handler line and the access line
+			lines := 0
+			scanner := bufio.NewScanner(logs)
+			for scanner.Scan() {
+				var line map[string]interface{}
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+				require.Equal(t, requestID, line[logging.RequestIDKey])
+				lines++
+			}
+			require.Equal(t, 2, lines)
+		})
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	logs := captureLogs(t)
+	server := newTestServer(t, nil)
+	server.router.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/panic", nil)
+	require.NoError(t, err)
+	request.Header.Set(requestIDHeaderKey, "panic-request")
+
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusInternalServerError, recorder.Code)
+	require.Equal(t, "panic-request", recorder.Header().Get(requestIDHeaderKey))
+	assertErrorInResponse(t, recorder, apierror.CodeInternal)
+	require.Contains(t, logs.String(), `"panic":"boom"`)
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentSpanID := "00f067aa0ba902b7"
+
+	testCases := []struct {
+		name           string
+		storeErr       error
+		expectedStatus int
+		expectedCode   codes.Code
+	}{
+		{
+			name:           "OK",
+			storeErr:       nil,
+			expectedStatus: http.StatusOK,
+			expectedCode:   codes.Unset,
+		},
+		{
+			name:           "InternalError",
+			storeErr:       sql.ErrConnDone,
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   codes.Error,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			user, _ := randomUser(t)
+			account := randomAccount(user.Username)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+				Times(1).
+				DoAndReturn(func(ctx context.Context, id int64) (db.Account, error) {
+					// the store gets the span of the request, to create the spans of the queries as its children
+					require.Equal(t, traceID, trace.SpanContextFromContext(ctx).TraceID().String())
+					return account, tc.storeErr
+				})
+
+			exporter := tracetest.NewInMemoryExporter()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			server := newTestServer(t, store, WithTracerProvider(provider))
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/accounts/%d", account.ID), nil)
+			require.NoError(t, err)
+			request.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-01", traceID, parentSpanID))
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+
+			// when
+			server.router.ServeHTTP(recorder, request)
+
+			// then
+			require.Equal(t, tc.expectedStatus, recorder.Code)
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			require.Equal(t, "GET /accounts/:id", span.Name)
+			require.Equal(t, trace.SpanKindServer, span.SpanKind)
+			require.Equal(t, traceID, span.SpanContext.TraceID().String())
+			require.Equal(t, parentSpanID, span.Parent.SpanID().String())
+			require.Contains(t, span.Attributes, attribute.Int("http.response.status_code", tc.expectedStatus))
+			require.Equal(t, tc.expectedCode, span.Status.Code)
+		})
+	}
+}
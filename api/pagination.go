@@ -0,0 +1,42 @@
+package api
+
+import (
+	"time"
+
+	"github.com/anilbolat/simple-bank/cursor"
+)
+
+// pageRequest is the paging of a list request. With page_id, the list is paged by offset as it always was.
+// Otherwise it is paged by keyset, continuing after the cursor of the previous page or from the start without one.
+type pageRequest struct {
+	PageID   int32  `form:"page_id" binding:"omitempty,min=1"`
+	PageSize int32  `form:"page_size" binding:"required,min=5,max=10"`
+	Cursor   string `form:"cursor" binding:"excluded_with=PageID"`
+}
+
+func (req pageRequest) byOffset() bool {
+	return req.PageID > 0
+}
+
+func (req pageRequest) offset() int32 {
+	return (req.PageID - 1) * req.PageSize
+}
+
+// keysetLimit is one more than the page size, to tell whether there is a next page.
+func (req pageRequest) keysetLimit() int32 {
+	return req.PageSize + 1
+}
+
+// after returns the position the page starts after, for the list identified by scope.
+func (server *Server) after(req pageRequest, scope string) (cursor.Cursor, error) {
+	if req.Cursor == "" {
+		return cursor.Cursor{Scope: scope}, nil
+	}
+
+	return server.cursors.Decode(req.Cursor, scope)
+}
+
+// nextCursor returns the cursor of the page after the row with the given keyset.
+func (server *Server) nextCursor(scope string, createdAt time.Time, id int64) (string, error) {
+	return server.cursors.Encode(cursor.Cursor{Scope: scope, CreatedAt: createdAt, ID: id})
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/gin-gonic/gin"
+)
+
+var errUnauthorizedTransfer = apierror.New(http.StatusUnauthorized, apierror.CodeTransferNotOwned,
+	"transfer does not belong to the authenticated user")
+
+type getTransferRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// transferResponse is a transfer with its reversal state.
+type transferResponse struct {
+	db.Transfer
+	ReversalState    string `json:"reversal_state"`
+	ReversibleAmount int64  `json:"reversible_amount"`
+	// Reversals are the transfers reversing this one, oldest first.
+	Reversals []db.Transfer `json:"reversals,omitempty"`
+}
+
+func newTransferResponse(transfer db.Transfer, reversals []db.Transfer) transferResponse {
+	return transferResponse{
+		Transfer:         transfer,
+		ReversalState:    transfer.ReversalState(),
+		ReversibleAmount: transfer.ReversibleAmount(),
+		Reversals:        reversals,
+	}
+}
+
+// getTransfer responds with a transfer and how much of it was reversed.
+// It is visible to the owners of both accounts and to bankers.
+func (server *Server) getTransfer(ctx *gin.Context) {
+	var req getTransferRequest
+	err := ctx.ShouldBindUri(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	transfer, valid := server.getTransferOf(ctx, req.ID, true)
+	if !valid {
+		return
+	}
+
+	reversals, err := server.store.ListTransferReversals(ctx, transfer.ID)
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while listing reversals of transfer ID %d: %w", transfer.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newTransferResponse(transfer, reversals))
+}
+
+type reverseTransferRequest struct {
+	// Amount is the part of the transfer to give back, all that was not reversed yet if left out.
+	Amount int64 `json:"amount" binding:"omitempty,gt=0"`
+}
+
+type reverseTransferResponse struct {
+	Original    transferResponse `json:"original"`
+	Reversal    db.Transfer      `json:"reversal"`
+	FromAccount db.Account       `json:"from_account"`
+	ToAccount   db.Account       `json:"to_account"`
+	FromEntry   db.Entry         `json:"from_entry"`
+	ToEntry     db.Entry         `json:"to_entry"`
+}
+
+// reverseTransfer gives back a transfer, fully or partially, as a refund by the owner of the account it went to,
+// or as a correction by a banker.
+func (server *Server) reverseTransfer(ctx *gin.Context) {
+	var uriReq getTransferRequest
+	err := ctx.ShouldBindUri(&uriReq)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	var req reverseTransferRequest
+	// the body is optional, without it the whole transfer is reversed
+	if ctx.Request.ContentLength != 0 {
+		err = ctx.ShouldBindJSON(&req)
+		if err != nil {
+			respondInvalid(ctx, err)
+			return
+		}
+	}
+
+	transfer, valid := server.getTransferOf(ctx, uriReq.ID, false)
+	if !valid {
+		return
+	}
+
+	result, err := server.store.ReverseTransferTx(ctx, db.ReverseTransferTxParams{
+		TransferID: transfer.ID,
+		Amount:     req.Amount,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrInsufficientFunds) {
+			detail := fmt.Sprintf("account ID %d has insufficient funds", transfer.ToAccountID)
+			respondError(ctx, apierror.Wrap(err, http.StatusUnprocessableEntity, apierror.CodeInsufficientFunds, detail))
+			return
+		}
+
+		errServer := fmt.Errorf("error occurred while reversing transfer ID %d: %w", transfer.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, reverseTransferResponse{
+		Original:    newTransferResponse(result.Original, nil),
+		Reversal:    result.Reversal,
+		FromAccount: result.FromAccount,
+		ToAccount:   result.ToAccount,
+		FromEntry:   result.FromEntry,
+		ToEntry:     result.ToEntry,
+	})
+}
+
+// getTransferOf gets a transfer the user may access: a banker any transfer, others the transfers
+// to their accounts, and also those from their accounts if fromAllowed is true.
+// It writes the error response itself and returns false if the transfer cannot be accessed.
+func (server *Server) getTransferOf(ctx *gin.Context, transferID int64, fromAllowed bool) (db.Transfer, bool) {
+	transfer, err := server.store.GetTransfer(ctx, transferID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			detail := fmt.Sprintf("transfer ID %d does not exist", transferID)
+			respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeTransferNotFound, detail))
+			return transfer, false
+		}
+
+		errServer := fmt.Errorf("error occurred for transfer ID %d: %w", transferID, err)
+		respondError(ctx, errServer)
+		return transfer, false
+	}
+
+	authPayload := getAuthPayload(ctx)
+	if authPayload.Role == util.BankerRole {
+		return transfer, true
+	}
+
+	accountIDs := []int64{transfer.ToAccountID}
+	if fromAllowed {
+		accountIDs = append(accountIDs, transfer.FromAccountID)
+	}
+
+	for _, accountID := range accountIDs {
+		account, err := server.store.GetAccount(ctx, accountID)
+		if err != nil {
+			errServer := fmt.Errorf("error occurred for account ID %d: %w", accountID, err)
+			respondError(ctx, errServer)
+			return transfer, false
+		}
+
+		if account.Owner == authPayload.Username {
+			return transfer, true
+		}
+	}
+
+	respondError(ctx, errUnauthorizedTransfer)
+	return transfer, false
+}
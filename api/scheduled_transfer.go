@@ -0,0 +1,237 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/gin-gonic/gin"
+)
+
+// startsAtTolerance is how far in the past starts_at may be, for the clock skew and the latency of the request.
+// A start further back would have the missed runs caught up right away.
+const startsAtTolerance = time.Minute
+
+var errUnauthorizedScheduledTransfer = apierror.New(http.StatusUnauthorized, apierror.CodeScheduledTransferNotOwned,
+	"scheduled transfer does not belong to the authenticated user")
+
+type createScheduledTransferRequest struct {
+	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount        int64  `json:"amount" binding:"required,gt=0"`
+	Currency      string `json:"currency" binding:"required,currency"`
+	// Schedule is a cron expression, like "0 9 1 * *", or a descriptor, like "@monthly" or "@every 168h".
+	Schedule string `json:"schedule" binding:"required,max=100"`
+	// StartsAt is when the schedule starts, now if left out, and must not be in the past. EndsAt is optional.
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+// createScheduledTransfer sets up a standing order, a transfer made again and again on a schedule.
+// Scheduled transfers are between accounts of the same currency.
+func (server *Server) createScheduledTransfer(ctx *gin.Context) {
+	var req createScheduledTransferRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	now := time.Now()
+	startsAt := now
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+	startsAt = startsAt.Truncate(time.Second)
+
+	if startsAt.Before(now.Add(-startsAtTolerance)) {
+		respondError(ctx, apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, "starts_at must not be in the past"))
+		return
+	}
+
+	if req.EndsAt != nil && !req.EndsAt.After(startsAt) {
+		respondError(ctx, apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, "ends_at must be after starts_at"))
+		return
+	}
+
+	schedule, err := db.ParseSchedule(req.Schedule)
+	if err != nil {
+		// the message only tells what is wrong with the schedule of the request
+		respondError(ctx, apierror.Wrap(err, http.StatusBadRequest, apierror.CodeInvalidSchedule, err.Error()))
+		return
+	}
+
+	nextRunAt := db.FirstRun(schedule, startsAt)
+	if nextRunAt.IsZero() || (req.EndsAt != nil && nextRunAt.After(*req.EndsAt)) {
+		detail := fmt.Sprintf("%s: it has no run between starts_at and ends_at", db.ErrInvalidSchedule)
+		respondError(ctx, apierror.Wrap(db.ErrInvalidSchedule, http.StatusBadRequest, apierror.CodeInvalidSchedule, detail))
+		return
+	}
+
+	fromAccount, valid := server.validAccount(ctx, req.FromAccountID, req.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := getAuthPayload(ctx)
+	if fromAccount.Owner != authPayload.Username {
+		respondError(ctx, errUnauthorizedAccount)
+		return
+	}
+
+	_, valid = server.validAccount(ctx, req.ToAccountID, req.Currency)
+	if !valid {
+		return
+	}
+
+	scheduled, err := server.store.CreateScheduledTransfer(ctx, db.CreateScheduledTransferParams{
+		Owner:         authPayload.Username,
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Amount:        req.Amount,
+		Schedule:      req.Schedule,
+		StartsAt:      startsAt,
+		EndsAt:        req.EndsAt,
+		NextRunAt:     nextRunAt,
+	})
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while scheduling transfers from account ID %d to account ID %d: %w",
+			req.FromAccountID, req.ToAccountID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, scheduled)
+}
+
+type getScheduledTransferRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) getScheduledTransfer(ctx *gin.Context) {
+	var req getScheduledTransferRequest
+	err := ctx.ShouldBindUri(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	scheduled, valid := server.getOwnedScheduledTransfer(ctx, req.ID)
+	if !valid {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, scheduled)
+}
+
+// listScheduledTransfers lists the scheduled transfers of the authenticated user, including the finished and cancelled ones.
+func (server *Server) listScheduledTransfers(ctx *gin.Context) {
+	authPayload := getAuthPayload(ctx)
+
+	scheduled, err := server.store.ListScheduledTransfers(ctx, authPayload.Username)
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while listing scheduled transfers of %s: %w", authPayload.Username, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, scheduled)
+}
+
+type listScheduledTransferRunsRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+// listScheduledTransferRuns lists the runs of a scheduled transfer with their outcomes, the latest first.
+func (server *Server) listScheduledTransferRuns(ctx *gin.Context) {
+	var uriReq getScheduledTransferRequest
+	err := ctx.ShouldBindUri(&uriReq)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	var req listScheduledTransferRunsRequest
+	err = ctx.ShouldBindQuery(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	if _, valid := server.getOwnedScheduledTransfer(ctx, uriReq.ID); !valid {
+		return
+	}
+
+	runs, err := server.store.ListScheduledTransferRuns(ctx, db.ListScheduledTransferRunsParams{
+		ScheduledTransferID: uriReq.ID,
+		Limit:               req.PageSize,
+		Offset:              (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while listing runs of scheduled transfer ID %d: %w", uriReq.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, runs)
+}
+
+// cancelScheduledTransfer stops a scheduled transfer, the transfers made so far stay as they are.
+func (server *Server) cancelScheduledTransfer(ctx *gin.Context) {
+	var req getScheduledTransferRequest
+	err := ctx.ShouldBindUri(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	if _, valid := server.getOwnedScheduledTransfer(ctx, req.ID); !valid {
+		return
+	}
+
+	// a run in progress holds the row lock, the cancellation waits for it
+	scheduled, err := server.store.CancelScheduledTransfer(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			detail := fmt.Sprintf("scheduled transfer ID %d is already finished or cancelled", req.ID)
+			respondError(ctx, apierror.New(http.StatusConflict, apierror.CodeScheduledTransferNotActive, detail))
+			return
+		}
+
+		errServer := fmt.Errorf("error occurred while cancelling scheduled transfer ID %d: %w", req.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, scheduled)
+}
+
+// getOwnedScheduledTransfer gets the scheduled transfer and checks that it belongs to the authenticated user.
+// It writes the error response itself and returns false if the scheduled transfer cannot be used.
+func (server *Server) getOwnedScheduledTransfer(ctx *gin.Context, id int64) (db.ScheduledTransfer, bool) {
+	scheduled, err := server.store.GetScheduledTransfer(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			detail := fmt.Sprintf("scheduled transfer ID %d does not exist", id)
+			respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeScheduledTransferNotFound, detail))
+			return scheduled, false
+		}
+
+		errServer := fmt.Errorf("error occurred for scheduled transfer ID %d: %w", id, err)
+		respondError(ctx, errServer)
+		return scheduled, false
+	}
+
+	authPayload := getAuthPayload(ctx)
+	if scheduled.Owner != authPayload.Username {
+		respondError(ctx, errUnauthorizedScheduledTransfer)
+		return scheduled, false
+	}
+
+	return scheduled, true
+}
@@ -1,33 +1,259 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	"github.com/anilbolat/simple-bank/cursor"
 	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/fx"
+	"github.com/anilbolat/simple-bank/health"
+	"github.com/anilbolat/simple-bank/metrics"
+	"github.com/anilbolat/simple-bank/token"
+	"github.com/anilbolat/simple-bank/util"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Server serves HTTP requests for our banking service.
 type Server struct {
-	store  db.Store
-	router *gin.Engine
+	config     util.Config
+	store      db.Store
+	tokenMaker token.Maker
+	rates      fx.ExchangeRateProvider
+	cursors    *cursor.Signer
+	health     *health.Registry
+	metrics    *metrics.Metrics
+	tracer     trace.Tracer
+	router     *gin.Engine
+	httpServer *http.Server
+	// shuttingDown is set once Shutdown is called
+	shuttingDown atomic.Bool
+}
+
+// tracerName names the tracer of the HTTP requests.
+const tracerName = "github.com/anilbolat/simple-bank/api"
+
+// Defaults of the HTTP server timeouts, used when they are not configured.
+const (
+	defaultHTTPReadTimeout  = 5 * time.Second
+	defaultHTTPWriteTimeout = 60 * time.Second
+	defaultHTTPIdleTimeout  = 120 * time.Second
+	// defaultStatementExportTimeout replaces the write timeout for the statement exports, which stream large responses.
+	defaultStatementExportTimeout = 30 * time.Minute
+	// defaultHealthCheckTimeout is the timeout of each readiness check, used when it is not configured.
+	defaultHealthCheckTimeout = 2 * time.Second
+)
+
+// ServerOption configures a Server created by NewServer.
+type ServerOption func(server *Server)
+
+// WithMetrics records the metrics of every request.
+// They are not served by the router: they are for a listener of their own, which is not exposed publicly.
+func WithMetrics(metrics *metrics.Metrics) ServerOption {
+	return func(server *Server) {
+		server.metrics = metrics
+	}
+}
+
+// WithTracerProvider sets the provider of the spans of the requests, the global provider being the default.
+func WithTracerProvider(provider trace.TracerProvider) ServerOption {
+	return func(server *Server) {
+		server.tracer = provider.Tracer(tracerName)
+	}
 }
 
-func NewServer(store db.Store) *Server {
+func NewServer(config util.Config, store db.Store, opts ...ServerOption) (*Server, error) {
+	tokenMaker, err := newTokenMaker(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create token maker: %w", err)
+	}
+
+	rates, err := newExchangeRateProvider(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create exchange rate provider: %w", err)
+	}
+
+	cursors, err := cursor.NewSigner(config.CursorSigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create cursor signer: %w", err)
+	}
+
 	server := &Server{
-		store:  store,
-		router: gin.Default(),
+		config:     config,
+		store:      store,
+		tokenMaker: tokenMaker,
+		rates:      rates,
+		cursors:    cursors,
+		health:     health.NewRegistry(durationOrDefault(config.HealthCheckTimeout, defaultHealthCheckTimeout)),
+		tracer:     otel.GetTracerProvider().Tracer(tracerName),
 	}
+	server.RegisterHealthCheck("db", server.checkDB)
 
-	server.router.POST("/accounts", server.createAccount)
-	server.router.GET("/accounts/:id", server.getAccount)
-	server.router.GET("/accounts", server.listAccount)
+	for _, opt := range opts {
+		opt(server)
+	}
 
-	return server
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		_ = v.RegisterValidation("currency", validCurrency)
+		_ = v.RegisterValidation("webhook_url", validWebhookURL)
+		v.RegisterTagNameFunc(requestFieldName)
+	}
+
+	server.setupRouter()
+	server.httpServer = &http.Server{
+		Handler:      server.router,
+		ReadTimeout:  durationOrDefault(config.HTTPReadTimeout, defaultHTTPReadTimeout),
+		WriteTimeout: durationOrDefault(config.HTTPWriteTimeout, defaultHTTPWriteTimeout),
+		IdleTimeout:  durationOrDefault(config.HTTPIdleTimeout, defaultHTTPIdleTimeout),
+	}
+	return server, nil
 }
 
+func (server *Server) setupRouter() {
+	router := gin.New()
+	// lets the handlers pass the gin ctx to the store with the values of the request ctx, like the request ID
+	router.ContextWithFallback = true
+	router.Use(tracingMiddleware(server.tracer), requestIDMiddleware(), accessLogMiddleware())
+	// outside of the recovery, so that the panics are counted as the 500s they are answered with
+	if server.metrics != nil {
+		router.Use(server.metrics.Middleware())
+	}
+	router.Use(recoveryMiddleware())
+
+	router.NoRoute(func(ctx *gin.Context) {
+		respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "no route matches the path"))
+	})
+	router.HandleMethodNotAllowed = true
+	router.NoMethod(func(ctx *gin.Context) {
+		respondError(ctx, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "the method is not allowed on the route"))
+	})
+
+	router.GET("/healthz", server.liveness)
+	router.GET("/readyz", server.readiness)
+
+	router.POST("/users", server.createUser)
+	router.POST("/users/login", server.loginUser)
+	router.POST("/tokens/renew_access", server.renewAccessToken)
+
+	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker))
+
+	authRoutes.POST("/accounts", server.createAccount)
+	authRoutes.GET("/accounts/:id", server.getAccount)
+	authRoutes.GET("/accounts", server.listAccount)
+	authRoutes.GET("/accounts/:id/statement", server.getAccountStatement)
+	authRoutes.GET("/accounts/:id/statement/export", server.exportAccountStatement)
+	authRoutes.GET("/accounts/:id/entries", server.listEntries)
+	authRoutes.GET("/accounts/:id/transfers", server.listTransfers)
+
+	authRoutes.POST("/transfers", server.createTransfer)
+	authRoutes.GET("/transfers/:id", server.getTransfer)
+	authRoutes.POST("/transfers/:id/reverse", server.reverseTransfer)
+
+	authRoutes.POST("/scheduled_transfers", server.createScheduledTransfer)
+	authRoutes.GET("/scheduled_transfers", server.listScheduledTransfers)
+	authRoutes.GET("/scheduled_transfers/:id", server.getScheduledTransfer)
+	authRoutes.GET("/scheduled_transfers/:id/runs", server.listScheduledTransferRuns)
+	authRoutes.DELETE("/scheduled_transfers/:id", server.cancelScheduledTransfer)
+
+	authRoutes.POST("/webhooks", server.createWebhook)
+	authRoutes.GET("/webhooks", server.listWebhooks)
+	authRoutes.GET("/webhooks/:id", server.getWebhook)
+	authRoutes.DELETE("/webhooks/:id", server.deleteWebhook)
+	authRoutes.GET("/webhooks/:id/deliveries", server.listWebhookDeliveries)
+	authRoutes.POST("/webhooks/:id/deliveries/:delivery_id/replay", server.replayWebhookDelivery)
+
+	adminRoutes := router.Group("/admin").Use(authMiddleware(server.tokenMaker), roleMiddleware(util.BankerRole))
+
+	adminRoutes.POST("/users/:username/sessions/revoke", server.revokeUserSessions)
+	adminRoutes.POST("/accounts/:id/freeze", server.freezeAccount)
+	adminRoutes.POST("/accounts/:id/unfreeze", server.unfreezeAccount)
+	adminRoutes.POST("/accounts/:id/close", server.closeAccount)
+	adminRoutes.GET("/accounts/:id/status_changes", server.listAccountStatusChanges)
+	adminRoutes.GET("/audit_log", server.listAuditLog)
+
+	server.router = router
+}
+
+// newTokenMaker creates the token maker selected by TOKEN_TYPE, PASETO being the default.
+func newTokenMaker(config util.Config) (token.Maker, error) {
+	switch config.TokenType {
+	case "jwt":
+		return token.NewJWTMaker(config.TokenSymmetricKey)
+	case "", "paseto":
+		return token.NewPasetoMaker(config.TokenSymmetricKey)
+	default:
+		return nil, fmt.Errorf("unsupported token type %q", config.TokenType)
+	}
+}
+
+// newExchangeRateProvider loads the rates from EXCHANGE_RATES_FILE.
+// Without a file, only transfers within one currency can be made.
+func newExchangeRateProvider(config util.Config) (fx.ExchangeRateProvider, error) {
+	if config.ExchangeRatesFile == "" {
+		return fx.NewStaticProvider(nil)
+	}
+	return fx.NewFileProvider(config.ExchangeRatesFile)
+}
+
+// Start listens on the address and serves HTTP requests until the server is shut down.
 func (server *Server) Start(address string) error {
-	return server.router.Run(address)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return server.Serve(listener)
 }
 
-func errorResponse(err error) gin.H {
-	return gin.H{"error": err.Error()}
+// Serve serves HTTP requests on the listener until the server is shut down.
+// It returns nil once Shutdown was called, without waiting for the shutdown to complete.
+func (server *Server) Serve(listener net.Listener) error {
+	err := server.httpServer.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown fails readiness, then keeps serving for SHUTDOWN_DRAIN_DELAY, so that the load balancer
+// stops routing new traffic here before the listener is closed.
+// It then stops accepting new connections and waits for the in-flight requests to complete.
+// If ctx is done first, the remaining connections are closed and the error of ctx is returned.
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.shuttingDown.Store(true)
+
+	if server.config.ShutdownDrainDelay > 0 {
+		timer := time.NewTimer(server.config.ShutdownDrainDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	err := server.httpServer.Shutdown(ctx)
+	if err != nil {
+		_ = server.httpServer.Close()
+		return err
+	}
+
+	return nil
+}
+
+func durationOrDefault(duration, defaultDuration time.Duration) time.Duration {
+	if duration <= 0 {
+		return defaultDuration
+	}
+	return duration
 }
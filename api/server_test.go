@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/anilbolat/simple-bank/db/mock"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/metrics"
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerShutdownCompletesInFlightRequest(t *testing.T) {
+	// given
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, id int64) (db.Account, error) {
+			close(started)
+			<-release
+			return account, nil
+		})
+
+	server := newTestServer(t, store)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	// a request is in flight
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/accounts/%d", listener.Addr(), account.ID), nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+
+	responseCode := make(chan int, 1)
+	go func() {
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			responseCode <- 0
+			return
+		}
+		defer response.Body.Close()
+		responseCode <- response.StatusCode
+	}()
+	<-started
+
+	// test
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- server.Shutdown(context.Background())
+	}()
+
+	// Serve returns as soon as the shutdown begins, new connections are refused from then on
+	require.NoError(t, <-serveErr)
+	_, err = net.Dial("tcp", listener.Addr().String())
+	require.Error(t, err)
+
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("shutdown completed before the in-flight request: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	// assert
+	require.Equal(t, http.StatusOK, <-responseCode)
+	require.NoError(t, <-shutdownErr)
+}
+
+func TestServerShutdownDeadline(t *testing.T) {
+	// given
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, id int64) (db.Account, error) {
+			close(started)
+			<-release
+			return account, nil
+		})
+
+	server := newTestServer(t, store)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/accounts/%d", listener.Addr(), account.ID), nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+
+	requestErr := make(chan error, 1)
+	go func() {
+		response, err := http.DefaultClient.Do(request)
+		if err == nil {
+			response.Body.Close()
+		}
+		requestErr <- err
+	}()
+	<-started
+
+	// test
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = server.Shutdown(ctx)
+
+	// assert: the request that did not complete in time is cut off
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Error(t, <-requestErr)
+}
+
+func TestServerShutdownDrainDelay(t *testing.T) {
+	// given
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	config := util.Config{
+		TokenSymmetricKey:  util.RandomString(32),
+		CursorSigningKey:   util.RandomString(32),
+		ShutdownDrainDelay: 200 * time.Millisecond,
+	}
+	server, err := NewServer(config, store)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	// test
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- server.Shutdown(context.Background())
+	}()
+
+	// assert: during the drain delay, the server still serves and reports it is not ready
+	require.Eventually(t, server.shuttingDown.Load, time.Second, time.Millisecond)
+	response, err := http.Get(fmt.Sprintf("http://%s/readyz", listener.Addr()))
+	require.NoError(t, err)
+	response.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+
+	require.NoError(t, <-serveErr)
+	require.NoError(t, <-shutdownErr)
+}
+
+func TestMetricsAPI(t *testing.T) {
+	// given
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStore := mockdb.NewMockStore(ctrl)
+	mockStore.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+	mockStore.EXPECT().Stats().AnyTimes().Return(sql.DBStats{})
+
+	m := metrics.New()
+	server := newTestServer(t, m.WrapStore(mockStore), WithMetrics(m))
+	server.router.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/accounts/%d", account.ID), nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+	server.router.ServeHTTP(httptest.NewRecorder(), request)
+
+	request, err = http.NewRequest(http.MethodGet, "/panic", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(httptest.NewRecorder(), request)
+
+	// the metrics are not served by the router
+	recorder := httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// when
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+	m.Handler().ServeHTTP(recorder, request)
+
+	// then
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	require.Contains(t, body, `simple_bank_http_requests_total{code="200",method="GET",route="/accounts/:id"} 1`)
+	require.Contains(t, body, `simple_bank_http_requests_total{code="500",method="GET",route="/panic"} 1`)
+	require.Contains(t, body, `simple_bank_store_operation_duration_seconds_count{method="GetAccount"} 1`)
+}
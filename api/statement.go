@@ -0,0 +1,145 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/export"
+	"github.com/gin-gonic/gin"
+)
+
+// statementRequest is the period of a statement, from inclusive and to exclusive, both in RFC 3339.
+type statementRequest struct {
+	From time.Time `form:"from" binding:"required"`
+	To   time.Time `form:"to" binding:"required,gtfield=From"`
+}
+
+type exportStatementRequest struct {
+	statementRequest
+	// Format is the name of an export format. Without it, the format is negotiated with the Accept header.
+	Format string `form:"format"`
+}
+
+func (server *Server) getAccountStatement(ctx *gin.Context) {
+	var uriReq getAccountRequest
+	err := ctx.ShouldBindUri(&uriReq)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	var req statementRequest
+	err = ctx.ShouldBindQuery(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	// checked first, so that the statement of an account of someone else is not even built
+	_, ok := server.getOwnedAccount(ctx, uriReq.ID)
+	if !ok {
+		return
+	}
+
+	statement, err := server.store.StatementTx(ctx, db.StatementTxParams{
+		AccountID: uriReq.ID,
+		From:      req.From,
+		To:        req.To,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			detail := fmt.Sprintf("account ID %d does not exist", uriReq.ID)
+			respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeAccountNotFound, detail))
+			return
+		}
+
+		errServer := fmt.Errorf("error occurred while building the statement of account ID %d: %w", uriReq.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, statement)
+}
+
+func (server *Server) exportAccountStatement(ctx *gin.Context) {
+	var uriReq getAccountRequest
+	err := ctx.ShouldBindUri(&uriReq)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	var req exportStatementRequest
+	err = ctx.ShouldBindQuery(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	var format export.Format
+	if req.Format != "" {
+		format, err = export.Lookup(req.Format)
+		if err != nil {
+			respondError(ctx, apierror.Wrap(err, http.StatusBadRequest, apierror.CodeUnknownFormat, fmt.Sprintf("unknown export format %q", req.Format)))
+			return
+		}
+	} else {
+		format, err = export.ForContentType(ctx.NegotiateFormat(export.ContentTypes()...))
+		if err != nil {
+			detail := fmt.Sprintf("none of %v is accepted", export.ContentTypes())
+			respondError(ctx, apierror.Wrap(err, http.StatusNotAcceptable, apierror.CodeNotAcceptable, detail))
+			return
+		}
+	}
+
+	_, ok := server.getOwnedAccount(ctx, uriReq.ID)
+	if !ok {
+		return
+	}
+
+	// a large statement takes longer to stream than the write timeout of the other routes allows
+	exportTimeout := durationOrDefault(server.config.StatementExportTimeout, defaultStatementExportTimeout)
+	err = http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Now().Add(exportTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		respondError(ctx, fmt.Errorf("error occurred while extending the write deadline: %w", err))
+		return
+	}
+
+	writer := format.NewWriter(ctx.Writer)
+	// once the statement started, the status is sent and errors can only cut the response short
+	started := false
+
+	err = server.store.StreamStatementTx(ctx, db.StatementTxParams{
+		AccountID: uriReq.ID,
+		From:      req.From,
+		To:        req.To,
+	}, func(summary db.StatementSummary) error {
+		started = true
+		ctx.Header("Content-Type", format.ContentType())
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%d.%s"`, uriReq.ID, format.Name()))
+		ctx.Status(http.StatusOK)
+		return writer.Begin(summary)
+	}, writer.Line)
+	if err == nil {
+		err = writer.End()
+	}
+	if err != nil {
+		switch {
+		case started:
+			slog.ErrorContext(ctx, "export of the statement stopped", "account_id", uriReq.ID, "error", err)
+			ctx.Abort()
+		case errors.Is(err, sql.ErrNoRows):
+			detail := fmt.Sprintf("account ID %d does not exist", uriReq.ID)
+			respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeAccountNotFound, detail))
+		default:
+			errServer := fmt.Errorf("error occurred while exporting the statement of account ID %d: %w", uriReq.ID, err)
+			respondError(ctx, errServer)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	"github.com/anilbolat/simple-bank/token"
+	"github.com/gin-gonic/gin"
+)
+
+type renewAccessTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type renewAccessTokenResponse struct {
+	AccessToken          string    `json:"access_token"`
+	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+}
+
+// renewAccessToken creates a new access token for the session of a refresh token.
+// The role is read again from the user rather than copied from the refresh token, so that a change of role
+// takes effect on the next renewal.
+func (server *Server) renewAccessToken(ctx *gin.Context) {
+	var req renewAccessTokenRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	refreshPayload, err := server.tokenMaker.VerifyToken(req.RefreshToken, token.TokenTypeRefreshToken)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	session, err := server.store.GetSession(ctx, refreshPayload.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			detail := fmt.Sprintf("session %s does not exist", refreshPayload.ID)
+			respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeSessionNotFound, detail))
+			return
+		}
+
+		errServer := fmt.Errorf("error occurred for session %s: %w", refreshPayload.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	var sessionDetail string
+	switch {
+	case session.IsBlocked:
+		sessionDetail = fmt.Sprintf("session %s is blocked", session.ID)
+	case session.Username != refreshPayload.Username:
+		sessionDetail = fmt.Sprintf("session %s belongs to another user", session.ID)
+	case session.RefreshToken != req.RefreshToken:
+		sessionDetail = fmt.Sprintf("session %s has a mismatched refresh token", session.ID)
+	case time.Now().After(session.ExpiresAt):
+		sessionDetail = fmt.Sprintf("session %s has expired", session.ID)
+	}
+	if sessionDetail != "" {
+		respondError(ctx, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidSession, sessionDetail))
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, session.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			detail := fmt.Sprintf("user of session %s does not exist", session.ID)
+			respondError(ctx, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidSession, detail))
+			return
+		}
+
+		errServer := fmt.Errorf("error occurred for user %s: %w", session.Username, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(
+		user.Username,
+		user.Role,
+		server.config.AccessTokenDuration,
+		token.TokenTypeAccessToken,
+	)
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while creating access token for user %s: %w", refreshPayload.Username, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, renewAccessTokenResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessPayload.ExpiredAt,
+	})
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/fx"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+type transferRequest struct {
+	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount        int64  `json:"amount" binding:"required,gt=0"`
+	Currency      string `json:"currency" binding:"required,currency"`
+	// Convert allows a transfer to an account of another currency, at the current exchange rate.
+	Convert bool `json:"convert"`
+}
+
+func (server *Server) createTransfer(ctx *gin.Context) {
+	var req transferRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	idempotencyKey := ctx.GetHeader(idempotencyKeyHeader)
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		detail := fmt.Sprintf("%s header must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)
+		respondError(ctx, apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, detail))
+		return
+	}
+
+	fromAccount, valid := server.validAccount(ctx, req.FromAccountID, req.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := getAuthPayload(ctx)
+	if fromAccount.Owner != authPayload.Username {
+		respondError(ctx, errUnauthorizedAccount)
+		return
+	}
+
+	arg := db.TransferTxParams{
+		FromAccountID:  req.FromAccountID,
+		ToAccountID:    req.ToAccountID,
+		Amount:         req.Amount,
+		IdempotencyKey: idempotencyKey,
+		Username:       authPayload.Username,
+	}
+
+	if req.Convert {
+		toAccount, valid := server.validAccount(ctx, req.ToAccountID, "")
+		if !valid {
+			return
+		}
+
+		if toAccount.Currency != req.Currency {
+			arg.ToAmount, arg.ExchangeRate, valid = server.convertAmount(ctx, req.Amount, req.Currency, toAccount.Currency)
+			if !valid {
+				return
+			}
+		}
+	} else {
+		_, valid = server.validAccount(ctx, req.ToAccountID, req.Currency)
+		if !valid {
+			return
+		}
+	}
+
+	result, err := server.store.TransferTx(ctx, arg)
+	if err != nil {
+		if errors.Is(err, db.ErrIdempotencyKeyReused) {
+			detail := fmt.Sprintf("%s %s was already used for a different request", idempotencyKeyHeader, idempotencyKey)
+			respondError(ctx, apierror.Wrap(err, http.StatusConflict, apierror.CodeIdempotencyKeyReused, detail))
+			return
+		}
+
+		if errors.Is(err, db.ErrInsufficientFunds) {
+			detail := fmt.Sprintf("account ID %d has insufficient funds", req.FromAccountID)
+			respondError(ctx, apierror.Wrap(err, http.StatusUnprocessableEntity, apierror.CodeInsufficientFunds, detail))
+			return
+		}
+
+		if errors.Is(err, db.ErrCurrencyMismatch) {
+			detail := fmt.Sprintf("currency mismatch between account ID %d and account ID %d", req.FromAccountID, req.ToAccountID)
+			respondError(ctx, apierror.Wrap(err, http.StatusBadRequest, apierror.CodeCurrencyMismatch, detail))
+			return
+		}
+
+		errServer := fmt.Errorf("error occurred while transferring from account ID %d to account ID %d: %w",
+			req.FromAccountID, req.ToAccountID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	if result.Replayed {
+		ctx.Header(idempotentReplayedHeader, "true")
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// validAccount checks that the account exists and that its currency matches the given one, if any.
+// It writes the error response itself and returns false if the account cannot be used.
+func (server *Server) validAccount(ctx *gin.Context, accountID int64, currency string) (db.Account, bool) {
+	account, err := server.store.GetAccount(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			detail := fmt.Sprintf("account ID %d does not exist", accountID)
+			respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeAccountNotFound, detail))
+			return account, false
+		}
+
+		errServer := fmt.Errorf("error occurred for account ID %d: %w", accountID, err)
+		respondError(ctx, errServer)
+		return account, false
+	}
+
+	if currency != "" && account.Currency != currency {
+		detail := fmt.Sprintf("account ID %d currency mismatch: %s vs %s", accountID, account.Currency, currency)
+		respondError(ctx, apierror.New(http.StatusBadRequest, apierror.CodeCurrencyMismatch, detail))
+		return account, false
+	}
+
+	return account, true
+}
+
+// convertAmount converts the amount with the current exchange rate between the currencies.
+// It writes the error response itself and returns false if the amount cannot be converted.
+func (server *Server) convertAmount(ctx *gin.Context, amount int64, from string, to string) (int64, string, bool) {
+	rate, err := server.rates.Rate(ctx, from, to)
+	if err != nil {
+		if errors.Is(err, fx.ErrRateNotFound) {
+			respondError(ctx, err)
+			return 0, "", false
+		}
+
+		errServer := fmt.Errorf("error occurred while getting exchange rate %s/%s: %w", from, to, err)
+		respondError(ctx, errServer)
+		return 0, "", false
+	}
+
+	toAmount, err := fx.Convert(amount, rate)
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while converting %d %s to %s: %w", amount, from, to, err)
+		respondError(ctx, errServer)
+		return 0, "", false
+	}
+
+	if toAmount <= 0 {
+		detail := fmt.Sprintf("amount %d %s is too small to be converted to %s", amount, from, to)
+		respondError(ctx, apierror.New(http.StatusBadRequest, apierror.CodeAmountTooSmall, detail))
+		return 0, "", false
+	}
+
+	return toAmount, rate.Value, true
+}
+
+type listTransfersRequest struct {
+	pageRequest
+}
+
+type listTransfersResponse struct {
+	Transfers  []db.Transfer `json:"transfers"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// listTransfers lists the transfers from and to the account.
+func (server *Server) listTransfers(ctx *gin.Context) {
+	var uriReq getAccountRequest
+	err := ctx.ShouldBindUri(&uriReq)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	var req listTransfersRequest
+	err = ctx.ShouldBindQuery(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	if _, valid := server.getOwnedAccount(ctx, uriReq.ID); !valid {
+		return
+	}
+
+	if req.byOffset() {
+		transfers, err := server.store.ListTransfers(ctx, db.ListTransfersParams{
+			FromAccountID: uriReq.ID,
+			ToAccountID:   uriReq.ID,
+			Limit:         req.PageSize,
+			Offset:        req.offset(),
+		})
+		if err != nil {
+			errServer := fmt.Errorf("error occurred while listing transfers of account ID %d: %w", uriReq.ID, err)
+			respondError(ctx, errServer)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, transfers)
+		return
+	}
+
+	scope := fmt.Sprintf("transfers:%d", uriReq.ID)
+	after, err := server.after(req.pageRequest, scope)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	transfers, err := server.store.ListTransfersAfter(ctx, db.ListTransfersAfterParams{
+		FromAccountID:  uriReq.ID,
+		ToAccountID:    uriReq.ID,
+		AfterCreatedAt: after.CreatedAt,
+		AfterID:        after.ID,
+		Limit:          req.keysetLimit(),
+	})
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while listing transfers of account ID %d: %w", uriReq.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	rsp := listTransfersResponse{Transfers: transfers}
+	if len(transfers) > int(req.PageSize) {
+		rsp.Transfers = transfers[:req.PageSize]
+		last := rsp.Transfers[req.PageSize-1]
+		rsp.NextCursor, err = server.nextCursor(scope, last.CreatedAt, last.ID)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
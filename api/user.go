@@ -0,0 +1,209 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/token"
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type createUserRequest struct {
+	Username string `json:"username" binding:"required,alphanum"`
+	Password string `json:"password" binding:"required,min=6"`
+	FullName string `json:"full_name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+}
+
+// userResponse is the public view of a user, it never contains the hashed password.
+type userResponse struct {
+	Username          string    `json:"username"`
+	FullName          string    `json:"full_name"`
+	Email             string    `json:"email"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+func newUserResponse(user db.User) userResponse {
+	return userResponse{
+		Username:          user.Username,
+		FullName:          user.FullName,
+		Email:             user.Email,
+		PasswordChangedAt: user.PasswordChangedAt,
+		CreatedAt:         user.CreatedAt,
+	}
+}
+
+func (server *Server) createUser(ctx *gin.Context) {
+	var req createUserRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(req.Password)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	// a user signing up creates themselves
+	setAuditActor(ctx, req.Username)
+	user, err := server.store.CreateUser(ctx, db.CreateUserParams{
+		Username:       req.Username,
+		HashedPassword: hashedPassword,
+		FullName:       req.FullName,
+		Email:          req.Email,
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			detail := fmt.Sprintf("username %s or email %s already exists", req.Username, req.Email)
+			respondError(ctx, apierror.New(http.StatusForbidden, apierror.CodeUserAlreadyExists, detail))
+			return
+		}
+
+		errServer := fmt.Errorf("error occurred while creating user %s: %w", req.Username, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
+
+type loginUserRequest struct {
+	Username string `json:"username" binding:"required,alphanum"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type loginUserResponse struct {
+	SessionID             uuid.UUID    `json:"session_id"`
+	AccessToken           string       `json:"access_token"`
+	AccessTokenExpiresAt  time.Time    `json:"access_token_expires_at"`
+	RefreshToken          string       `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+	User                  userResponse `json:"user"`
+}
+
+// unknownUserPasswordHash is the bcrypt hash checked against when the username of a login does not exist.
+const unknownUserPasswordHash = "$2a$10$RFjsz7GxkglJOd2524JfMumKV1/eP01JeVdTXVUF3cetZGmhN.iFW"
+
+// errInvalidCredentials is the same for an unknown username and a wrong password,
+// so that a login does not tell which usernames exist.
+var errInvalidCredentials = apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "incorrect username or password")
+
+// loginUser creates a session for the user, with an access and a refresh token.
+func (server *Server) loginUser(ctx *gin.Context) {
+	var req loginUserRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, req.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// a password is still checked, so an unknown username takes as long as a wrong password
+			_ = util.CheckPassword(req.Password, unknownUserPasswordHash)
+			respondError(ctx, errInvalidCredentials)
+			return
+		}
+
+		errServer := fmt.Errorf("error occurred for user %s: %w", req.Username, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	err = util.CheckPassword(req.Password, user.HashedPassword)
+	if err != nil {
+		respondError(ctx, errInvalidCredentials)
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(
+		user.Username,
+		user.Role,
+		server.config.AccessTokenDuration,
+		token.TokenTypeAccessToken,
+	)
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while creating access token for user %s: %w", req.Username, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(
+		user.Username,
+		user.Role,
+		server.config.RefreshTokenDuration,
+		token.TokenTypeRefreshToken,
+	)
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while creating refresh token for user %s: %w", req.Username, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	setAuditActor(ctx, user.Username)
+	session, err := server.store.CreateSession(ctx, db.CreateSessionParams{
+		ID:           refreshPayload.ID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    ctx.Request.UserAgent(),
+		ClientIp:     ctx.ClientIP(),
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	})
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while creating session for user %s: %w", req.Username, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, loginUserResponse{
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+		User:                  newUserResponse(user),
+	})
+}
+
+type revokeUserSessionsRequest struct {
+	Username string `uri:"username" binding:"required,alphanum"`
+}
+
+type revokeUserSessionsResponse struct {
+	RevokedSessions int64 `json:"revoked_sessions"`
+}
+
+// revokeUserSessions blocks every session of a user, so none of their refresh tokens can be renewed anymore.
+// Access tokens already issued stay valid until they expire.
+func (server *Server) revokeUserSessions(ctx *gin.Context) {
+	var req revokeUserSessionsRequest
+	err := ctx.ShouldBindUri(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	revoked, err := server.store.BlockUserSessions(ctx, req.Username)
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while revoking sessions of user %s: %w", req.Username, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, revokeUserSessionsResponse{RevokedSessions: revoked})
+}
@@ -12,11 +12,12 @@ func getAccountFromResponseBody(resBody *bytes.Buffer, account *db.Account) erro
 	return json.NewDecoder(resBody).Decode(account)
 }
 
-func randomAccount() db.Account {
+func randomAccount(owner string) db.Account {
 	return db.Account{
 		ID:       util.RandomInt(1, 1000),
-		Owner:    util.RandomOwner(),
+		Owner:    owner,
 		Balance:  util.RandomMoney(),
 		Currency: util.RandomCurrency(),
+		Status:   db.AccountStatusActive,
 	}
 }
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/anilbolat/simple-bank/webhook"
+	"github.com/go-playground/validator/v10"
+)
+
+var validCurrency validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	if currency, ok := fieldLevel.Field().Interface().(string); ok {
+		return util.IsSupportedCurrency(currency)
+	}
+	return false
+}
+
+var validWebhookURL validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	if webhookURL, ok := fieldLevel.Field().Interface().(string); ok {
+		return webhook.CheckURL(webhookURL) == nil
+	}
+	return false
+}
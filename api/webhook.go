@@ -0,0 +1,250 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anilbolat/simple-bank/apierror"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+var errUnauthorizedWebhook = apierror.New(http.StatusUnauthorized, apierror.CodeWebhookNotOwned,
+	"webhook subscription does not belong to the authenticated user")
+
+// webhookResponse is a webhook subscription without its secret, which is only sent once, when it is created.
+type webhookResponse struct {
+	ID         int64     `json:"id"`
+	Owner      string    `json:"owner"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newWebhookResponse(subscription db.WebhookSubscription) webhookResponse {
+	return webhookResponse{
+		ID:         subscription.ID,
+		Owner:      subscription.Owner,
+		URL:        subscription.Url,
+		EventTypes: subscription.EventTypes,
+		CreatedAt:  subscription.CreatedAt,
+	}
+}
+
+type createWebhookRequest struct {
+	// URL must be https, and must not point to a loopback, link-local or private address.
+	URL        string   `json:"url" binding:"required,max=2048,webhook_url"`
+	EventTypes []string `json:"event_types" binding:"required,min=1,unique,dive,oneof=TransferCompleted AccountCreated BalanceChanged"`
+}
+
+type createWebhookResponse struct {
+	webhookResponse
+	// Secret signs the deliveries, receivers verify the signature with it.
+	Secret string `json:"secret"`
+}
+
+// createWebhook subscribes the authenticated user to the events of their accounts.
+// The events are posted to the URL, signed with a secret sent in the response only.
+func (server *Server) createWebhook(ctx *gin.Context) {
+	var req createWebhookRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while creating a webhook secret: %w", err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	authPayload := getAuthPayload(ctx)
+	subscription, err := server.store.CreateWebhookSubscription(ctx, db.CreateWebhookSubscriptionParams{
+		Owner:      authPayload.Username,
+		Url:        req.URL,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+	})
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while creating a webhook for %s: %w", authPayload.Username, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, createWebhookResponse{
+		webhookResponse: newWebhookResponse(subscription),
+		Secret:          subscription.Secret,
+	})
+}
+
+type getWebhookRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) getWebhook(ctx *gin.Context) {
+	var req getWebhookRequest
+	err := ctx.ShouldBindUri(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	subscription, valid := server.getOwnedWebhook(ctx, req.ID)
+	if !valid {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newWebhookResponse(subscription))
+}
+
+func (server *Server) listWebhooks(ctx *gin.Context) {
+	authPayload := getAuthPayload(ctx)
+
+	subscriptions, err := server.store.ListWebhookSubscriptions(ctx, authPayload.Username)
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while listing webhooks of %s: %w", authPayload.Username, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	rsp := make([]webhookResponse, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		rsp = append(rsp, newWebhookResponse(subscription))
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// deleteWebhook unsubscribes from the events, the deliveries not sent yet are dropped with the delivery log.
+func (server *Server) deleteWebhook(ctx *gin.Context) {
+	var req getWebhookRequest
+	err := ctx.ShouldBindUri(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	if _, valid := server.getOwnedWebhook(ctx, req.ID); !valid {
+		return
+	}
+
+	err = server.store.DeleteWebhookSubscription(ctx, req.ID)
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while deleting webhook ID %d: %w", req.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+type listWebhookDeliveriesRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+// listWebhookDeliveries lists the delivery log of a webhook, the latest first.
+func (server *Server) listWebhookDeliveries(ctx *gin.Context) {
+	var uriReq getWebhookRequest
+	err := ctx.ShouldBindUri(&uriReq)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	var req listWebhookDeliveriesRequest
+	err = ctx.ShouldBindQuery(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	if _, valid := server.getOwnedWebhook(ctx, uriReq.ID); !valid {
+		return
+	}
+
+	deliveries, err := server.store.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{
+		SubscriptionID: uriReq.ID,
+		Limit:          req.PageSize,
+		Offset:         (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while listing deliveries of webhook ID %d: %w", uriReq.ID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, deliveries)
+}
+
+type replayWebhookDeliveryRequest struct {
+	ID         int64 `uri:"id" binding:"required,min=1"`
+	DeliveryID int64 `uri:"delivery_id" binding:"required,min=1"`
+}
+
+// replayWebhookDelivery sends a delivery again, with a fresh set of attempts, whether it succeeded or failed before.
+func (server *Server) replayWebhookDelivery(ctx *gin.Context) {
+	var req replayWebhookDeliveryRequest
+	err := ctx.ShouldBindUri(&req)
+	if err != nil {
+		respondInvalid(ctx, err)
+		return
+	}
+
+	if _, valid := server.getOwnedWebhook(ctx, req.ID); !valid {
+		return
+	}
+
+	delivery, err := server.store.GetWebhookDelivery(ctx, req.DeliveryID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		errServer := fmt.Errorf("error occurred for webhook delivery ID %d: %w", req.DeliveryID, err)
+		respondError(ctx, errServer)
+		return
+	}
+	if err != nil || delivery.SubscriptionID != req.ID {
+		detail := fmt.Sprintf("webhook ID %d has no delivery ID %d", req.ID, req.DeliveryID)
+		respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeWebhookDeliveryNotFound, detail))
+		return
+	}
+
+	// a delivery being attempted holds the row lock, the replay waits for the attempt to be recorded
+	delivery, err = server.store.ReplayWebhookDelivery(ctx, req.DeliveryID)
+	if err != nil {
+		errServer := fmt.Errorf("error occurred while replaying webhook delivery ID %d: %w", req.DeliveryID, err)
+		respondError(ctx, errServer)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, delivery)
+}
+
+// getOwnedWebhook gets the webhook subscription and checks that it belongs to the authenticated user.
+// It writes the error response itself and returns false if the subscription cannot be used.
+func (server *Server) getOwnedWebhook(ctx *gin.Context, id int64) (db.WebhookSubscription, bool) {
+	subscription, err := server.store.GetWebhookSubscription(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			detail := fmt.Sprintf("webhook ID %d does not exist", id)
+			respondError(ctx, apierror.New(http.StatusNotFound, apierror.CodeWebhookNotFound, detail))
+			return subscription, false
+		}
+
+		errServer := fmt.Errorf("error occurred for webhook ID %d: %w", id, err)
+		respondError(ctx, errServer)
+		return subscription, false
+	}
+
+	authPayload := getAuthPayload(ctx)
+	if subscription.Owner != authPayload.Username {
+		respondError(ctx, errUnauthorizedWebhook)
+		return subscription, false
+	}
+
+	return subscription, true
+}
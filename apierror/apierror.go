@@ -0,0 +1,97 @@
+// Package apierror defines the errors of the API. Each has a stable code clients can switch on,
+// and is sent as RFC 7807 problem details.
+package apierror
+
+import (
+	"net/http"
+)
+
+// ContentType is the media type of problem details.
+const ContentType = "application/problem+json"
+
+// Code identifies the kind of an error. Codes never change once released, unlike the details.
+type Code string
+
+const (
+	CodeValidationFailed           Code = "validation_failed"
+	CodeMalformedRequest           Code = "malformed_request"
+	CodeUnauthenticated            Code = "unauthenticated"
+	CodeInvalidToken               Code = "invalid_token"
+	CodeTokenExpired               Code = "token_expired"
+	CodeInvalidSession             Code = "invalid_session"
+	CodeForbidden                  Code = "forbidden"
+	CodeNotFound                   Code = "not_found"
+	CodeMethodNotAllowed           Code = "method_not_allowed"
+	CodeUserAlreadyExists          Code = "user_already_exists"
+	CodeInvalidCredentials         Code = "invalid_credentials"
+	CodeSessionNotFound            Code = "session_not_found"
+	CodeAccountNotFound            Code = "account_not_found"
+	CodeAccountNotOwned            Code = "account_not_owned"
+	CodeAccountAlreadyExists       Code = "account_already_exists"
+	CodeAccountNotActive           Code = "account_not_active"
+	CodeInvalidStatusTransition    Code = "invalid_status_transition"
+	CodeAccountBalanceNotZero      Code = "account_balance_not_zero"
+	CodeOwnerNotFound              Code = "owner_not_found"
+	CodeCurrencyMismatch           Code = "currency_mismatch"
+	CodeInsufficientFunds          Code = "insufficient_funds"
+	CodeIdempotencyKeyReused       Code = "idempotency_key_reused"
+	CodeTransferNotFound           Code = "transfer_not_found"
+	CodeTransferNotOwned           Code = "transfer_not_owned"
+	CodeTransferAlreadyReversed    Code = "transfer_already_reversed"
+	CodeInvalidReversalAmount      Code = "invalid_reversal_amount"
+	CodeReversalNotReversible      Code = "reversal_not_reversible"
+	CodeScheduledTransferNotFound  Code = "scheduled_transfer_not_found"
+	CodeScheduledTransferNotOwned  Code = "scheduled_transfer_not_owned"
+	CodeScheduledTransferNotActive Code = "scheduled_transfer_not_active"
+	CodeInvalidSchedule            Code = "invalid_schedule"
+	CodeWebhookNotFound            Code = "webhook_not_found"
+	CodeWebhookNotOwned            Code = "webhook_not_owned"
+	CodeWebhookDeliveryNotFound    Code = "webhook_delivery_not_found"
+	CodeExchangeRateNotFound       Code = "exchange_rate_not_found"
+	CodeAmountTooSmall             Code = "amount_too_small"
+	CodeInvalidCursor              Code = "invalid_cursor"
+	CodeUnknownFormat              Code = "unknown_format"
+	CodeNotAcceptable              Code = "not_acceptable"
+	CodeInternal                   Code = "internal_error"
+)
+
+// internalDetail is the only detail of an internal error sent to clients, the cause is only logged.
+const internalDetail = "internal server error"
+
+// Error is an error of the API with the status and the code it is sent with.
+type Error struct {
+	Status int
+	Code   Code
+	// Detail explains the error to the client. It must not reveal internals.
+	Detail string
+	// Fields are the invalid fields of a request that failed validation.
+	Fields []FieldError
+	// Err is the cause of the error. It is logged but never sent.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates an error sent with the status, the code and the detail.
+func New(status int, code Code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// Wrap creates an error sent with the status, the code and the detail, keeping err as its cause.
+func Wrap(err error, status int, code Code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail, Err: err}
+}
+
+// Internal creates an internal server error, which does not tell the client anything about err.
+func Internal(err error) *Error {
+	return Wrap(err, http.StatusInternalServerError, CodeInternal, internalDetail)
+}
@@ -0,0 +1,55 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalid(t *testing.T) {
+	type request struct {
+		Amount   int64  `json:"amount" validate:"required,gt=0"`
+		Currency string `json:"currency" validate:"required"`
+	}
+
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("json")
+	})
+	err := validate.Struct(request{Amount: -1})
+
+	apiErr := Invalid(err)
+	require.Equal(t, http.StatusBadRequest, apiErr.Status)
+	require.Equal(t, CodeValidationFailed, apiErr.Code)
+	require.Equal(t, []FieldError{
+		{Field: "amount", Rule: "gt", Message: "must be greater than 0"},
+		{Field: "currency", Rule: "required", Message: "is required"},
+	}, apiErr.Fields)
+
+	err = json.Unmarshal([]byte(`{"amount":`), &request{})
+	apiErr = Invalid(err)
+	require.Equal(t, http.StatusBadRequest, apiErr.Status)
+	require.Equal(t, CodeMalformedRequest, apiErr.Code)
+	require.Empty(t, apiErr.Fields)
+}
+
+func TestProblem(t *testing.T) {
+	apiErr := Wrap(errors.New("currency mismatch"), http.StatusBadRequest, CodeCurrencyMismatch, "currency mismatch between account ID 1 and account ID 2")
+
+	data, err := json.Marshal(apiErr.Problem("/transfers", "abc-123"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "currency mismatch between account ID 1 and account ID 2",
+		"instance": "/transfers",
+		"code": "currency_mismatch",
+		"request_id": "abc-123"
+	}`, string(data))
+}
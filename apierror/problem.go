@@ -0,0 +1,31 @@
+package apierror
+
+import "net/http"
+
+// Problem is the body of an error response, as RFC 7807 problem details
+// extended with the code, the request ID and the invalid fields.
+type Problem struct {
+	// Type is about:blank, the problem being identified by Code instead.
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// Problem returns the problem details of the error that occurred at instance, the path of the request.
+func (e *Error) Problem(instance string, requestID string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Detail,
+		Instance:  instance,
+		Code:      e.Code,
+		RequestID: requestID,
+		Errors:    e.Fields,
+	}
+}
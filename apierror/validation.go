@@ -0,0 +1,79 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError tells why a field of a request is invalid.
+type FieldError struct {
+	// Field is the name of the field in the request, like from_account_id.
+	Field string `json:"field"`
+	// Rule is the validation rule the field broke, like required.
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Invalid creates the error of a request that could not be bound.
+// Failed validations are detailed per field, a body that is not valid JSON is a malformed request.
+func Invalid(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, FieldError{
+				Field:   fieldErr.Field(),
+				Rule:    fieldErr.Tag(),
+				Message: fieldMessage(fieldErr),
+			})
+		}
+
+		apiErr := Wrap(err, http.StatusBadRequest, CodeValidationFailed, "the request has invalid fields")
+		apiErr.Fields = fields
+		return apiErr
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	// a body cut short or missing fails with an EOF
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return Wrap(err, http.StatusBadRequest, CodeMalformedRequest, err.Error())
+	}
+
+	return Wrap(err, http.StatusBadRequest, CodeValidationFailed, err.Error())
+}
+
+// fieldMessage describes the rule a field broke.
+func fieldMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %s", fieldErr.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fieldErr.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fieldErr.Param())
+	case "gtfield":
+		return fmt.Sprintf("must be greater than %s", fieldErr.Param())
+	case "nefield":
+		return fmt.Sprintf("must differ from %s", fieldErr.Param())
+	case "excluded_with":
+		return fmt.Sprintf("must not be given together with %s", fieldErr.Param())
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "email":
+		return "must be an email address"
+	case "currency":
+		return "is not a supported currency"
+	case "webhook_url":
+		return "must be an https URL that does not point to a loopback, link-local or private address"
+	default:
+		return fmt.Sprintf("is invalid (%s)", fieldErr.Tag())
+	}
+}
@@ -1,33 +1,413 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/anilbolat/simple-bank/util"
 
 	"github.com/anilbolat/simple-bank/api"
 	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/logging"
+	"github.com/anilbolat/simple-bank/metrics"
+	"github.com/anilbolat/simple-bank/outbox"
+	"github.com/anilbolat/simple-bank/tracing"
+	"github.com/anilbolat/simple-bank/webhook"
+	"github.com/anilbolat/simple-bank/worker"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
 	_ "github.com/lib/pq"
 )
 
+const (
+	// defaultShutdownTimeout is how long in-flight requests get to complete if SHUTDOWN_TIMEOUT is not set.
+	defaultShutdownTimeout = 30 * time.Second
+	// metricsReadHeaderTimeout is how long a scraper gets to send the headers of its request.
+	metricsReadHeaderTimeout = 5 * time.Second
+	// tracingShutdownTimeout is how long the spans still buffered get to be exported on exit.
+	tracingShutdownTimeout = 5 * time.Second
+	// defaultHoldExpiryInterval is how often expired holds are released if HOLD_EXPIRY_INTERVAL is not set.
+	defaultHoldExpiryInterval = time.Minute
+	// defaultScheduledTransferInterval is how often due scheduled transfers are run if SCHEDULED_TRANSFER_INTERVAL is not set.
+	defaultScheduledTransferInterval = time.Minute
+	// defaultOutboxRelayInterval is how often the outbox events are published if OUTBOX_RELAY_INTERVAL is not set.
+	defaultOutboxRelayInterval = time.Second
+	// defaultWebhookDeliveryInterval is how often due webhooks are delivered if WEBHOOK_DELIVERY_INTERVAL is not set.
+	defaultWebhookDeliveryInterval = 5 * time.Second
+	// defaultWebhookTimeout is how long a webhook receiver gets to respond if WEBHOOK_TIMEOUT is not set.
+	defaultWebhookTimeout = 10 * time.Second
+	// defaultAuditChainInterval is how often the new audit log entries are chained if AUDIT_CHAIN_INTERVAL is not set.
+	defaultAuditChainInterval = time.Second
+	// defaultReconciliationInterval is how often the ledger is reconciled if RECONCILIATION_INTERVAL is not set.
+	defaultReconciliationInterval = time.Hour
+	// missedRunIntervals is how many intervals of the worker a scheduled run may be late before it counts as missed.
+	missedRunIntervals = 2
+)
+
 func main() {
 	config, err := util.LoadConfig(".")
 	if err != nil {
-		log.Fatal("error while loading the config file.")
+		slog.Error("error while loading the config file", "error", err)
+		os.Exit(1)
+	}
+
+	logger, err := logging.New(os.Stdout, config.LogLevel, config.LogFormat)
+	if err != nil {
+		slog.Error("cannot create logger", "error", err)
+		os.Exit(1)
+	}
+	// also routes the lines of the standard log package through the logger
+	slog.SetDefault(logger)
+
+	if len(os.Args) > 1 {
+		err = runCommand(config, os.Args[1])
+		if err != nil {
+			slog.Error("command failed", "command", os.Args[1], "error", err)
+			os.Exit(1)
+		}
+		return
 	}
 
+	err = run(config)
+	if err != nil {
+		slog.Error("server stopped with an error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run serves until SIGINT or SIGTERM, then drains the in-flight requests and closes the db.
+func run(config util.Config) error {
 	conn, err := sql.Open(config.DBDriver, config.DBSource)
 	if err != nil {
-		log.Fatal("cannot connect to db", err)
+		return fmt.Errorf("cannot connect to db: %w", err)
+	}
+	// closed last, after the in-flight requests are done with it
+	defer closeDB(conn)
+
+	// the spans go to stderr, so that they do not interleave with the JSON logs on stdout
+	tracerProvider, err := tracing.NewProvider(config.TracingExporter, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("cannot create tracer provider: %w", err)
+	}
+	// flushes the spans of the last requests, after the server is shut down
+	defer shutdownTracing(tracerProvider)
+
+	m := metrics.New()
+	store := m.WrapStore(db.NewStore(conn, db.WithTracerProvider(tracerProvider)))
+	server, err := api.NewServer(config, store, api.WithMetrics(m), api.WithTracerProvider(tracerProvider))
+	if err != nil {
+		return fmt.Errorf("cannot create server: %w", err)
+	}
+
+	publisher, err := outbox.NewPublisher(config.OutboxPublisher, config.OutboxFile)
+	if err != nil {
+		return fmt.Errorf("cannot create outbox publisher: %w", err)
+	}
+	// the events are always enqueued for the webhooks, and also published with the configured publisher if any
+	publishers := outbox.MultiPublisher{webhook.NewPublisher(store)}
+	if publisher != nil {
+		publishers = append(publishers, publisher)
+	}
+	// closed after the workers are done with it
+	defer closePublisher(publishers)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the workers are stopped and waited for on return, before the db is closed
+	var workers sync.WaitGroup
+	defer workers.Wait()
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	err = startWorkers(workerCtx, &workers, config, store, publishers)
+	if err != nil {
+		return fmt.Errorf("cannot start workers: %w", err)
+	}
+
+	serverErr := make(chan error, 2)
+	go func() {
+		serverErr <- server.Start(config.ServerAddress)
+	}()
+	// the metrics are served on a listener of their own, which is not exposed publicly like SERVER_ADDRESS
+	var metricsServer *http.Server
+	if config.MetricsAddress != "" {
+		metricsServer = &http.Server{Addr: config.MetricsAddress, Handler: m.Handler(), ReadHeaderTimeout: metricsReadHeaderTimeout}
+		go func() {
+			err := metricsServer.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- fmt.Errorf("metrics: %w", err)
+			}
+		}()
+	}
+
+	select {
+	case err = <-serverErr:
+		return fmt.Errorf("cannot start server: %w", err)
+	case <-ctx.Done():
+		// restore the default behavior, so that a second signal stops the app right away
+		stop()
+		slog.Info("shutting down the server")
+	}
+
+	shutdownTimeout := config.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("cannot shut down the server gracefully: %w", err)
+	}
+	// stopped after the server, so that the requests of the drain can still be scraped
+	if metricsServer != nil {
+		_ = metricsServer.Shutdown(shutdownCtx)
+	}
+
+	slog.Info("server stopped")
+	return nil
+}
+
+// startWorkers runs the background jobs until ctx is done.
+func startWorkers(ctx context.Context, workers *sync.WaitGroup, config util.Config, store db.Store, publisher outbox.Publisher) error {
+	holdExpiryInterval := config.HoldExpiryInterval
+	if holdExpiryInterval <= 0 {
+		holdExpiryInterval = defaultHoldExpiryInterval
+	}
+
+	catchUp := config.ScheduledTransferCatchUp
+	if catchUp == "" {
+		catchUp = db.CatchUpOnce
+	}
+	if !db.IsCatchUpPolicy(catchUp) {
+		return fmt.Errorf("SCHEDULED_TRANSFER_CATCH_UP %q: %w", catchUp, db.ErrInvalidCatchUpPolicy)
+	}
+
+	scheduledTransferInterval := config.ScheduledTransferInterval
+	if scheduledTransferInterval <= 0 {
+		scheduledTransferInterval = defaultScheduledTransferInterval
+	}
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		worker.Run(ctx, "expire_holds", holdExpiryInterval, func(ctx context.Context) error {
+			expired, err := store.ExpireHolds(ctx, time.Now())
+			if expired > 0 {
+				slog.InfoContext(ctx, "expired holds", "count", expired)
+			}
+			return err
+		})
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		worker.Run(ctx, "run_scheduled_transfers", scheduledTransferInterval, func(ctx context.Context) error {
+			runs, err := store.RunScheduledTransfers(ctx, db.RunScheduledTransfersParams{
+				Now:         time.Now(),
+				CatchUp:     catchUp,
+				MissedAfter: missedRunIntervals * scheduledTransferInterval,
+				// a run that failed unexpectedly is retried on the next tick
+				Lease: scheduledTransferInterval,
+			})
+			if runs > 0 {
+				slog.InfoContext(ctx, "ran scheduled transfers", "count", runs)
+			}
+			return err
+		})
+	}()
+
+	outboxRelayInterval := config.OutboxRelayInterval
+	if outboxRelayInterval <= 0 {
+		outboxRelayInterval = defaultOutboxRelayInterval
+	}
+
+	relay := outbox.NewRelay(store, publisher, outbox.DefaultBatchSize)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		worker.Run(ctx, "relay_outbox_events", outboxRelayInterval, func(ctx context.Context) error {
+			published, err := relay.Run(ctx)
+			if published > 0 {
+				slog.DebugContext(ctx, "published outbox events", "count", published)
+			}
+			return err
+		})
+	}()
+
+	webhookDeliveryInterval := config.WebhookDeliveryInterval
+	if webhookDeliveryInterval <= 0 {
+		webhookDeliveryInterval = defaultWebhookDeliveryInterval
 	}
 
-	store := db.NewStore(conn)
-	server := api.NewServer(store)
+	webhookTimeout := config.WebhookTimeout
+	if webhookTimeout <= 0 {
+		webhookTimeout = defaultWebhookTimeout
+	}
+
+	dispatcher := webhook.NewDispatcher(store, webhookTimeout)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		worker.Run(ctx, "deliver_webhooks", webhookDeliveryInterval, func(ctx context.Context) error {
+			attempts, err := dispatcher.Run(ctx)
+			if attempts > 0 {
+				slog.InfoContext(ctx, "attempted webhook deliveries", "count", attempts)
+			}
+			return err
+		})
+	}()
+
+	auditChainInterval := config.AuditChainInterval
+	if auditChainInterval <= 0 {
+		auditChainInterval = defaultAuditChainInterval
+	}
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		worker.Run(ctx, "chain_audit_log", auditChainInterval, func(ctx context.Context) error {
+			chained, err := store.ChainAuditLog(ctx, db.DefaultAuditChainBatchSize)
+			if chained > 0 {
+				slog.DebugContext(ctx, "chained audit log entries", "count", chained)
+			}
+			return err
+		})
+	}()
+
+	// every run reads the whole ledger, so the ledger is only reconciled by the replicas it is enabled on, one being enough
+	if config.ReconciliationEnabled {
+		reconciliationInterval := config.ReconciliationInterval
+		if reconciliationInterval <= 0 {
+			reconciliationInterval = defaultReconciliationInterval
+		}
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker.Run(ctx, "reconcile_ledger", reconciliationInterval, func(ctx context.Context) error {
+				report, err := store.ReconcileLedger(ctx)
+				if err != nil && !errors.Is(err, db.ErrLedgerDiscrepancy) {
+					return err
+				}
+				slog.InfoContext(ctx, "reconciled ledger", "accounts", report.Accounts, "discrepancies", len(report.Discrepancies))
+
+				// the discrepancies fail the job once the report is kept
+				errReport := appendReconciliationReport(config.ReconciliationReportFile, report)
+				if err != nil {
+					return err
+				}
+				return errReport
+			})
+		}()
+	}
+
+	return nil
+}
+
+// commands are the maintenance commands, run instead of the server when their name is given as the first argument.
+var commands = map[string]func(ctx context.Context, store db.Store) error{
+	"verify-audit-log": verifyAuditLog,
+	"reconcile-ledger": reconcileLedger,
+}
+
+// runCommand runs the maintenance command until it is done or SIGINT or SIGTERM is received.
+func runCommand(config util.Config, name string) error {
+	command, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	conn, err := sql.Open(config.DBDriver, config.DBSource)
+	if err != nil {
+		return fmt.Errorf("cannot connect to db: %w", err)
+	}
+	defer closeDB(conn)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return command(ctx, db.NewStore(conn))
+}
+
+// verifyAuditLog walks the hash chain of the audit log and prints how far it is valid as JSON.
+// The last hash printed is worth keeping outside of the db, the removal of the latest entries only shows against it.
+func verifyAuditLog(ctx context.Context, store db.Store) error {
+	result, err := store.VerifyAuditLog(ctx)
+
+	errEncode := json.NewEncoder(os.Stdout).Encode(result)
+	if err != nil {
+		return err
+	}
+	return errEncode
+}
+
+// reconcileLedger checks the balances of the ledger against its entries and prints the report as JSON.
+// It fails if a discrepancy is found, after printing the report.
+func reconcileLedger(ctx context.Context, store db.Store) error {
+	report, err := store.ReconcileLedger(ctx)
+	if err != nil && !errors.Is(err, db.ErrLedgerDiscrepancy) {
+		return err
+	}
+
+	errEncode := json.NewEncoder(os.Stdout).Encode(report)
+	if err != nil {
+		return err
+	}
+	return errEncode
+}
+
+// appendReconciliationReport appends the report as a line of JSON to the file at path, created if needed.
+// Nothing is written if path is empty.
+func appendReconciliationReport(path string, report db.ReconciliationReport) error {
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("cannot open report file: %w", err)
+	}
+
+	err = json.NewEncoder(file).Encode(report)
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+func closeDB(conn *sql.DB) {
+	err := conn.Close()
+	if err != nil {
+		slog.Error("cannot close db", "error", err)
+	}
+}
+
+func closePublisher(publisher outbox.Publisher) {
+	err := publisher.Close()
+	if err != nil {
+		slog.Error("cannot close outbox publisher", "error", err)
+	}
+}
+
+func shutdownTracing(tracerProvider *sdktrace.TracerProvider) {
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
 
-	err = server.Start(config.ServerAddress)
+	err := tracerProvider.Shutdown(ctx)
 	if err != nil {
-		log.Fatal("cannot start server", err)
+		slog.Error("cannot flush spans", "error", err)
 	}
 }
@@ -0,0 +1,90 @@
+// Package cursor encodes the position of a keyset paged list into opaque tokens.
+package cursor
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const minSecretKeySize = 32
+
+// ErrInvalidCursor is returned for a cursor that was not signed by us, or not for the list it is used with.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last row of a page of a list ordered by (created_at, id).
+type Cursor struct {
+	// Scope identifies the list, so that a cursor of one list cannot be used with another.
+	Scope     string    `json:"s"`
+	CreatedAt time.Time `json:"t"`
+	ID        int64     `json:"i"`
+}
+
+// Signer encodes cursors into tokens signed with HMAC-SHA256 and decodes them back.
+type Signer struct {
+	secretKey []byte
+}
+
+// NewSigner creates a new Signer
+func NewSigner(secretKey string) (*Signer, error) {
+	if len(secretKey) < minSecretKeySize {
+		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minSecretKeySize)
+	}
+
+	return &Signer{secretKey: []byte(secretKey)}, nil
+}
+
+// Encode returns the token of the cursor, as payload.signature in URL safe base64.
+func (signer *Signer) Encode(cursor Cursor) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(signer.sign(payload)), nil
+}
+
+// Decode checks the signature and the scope of the token and returns its cursor.
+func (signer *Signer) Decode(token string, scope string) (Cursor, error) {
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if !hmac.Equal(signature, signer.sign(payload)) {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cursor); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if cursor.Scope != scope {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+func (signer *Signer) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, signer.secretKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
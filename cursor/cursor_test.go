@@ -0,0 +1,78 @@
+package cursor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	signer, err := NewSigner(util.RandomString(32))
+	require.NoError(t, err)
+
+	cursor := Cursor{
+		Scope:     "accounts:" + util.RandomOwner(),
+		CreatedAt: time.Now().Truncate(time.Microsecond),
+		ID:        util.RandomInt(1, 1000),
+	}
+
+	token, err := signer.Encode(cursor)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	decoded, err := signer.Decode(token, cursor.Scope)
+	require.NoError(t, err)
+	require.Equal(t, cursor.Scope, decoded.Scope)
+	require.Equal(t, cursor.ID, decoded.ID)
+	require.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+}
+
+func TestSigner_OtherScope(t *testing.T) {
+	signer, err := NewSigner(util.RandomString(32))
+	require.NoError(t, err)
+
+	token, err := signer.Encode(Cursor{Scope: "entries:1", CreatedAt: time.Now(), ID: 1})
+	require.NoError(t, err)
+
+	_, err = signer.Decode(token, "entries:2")
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestSigner_InvalidToken(t *testing.T) {
+	signer, err := NewSigner(util.RandomString(32))
+	require.NoError(t, err)
+	otherSigner, err := NewSigner(util.RandomString(32))
+	require.NoError(t, err)
+
+	cursor := Cursor{Scope: "transfers:1", CreatedAt: time.Now(), ID: 1}
+	token, err := signer.Encode(cursor)
+	require.NoError(t, err)
+	otherToken, err := otherSigner.Encode(cursor)
+	require.NoError(t, err)
+
+	payload, signature, _ := strings.Cut(token, ".")
+	tamperedToken, err := signer.Encode(Cursor{Scope: "transfers:1", CreatedAt: time.Now(), ID: 2})
+	require.NoError(t, err)
+	tamperedPayload, _, _ := strings.Cut(tamperedToken, ".")
+
+	for _, invalid := range []string{
+		"",
+		payload,
+		otherToken,
+		tamperedPayload + "." + signature,
+		payload + ".%%%",
+		"%%%." + signature,
+	} {
+		_, err = signer.Decode(invalid, cursor.Scope)
+		require.ErrorIs(t, err, ErrInvalidCursor, invalid)
+	}
+}
+
+func TestNewSigner_InvalidKeySize(t *testing.T) {
+	signer, err := NewSigner(util.RandomString(31))
+	require.Error(t, err)
+	require.Nil(t, signer)
+}
@@ -0,0 +1,21 @@
+CREATE TABLE "users"
+(
+    "username"            varchar PRIMARY KEY,
+    "hashed_password"     varchar        NOT NULL,
+    "full_name"           varchar        NOT NULL,
+    "email"               varchar UNIQUE NOT NULL,
+    "password_changed_at" timestamptz    NOT NULL DEFAULT ('0001-01-01 00:00:00Z'),
+    "created_at"          timestamptz    NOT NULL DEFAULT (now())
+);
+
+-- the owners of the existing accounts become placeholder users, so the foreign key holds on the existing rows:
+-- no password hash matches their empty one, they cannot log in until a password is set for them
+INSERT INTO "users" ("username", "hashed_password", "full_name", "email")
+SELECT DISTINCT "owner", '', "owner", "owner" || '@placeholder.invalid'
+FROM "accounts";
+
+ALTER TABLE "accounts"
+    ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "accounts"
+    ADD CONSTRAINT "owner_currency_key" UNIQUE ("owner", "currency");
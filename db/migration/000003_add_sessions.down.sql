@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS sessions;
+
+ALTER TABLE IF EXISTS "users" DROP COLUMN IF EXISTS "role";
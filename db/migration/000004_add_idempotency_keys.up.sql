@@ -0,0 +1,14 @@
+CREATE TABLE "idempotency_keys"
+(
+    "username"        varchar     NOT NULL,
+    "idempotency_key" varchar     NOT NULL,
+    "request_hash"    varchar     NOT NULL,
+    "response"        jsonb       NOT NULL DEFAULT '{}',
+    "created_at"      timestamptz NOT NULL DEFAULT (now()),
+    PRIMARY KEY ("username", "idempotency_key")
+);
+
+COMMENT ON COLUMN "idempotency_keys"."request_hash" IS 'hash of the request the key was first used with';
+
+ALTER TABLE "idempotency_keys"
+    ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
@@ -0,0 +1,15 @@
+ALTER TABLE "transfers"
+    ADD COLUMN "to_amount" bigint;
+
+UPDATE "transfers"
+SET "to_amount" = "amount";
+
+ALTER TABLE "transfers"
+    ALTER COLUMN "to_amount" SET NOT NULL;
+
+ALTER TABLE "transfers"
+    ADD COLUMN "exchange_rate" numeric NOT NULL DEFAULT 1;
+
+COMMENT ON COLUMN "transfers"."to_amount" IS 'amount credited to the to account, in its currency';
+
+COMMENT ON COLUMN "transfers"."exchange_rate" IS 'rate used to convert amount into to_amount';
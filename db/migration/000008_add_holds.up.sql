@@ -0,0 +1,44 @@
+ALTER TABLE "accounts"
+    ADD COLUMN "held_balance" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "accounts"
+    ADD COLUMN "available_balance" bigint NOT NULL GENERATED ALWAYS AS ("balance" - "held_balance") STORED;
+
+CREATE TABLE "holds"
+(
+    "id"              bigserial PRIMARY KEY,
+    "account_id"      bigint      NOT NULL,
+    "to_account_id"   bigint      NOT NULL,
+    "amount"          bigint      NOT NULL,
+    "captured_amount" bigint      NOT NULL DEFAULT 0,
+    "status"          varchar     NOT NULL DEFAULT 'pending',
+    "transfer_id"     bigint,
+    "expires_at"      timestamptz NOT NULL,
+    "created_at"      timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "holds"
+    ADD CONSTRAINT "holds_status_check" CHECK ("status" IN ('pending', 'captured', 'voided', 'expired'));
+
+CREATE INDEX ON "holds" ("account_id");
+
+CREATE INDEX ON "holds" ("expires_at") WHERE "status" = 'pending';
+
+COMMENT ON COLUMN "accounts"."held_balance" IS 'sum of the pending holds on the account';
+
+COMMENT ON COLUMN "accounts"."available_balance" IS 'balance that can be spent, the balance less the held balance';
+
+COMMENT ON COLUMN "holds"."amount" IS 'must be positive';
+
+COMMENT ON COLUMN "holds"."status" IS 'pending, captured, voided or expired';
+
+COMMENT ON COLUMN "holds"."transfer_id" IS 'transfer the hold was captured with';
+
+ALTER TABLE "holds"
+    ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds"
+    ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds"
+    ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
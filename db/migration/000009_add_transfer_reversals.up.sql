@@ -0,0 +1,17 @@
+ALTER TABLE "transfers"
+    ADD COLUMN "reversal_of_id" bigint;
+
+ALTER TABLE "transfers"
+    ADD COLUMN "reversed_amount" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "transfers"
+    ADD CONSTRAINT "transfers_reversed_amount_check" CHECK ("reversed_amount" BETWEEN 0 AND "amount");
+
+CREATE INDEX ON "transfers" ("reversal_of_id");
+
+COMMENT ON COLUMN "transfers"."reversal_of_id" IS 'transfer this one reverses, fully or partially';
+
+COMMENT ON COLUMN "transfers"."reversed_amount" IS 'part of amount reversed so far, at most amount';
+
+ALTER TABLE "transfers"
+    ADD FOREIGN KEY ("reversal_of_id") REFERENCES "transfers" ("id");
@@ -0,0 +1,67 @@
+CREATE TABLE "scheduled_transfers"
+(
+    "id"              bigserial PRIMARY KEY,
+    "owner"           varchar     NOT NULL,
+    "from_account_id" bigint      NOT NULL,
+    "to_account_id"   bigint      NOT NULL,
+    "amount"          bigint      NOT NULL,
+    "schedule"        varchar     NOT NULL,
+    "starts_at"       timestamptz NOT NULL,
+    "ends_at"         timestamptz,
+    "next_run_at"     timestamptz NOT NULL,
+    "status"          varchar     NOT NULL DEFAULT 'active',
+    "created_at"      timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "scheduled_transfers"
+    ADD CONSTRAINT "scheduled_transfers_status_check" CHECK ("status" IN ('active', 'finished', 'cancelled'));
+
+CREATE TABLE "scheduled_transfer_runs"
+(
+    "id"                    bigserial PRIMARY KEY,
+    "scheduled_transfer_id" bigint      NOT NULL,
+    "scheduled_for"         timestamptz NOT NULL,
+    "status"                varchar     NOT NULL,
+    "transfer_id"           bigint,
+    "error"                 varchar     NOT NULL DEFAULT '',
+    "missed_runs"           bigint      NOT NULL DEFAULT 0,
+    "created_at"            timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "scheduled_transfer_runs"
+    ADD CONSTRAINT "scheduled_transfer_runs_status_check" CHECK ("status" IN ('succeeded', 'failed', 'skipped'));
+
+CREATE INDEX ON "scheduled_transfers" ("owner");
+
+CREATE INDEX ON "scheduled_transfers" ("next_run_at") WHERE "status" = 'active';
+
+CREATE INDEX ON "scheduled_transfer_runs" ("scheduled_transfer_id", "id");
+
+COMMENT ON COLUMN "scheduled_transfers"."amount" IS 'must be positive';
+
+COMMENT ON COLUMN "scheduled_transfers"."schedule" IS 'cron expression or descriptor, like @monthly or @every 24h';
+
+COMMENT ON COLUMN "scheduled_transfers"."ends_at" IS 'no runs after it, runs forever if null';
+
+COMMENT ON COLUMN "scheduled_transfers"."status" IS 'active, finished or cancelled';
+
+COMMENT ON COLUMN "scheduled_transfer_runs"."status" IS 'succeeded, failed or skipped';
+
+COMMENT ON COLUMN "scheduled_transfer_runs"."transfer_id" IS 'transfer made by a succeeded run';
+
+COMMENT ON COLUMN "scheduled_transfer_runs"."missed_runs" IS 'earlier runs missed during a downtime that the catch-up policy did not execute';
+
+ALTER TABLE "scheduled_transfers"
+    ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "scheduled_transfers"
+    ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers"
+    ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfer_runs"
+    ADD FOREIGN KEY ("scheduled_transfer_id") REFERENCES "scheduled_transfers" ("id");
+
+ALTER TABLE "scheduled_transfer_runs"
+    ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
@@ -0,0 +1,18 @@
+CREATE TABLE "outbox"
+(
+    "id"             bigserial PRIMARY KEY,
+    "event_type"     varchar     NOT NULL,
+    "aggregate_type" varchar     NOT NULL,
+    "aggregate_id"   bigint      NOT NULL,
+    "payload"        jsonb       NOT NULL,
+    "created_at"     timestamptz NOT NULL DEFAULT (now()),
+    "published_at"   timestamptz
+);
+
+CREATE INDEX ON "outbox" ("id") WHERE "published_at" IS NULL;
+
+COMMENT ON COLUMN "outbox"."event_type" IS 'like TransferCompleted, AccountCreated or BalanceChanged';
+
+COMMENT ON COLUMN "outbox"."aggregate_type" IS 'kind of record the event is about, like transfer or account';
+
+COMMENT ON COLUMN "outbox"."published_at" IS 'when the relay published the event, null until then';
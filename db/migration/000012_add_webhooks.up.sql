@@ -0,0 +1,52 @@
+CREATE TABLE "webhook_subscriptions"
+(
+    "id"          bigserial PRIMARY KEY,
+    "owner"       varchar     NOT NULL,
+    "url"         varchar     NOT NULL,
+    "secret"      varchar     NOT NULL,
+    "event_types" varchar[]   NOT NULL,
+    "created_at"  timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "webhook_deliveries"
+(
+    "id"              bigserial PRIMARY KEY,
+    "subscription_id" bigint      NOT NULL,
+    "event_id"        bigint      NOT NULL,
+    "event_type"      varchar     NOT NULL,
+    "payload"         jsonb       NOT NULL,
+    "status"          varchar     NOT NULL DEFAULT 'pending',
+    "attempts"        int         NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz NOT NULL DEFAULT (now()),
+    "last_attempt_at" timestamptz,
+    "response_status" int         NOT NULL DEFAULT 0,
+    "last_error"      varchar     NOT NULL DEFAULT '',
+    "created_at"      timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "webhook_deliveries"
+    ADD CONSTRAINT "webhook_deliveries_status_check" CHECK ("status" IN ('pending', 'succeeded', 'failed'));
+
+CREATE INDEX ON "webhook_subscriptions" ("owner");
+
+CREATE UNIQUE INDEX ON "webhook_deliveries" ("subscription_id", "event_id");
+
+CREATE INDEX ON "webhook_deliveries" ("subscription_id", "id");
+
+CREATE INDEX ON "webhook_deliveries" ("next_attempt_at") WHERE "status" = 'pending';
+
+COMMENT ON COLUMN "webhook_subscriptions"."secret" IS 'key the deliveries are signed with';
+
+COMMENT ON COLUMN "webhook_subscriptions"."event_types" IS 'types of the events delivered, like TransferCompleted';
+
+COMMENT ON COLUMN "webhook_deliveries"."event_id" IS 'outbox event delivered';
+
+COMMENT ON COLUMN "webhook_deliveries"."status" IS 'pending, succeeded or failed';
+
+COMMENT ON COLUMN "webhook_deliveries"."response_status" IS 'HTTP status of the last response, 0 if there was none';
+
+ALTER TABLE "webhook_subscriptions"
+    ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "webhook_deliveries"
+    ADD FOREIGN KEY ("subscription_id") REFERENCES "webhook_subscriptions" ("id") ON DELETE CASCADE;
@@ -0,0 +1,55 @@
+-- the states are json rather than jsonb, which keeps them byte for byte as they were hashed
+CREATE TABLE "audit_log"
+(
+    "id"            bigserial PRIMARY KEY,
+    "actor"         varchar     NOT NULL,
+    "action"        varchar     NOT NULL,
+    "resource_type" varchar     NOT NULL,
+    "resource_id"   varchar     NOT NULL,
+    "before"        json,
+    "after"         json,
+    "request_id"    varchar     NOT NULL DEFAULT '',
+    "client_ip"     varchar     NOT NULL DEFAULT '',
+    "created_at"    timestamptz NOT NULL DEFAULT (now()),
+    "prev_hash"     varchar     NOT NULL,
+    "hash"          varchar     NOT NULL
+);
+
+CREATE INDEX ON "audit_log" ("actor", "id");
+
+CREATE INDEX ON "audit_log" ("resource_type", "resource_id", "id");
+
+CREATE INDEX ON "audit_log" ("request_id");
+
+CREATE INDEX ON "audit_log" ("created_at");
+
+COMMENT ON COLUMN "audit_log"."actor" IS 'username of the user making the change, or system';
+
+COMMENT ON COLUMN "audit_log"."action" IS 'like transfer.create or account.status_change';
+
+COMMENT ON COLUMN "audit_log"."before" IS 'resource before the change, null if it was created';
+
+COMMENT ON COLUMN "audit_log"."after" IS 'resource after the change, null if it was deleted';
+
+COMMENT ON COLUMN "audit_log"."prev_hash" IS 'hash of the entry before, empty for the first one';
+
+COMMENT ON COLUMN "audit_log"."hash" IS 'sha256 of the entry chained to prev_hash';
+
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "audit_log_append_only"
+    BEFORE UPDATE OR DELETE
+    ON "audit_log"
+    FOR EACH ROW
+EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER "audit_log_no_truncate"
+    BEFORE TRUNCATE
+    ON "audit_log"
+    FOR EACH STATEMENT
+EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "accounts_owner_currency_open_idx";
+
+ALTER TABLE "accounts"
+    ADD CONSTRAINT "owner_currency_key" UNIQUE ("owner", "currency");
@@ -0,0 +1,4 @@
+-- an owner has at most one account in each currency that is not closed, so that a closed account can be replaced
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "owner_currency_key";
+
+CREATE UNIQUE INDEX "accounts_owner_currency_open_idx" ON "accounts" ("owner", "currency") WHERE "status" <> 'closed';
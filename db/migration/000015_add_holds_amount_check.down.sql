@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS "holds" DROP CONSTRAINT IF EXISTS "holds_amount_check";
@@ -0,0 +1,2 @@
+ALTER TABLE "holds"
+    ADD CONSTRAINT "holds_amount_check" CHECK ("amount" > 0);
@@ -0,0 +1,18 @@
+ALTER TABLE "entries"
+    ADD COLUMN "transfer_id" bigint;
+
+-- the entries written before were matched to their transfer by account, amount and created_at,
+-- the entries of a transfer being written in its tx
+UPDATE "entries" e
+SET "transfer_id" = t."id"
+FROM "transfers" t
+WHERE e."created_at" = t."created_at"
+  AND ((e."account_id" = t."from_account_id" AND e."amount" = -t."amount")
+    OR (e."account_id" = t."to_account_id" AND e."amount" = t."to_amount"));
+
+CREATE INDEX ON "entries" ("transfer_id");
+
+COMMENT ON COLUMN "entries"."transfer_id" IS 'transfer the entry was written for, null for money from outside the bank';
+
+ALTER TABLE "entries"
+    ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
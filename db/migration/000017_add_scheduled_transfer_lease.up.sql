@@ -0,0 +1,4 @@
+ALTER TABLE "scheduled_transfers"
+    ADD COLUMN "leased_until" timestamptz;
+
+COMMENT ON COLUMN "scheduled_transfers"."leased_until" IS 'the due run is being made by a worker, it is retried after it if it is not recorded by then';
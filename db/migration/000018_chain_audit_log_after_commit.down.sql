@@ -0,0 +1,23 @@
+-- the entries not chained yet are left with an empty hash, which VerifyAuditLog then reports
+DROP INDEX IF EXISTS "audit_log_chain_seq_idx";
+
+DROP INDEX IF EXISTS "audit_log_id_idx";
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE IF EXISTS "audit_log" DROP COLUMN IF EXISTS "chain_seq";
+
+ALTER TABLE IF EXISTS "audit_log"
+    ALTER COLUMN "prev_hash" DROP DEFAULT;
+
+ALTER TABLE IF EXISTS "audit_log"
+    ALTER COLUMN "hash" DROP DEFAULT;
+
+COMMENT ON COLUMN "audit_log"."prev_hash" IS 'hash of the entry before, empty for the first one';
+
+COMMENT ON COLUMN "audit_log"."hash" IS 'sha256 of the entry chained to prev_hash';
@@ -0,0 +1,40 @@
+-- the entries are written without waiting for each other, and chained afterwards by a single writer
+ALTER TABLE "audit_log"
+    ADD COLUMN "chain_seq" bigint;
+
+ALTER TABLE "audit_log"
+    ALTER COLUMN "prev_hash" SET DEFAULT '';
+
+ALTER TABLE "audit_log"
+    ALTER COLUMN "hash" SET DEFAULT '';
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS
+$$
+BEGIN
+    -- an entry is chained once after it is written, nothing of it changes after that
+    IF TG_OP = 'UPDATE' AND OLD."chain_seq" IS NULL AND NEW."chain_seq" IS NOT NULL
+        AND (NEW."id", NEW."actor", NEW."action", NEW."resource_type", NEW."resource_id", NEW."before"::text,
+             NEW."after"::text, NEW."request_id", NEW."client_ip", NEW."created_at")
+            IS NOT DISTINCT FROM
+            (OLD."id", OLD."actor", OLD."action", OLD."resource_type", OLD."resource_id", OLD."before"::text,
+             OLD."after"::text, OLD."request_id", OLD."client_ip", OLD."created_at") THEN
+        RETURN NEW;
+    END IF;
+
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- the entries written before were chained in the order of their IDs, as they were written
+UPDATE "audit_log"
+SET "chain_seq" = "id";
+
+CREATE UNIQUE INDEX ON "audit_log" ("chain_seq");
+
+CREATE INDEX ON "audit_log" ("id") WHERE "chain_seq" IS NULL;
+
+COMMENT ON COLUMN "audit_log"."chain_seq" IS 'position of the entry in the hash chain, null until it is chained';
+
+COMMENT ON COLUMN "audit_log"."prev_hash" IS 'hash of the entry before in the chain, empty for the first one and until it is chained';
+
+COMMENT ON COLUMN "audit_log"."hash" IS 'sha256 of the entry chained to prev_hash, empty until it is chained';
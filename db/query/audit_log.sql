@@ -0,0 +1,64 @@
+-- name: ChainAuditLogEntry :exec
+UPDATE audit_log
+set chain_seq = $1,
+    prev_hash = $2,
+    hash      = $3
+WHERE id = $4
+  AND chain_seq IS NULL;
+
+-- name: CountUnchainedAuditLog :one
+SELECT count(*)
+FROM audit_log
+WHERE chain_seq IS NULL;
+
+-- name: CreateAuditLog :one
+INSERT INTO audit_log (actor,
+                       action,
+                       resource_type,
+                       resource_id,
+                       before,
+                       after,
+                       request_id,
+                       client_ip,
+                       created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: GetLastChainedAuditLog :one
+SELECT chain_seq, hash
+FROM audit_log
+WHERE chain_seq IS NOT NULL
+ORDER BY chain_seq DESC
+LIMIT 1;
+
+-- name: ListAuditLog :many
+SELECT *
+FROM audit_log
+WHERE id > sqlc.arg(after_id)
+  AND (sqlc.narg(actor)::varchar IS NULL OR actor = sqlc.narg(actor))
+  AND (sqlc.narg(action)::varchar IS NULL OR action = sqlc.narg(action))
+  AND (sqlc.narg(resource_type)::varchar IS NULL OR resource_type = sqlc.narg(resource_type))
+  AND (sqlc.narg(resource_id)::varchar IS NULL OR resource_id = sqlc.narg(resource_id))
+  AND (sqlc.narg(request_id)::varchar IS NULL OR request_id = sqlc.narg(request_id))
+  AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from))
+  AND (sqlc.narg(created_to)::timestamptz IS NULL OR created_at < sqlc.narg(created_to))
+ORDER BY id
+LIMIT sqlc.arg('limit');
+
+-- name: ListChainedAuditLog :many
+SELECT *
+FROM audit_log
+WHERE chain_seq > sqlc.arg(after_seq)::bigint
+ORDER BY chain_seq
+LIMIT sqlc.arg('limit');
+
+-- name: ListUnchainedAuditLog :many
+SELECT *
+FROM audit_log
+WHERE chain_seq IS NULL
+ORDER BY id
+LIMIT $1;
+
+-- name: LockAuditLog :exec
+-- the lock is held until the end of the tx, so the entries are chained by one writer at a time
+SELECT pg_advisory_xact_lock(hashtext('audit_log'));
@@ -0,0 +1,33 @@
+-- name: CreateHold :one
+INSERT INTO holds (account_id, to_account_id, amount, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetHold :one
+SELECT *
+FROM holds
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetHoldForUpdate :one
+SELECT *
+FROM holds
+WHERE id = $1
+LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: ListExpiredHolds :many
+SELECT id
+FROM holds
+WHERE status = 'pending'
+  AND expires_at <= sqlc.arg(expired_at)
+ORDER BY expires_at
+LIMIT sqlc.arg('limit');
+
+-- name: UpdateHold :one
+UPDATE holds
+set status          = sqlc.arg(status),
+    captured_amount = sqlc.arg(captured_amount),
+    transfer_id     = sqlc.arg(transfer_id)
+WHERE id = sqlc.arg(id)
+RETURNING *;
@@ -0,0 +1,17 @@
+-- name: ClaimUnpublishedOutboxEvents :many
+SELECT *
+FROM outbox
+WHERE published_at IS NULL
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE outbox
+set published_at = now()
+WHERE id = $1;
@@ -0,0 +1,71 @@
+-- name: ListAccountBalanceMismatches :many
+-- the accounts whose balance is not the sum of their entries
+SELECT a.id, a.currency, a.balance, COALESCE(e.total, 0)::bigint AS entries_total
+FROM accounts a
+         LEFT JOIN (SELECT account_id, SUM(amount) AS total
+                    FROM entries
+                    GROUP BY account_id) e ON e.account_id = a.id
+WHERE a.balance <> COALESCE(e.total, 0)
+ORDER BY a.id;
+
+-- name: ListTransferEntryMismatches :many
+-- the transfers without exactly one entry of the right amount for each account among the entries written for them
+SELECT id, from_account_id, to_account_id, amount, to_amount, created_at, from_entries, to_entries
+FROM (SELECT t.id,
+             t.from_account_id,
+             t.to_account_id,
+             t.amount,
+             t.to_amount,
+             t.created_at,
+             (SELECT COUNT(*)
+              FROM entries e
+              WHERE e.transfer_id = t.id
+                AND e.account_id = t.from_account_id
+                AND e.amount = -t.amount) AS from_entries,
+             (SELECT COUNT(*)
+              FROM entries e
+              WHERE e.transfer_id = t.id
+                AND e.account_id = t.to_account_id
+                AND e.amount = t.to_amount) AS to_entries
+      FROM transfers t) matched
+WHERE from_entries <> 1
+   OR to_entries <> 1
+ORDER BY id;
+
+-- name: ListCurrencyTotals :many
+-- the totals of the accounts of each currency, and the total their balances are expected to add up to.
+-- Money only enters or leaves a currency from outside the bank, with the entries written for no transfer,
+-- or by a transfer between currencies, which moves money out of one and into the other.
+-- The transfers within a currency net to zero, so they are left out.
+WITH entry_totals AS (SELECT a.currency,
+                             SUM(e.amount) AS total,
+                             SUM(e.amount) FILTER (WHERE e.transfer_id IS NULL) AS funding
+                      FROM entries e
+                               JOIN accounts a ON a.id = e.account_id
+                      GROUP BY a.currency),
+     exchange_totals AS (SELECT currency, SUM(amount) AS total
+                         FROM (SELECT fa.currency, -t.amount AS amount
+                               FROM transfers t
+                                        JOIN accounts fa ON fa.id = t.from_account_id
+                                        JOIN accounts ta ON ta.id = t.to_account_id
+                               WHERE fa.currency <> ta.currency
+                               UNION ALL
+                               SELECT ta.currency, t.to_amount AS amount
+                               FROM transfers t
+                                        JOIN accounts fa ON fa.id = t.from_account_id
+                                        JOIN accounts ta ON ta.id = t.to_account_id
+                               WHERE fa.currency <> ta.currency) legs
+                         GROUP BY currency)
+SELECT b.currency,
+       b.accounts,
+       b.balance_total,
+       COALESCE(et.total, 0)::bigint AS entries_total,
+       COALESCE(et.funding, 0)::bigint AS funding_total,
+       COALESCE(xt.total, 0)::bigint AS exchange_total,
+       (COALESCE(et.funding, 0) + COALESCE(xt.total, 0))::bigint AS expected_total
+FROM (SELECT currency, COUNT(*) AS accounts, SUM(balance)::bigint AS balance_total
+      FROM accounts
+      GROUP BY currency) b
+         LEFT JOIN entry_totals et ON et.currency = b.currency
+         LEFT JOIN exchange_totals xt ON xt.currency = b.currency
+ORDER BY b.currency;
@@ -0,0 +1,54 @@
+-- name: CancelScheduledTransfer :one
+UPDATE scheduled_transfers
+set status = 'cancelled'
+WHERE id = $1
+  AND status = 'active'
+RETURNING *;
+
+-- name: ClaimDueScheduledTransfer :one
+SELECT *
+FROM scheduled_transfers
+WHERE status = 'active'
+  AND next_run_at <= sqlc.arg(due_at)
+  AND (leased_until IS NULL OR leased_until <= sqlc.arg(due_at))
+ORDER BY next_run_at
+LIMIT 1
+FOR NO KEY UPDATE SKIP LOCKED;
+
+-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (owner, from_account_id, to_account_id, amount, schedule, starts_at, ends_at, next_run_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetScheduledTransfer :one
+SELECT *
+FROM scheduled_transfers
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetScheduledTransferForUpdate :one
+SELECT *
+FROM scheduled_transfers
+WHERE id = $1
+LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: LeaseScheduledTransfer :one
+UPDATE scheduled_transfers
+set leased_until = sqlc.arg(leased_until)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ListScheduledTransfers :many
+SELECT *
+FROM scheduled_transfers
+WHERE owner = $1
+ORDER BY id;
+
+-- name: UpdateScheduledTransfer :one
+UPDATE scheduled_transfers
+set next_run_at  = sqlc.arg(next_run_at),
+    status       = sqlc.arg(status),
+    leased_until = NULL
+WHERE id = sqlc.arg(id)
+RETURNING *;
@@ -0,0 +1,11 @@
+-- name: CreateScheduledTransferRun :one
+INSERT INTO scheduled_transfer_runs (scheduled_transfer_id, scheduled_for, status, transfer_id, error, missed_runs)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: ListScheduledTransferRuns :many
+SELECT *
+FROM scheduled_transfer_runs
+WHERE scheduled_transfer_id = $1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3;
@@ -1,6 +1,6 @@
 -- name: CreateTransfer :one
-INSERT INTO transfers (from_account_id, to_account_id, amount)
-VALUES ($1, $2, $3)
+INSERT INTO transfers (from_account_id, to_account_id, amount, to_amount, exchange_rate, reversal_of_id)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: GetTransfer :one
@@ -9,10 +9,37 @@ FROM transfers
 WHERE id = $1
 LIMIT 1;
 
+-- name: GetTransferForUpdate :one
+SELECT *
+FROM transfers
+WHERE id = $1
+LIMIT 1
+FOR NO KEY UPDATE;
+
 -- name: ListTransfers :many
 SELECT *
 FROM transfers
 WHERE from_account_id = $1
    OR to_account_id = $2
 ORDER BY id
-LIMIT $3 OFFSET $4;
\ No newline at end of file
+LIMIT $3 OFFSET $4;
+
+-- name: ListTransfersAfter :many
+SELECT *
+FROM transfers
+WHERE (from_account_id = sqlc.arg(from_account_id) OR to_account_id = sqlc.arg(to_account_id))
+  AND (created_at, id) > (sqlc.arg(after_created_at)::timestamptz, sqlc.arg(after_id)::bigint)
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: ListTransferReversals :many
+SELECT *
+FROM transfers
+WHERE reversal_of_id = sqlc.arg(transfer_id)::bigint
+ORDER BY id;
+
+-- name: AddTransferReversedAmount :one
+UPDATE transfers
+set reversed_amount = reversed_amount + sqlc.arg(amount)
+WHERE id = sqlc.arg(id)
+RETURNING *;
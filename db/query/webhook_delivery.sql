@@ -0,0 +1,59 @@
+-- name: ClaimDueWebhookDelivery :one
+SELECT *
+FROM webhook_deliveries
+WHERE status = 'pending'
+  AND next_attempt_at <= sqlc.arg(due_at)
+ORDER BY next_attempt_at
+LIMIT 1
+FOR NO KEY UPDATE SKIP LOCKED;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (subscription_id, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT *
+FROM webhook_deliveries
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetWebhookDeliveryForUpdate :one
+SELECT *
+FROM webhook_deliveries
+WHERE id = $1
+LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: LeaseWebhookDelivery :one
+UPDATE webhook_deliveries
+set next_attempt_at = $1
+WHERE id = $2
+RETURNING *;
+
+-- name: ListWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE subscription_id = $1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ReplayWebhookDelivery :one
+UPDATE webhook_deliveries
+set status          = 'pending',
+    attempts        = 0,
+    next_attempt_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateWebhookDelivery :one
+UPDATE webhook_deliveries
+set status          = $1,
+    attempts        = $2,
+    next_attempt_at = $3,
+    last_attempt_at = $4,
+    response_status = $5,
+    last_error      = $6
+WHERE id = $7
+RETURNING *;
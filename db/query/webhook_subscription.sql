@@ -0,0 +1,29 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (owner, url, secret, event_types)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: DeleteWebhookSubscription :exec
+DELETE FROM webhook_subscriptions
+WHERE id = $1;
+
+-- name: GetWebhookSubscription :one
+SELECT *
+FROM webhook_subscriptions
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListWebhookSubscriptions :many
+SELECT *
+FROM webhook_subscriptions
+WHERE owner = $1
+ORDER BY id;
+
+-- name: ListWebhookSubscriptionsForEvent :many
+SELECT *
+FROM webhook_subscriptions
+WHERE sqlc.arg(event_type)::varchar = ANY (event_types)
+  AND owner IN (SELECT owner
+                FROM accounts
+                WHERE id = ANY (sqlc.arg(account_ids)::bigint[]))
+ORDER BY id;
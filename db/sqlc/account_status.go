@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// Account statuses. Only active accounts can be debited or credited, closed is final.
+const (
+	AccountStatusActive = "active"
+	AccountStatusFrozen = "frozen"
+	AccountStatusClosed = "closed"
+)
+
+var (
+	// ErrAccountNotActive is returned when a transfer would debit or credit a frozen or closed account.
+	ErrAccountNotActive = errors.New("account is not active")
+	// ErrInvalidStatusTransition is returned when an account cannot move from its status to the requested one.
+	ErrInvalidStatusTransition = errors.New("invalid account status transition")
+	// ErrAccountBalanceNotZero is returned when closing an account that still holds money.
+	ErrAccountBalanceNotZero = errors.New("account balance is not zero")
+)
+
+// accountStatusTransitions lists the statuses an account can move to from each status.
+var accountStatusTransitions = map[string][]string{
+	AccountStatusActive: {AccountStatusFrozen, AccountStatusClosed},
+	AccountStatusFrozen: {AccountStatusActive, AccountStatusClosed},
+	AccountStatusClosed: {},
+}
+
+// CanChangeAccountStatus reports whether an account can move from one status to the other.
+func CanChangeAccountStatus(from, to string) bool {
+	for _, status := range accountStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+type ChangeAccountStatusTxParams struct {
+	AccountID int64  `json:"account_id"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason"`
+	// ChangedBy is the username of the banker making the change.
+	ChangedBy string `json:"changed_by"`
+}
+
+type ChangeAccountStatusTxResult struct {
+	Account Account             `json:"account"`
+	Change  AccountStatusChange `json:"change"`
+}
+
+// ChangeAccountStatusTx moves an account to a new status and records the change with its reason within a single db tx,
+// in which the change is audited too.
+// The tx is rolled back with ErrInvalidStatusTransition if the account cannot move to the status,
+// and with ErrAccountBalanceNotZero if it is being closed with money left on it.
+func (store *SQLStore) ChangeAccountStatusTx(ctx context.Context, arg ChangeAccountStatusTxParams) (ChangeAccountStatusTxResult, error) {
+	var result ChangeAccountStatusTxResult
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		result = ChangeAccountStatusTxResult{}
+
+		// lock the account, so no transfer changes its balance before it is closed
+		account, err := queries.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		if !CanChangeAccountStatus(account.Status, arg.Status) {
+			return ErrInvalidStatusTransition
+		}
+
+		if arg.Status == AccountStatusClosed && account.Balance != 0 {
+			return ErrAccountBalanceNotZero
+		}
+
+		result.Account, err = queries.UpdateAccountStatus(ctx, UpdateAccountStatusParams{
+			Status: arg.Status,
+			ID:     arg.AccountID,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Change, err = queries.CreateAccountStatusChange(ctx, CreateAccountStatusChangeParams{
+			AccountID:  arg.AccountID,
+			FromStatus: account.Status,
+			ToStatus:   arg.Status,
+			Reason:     arg.Reason,
+			ChangedBy:  arg.ChangedBy,
+		})
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditAccountStatusChange, ResourceAccount, auditID(account.ID), account, result.Account)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
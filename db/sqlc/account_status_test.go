@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanChangeAccountStatus(t *testing.T) {
+	testCases := []struct {
+		from, to string
+		ok       bool
+	}{
+		{AccountStatusActive, AccountStatusFrozen, true},
+		{AccountStatusActive, AccountStatusClosed, true},
+		{AccountStatusFrozen, AccountStatusActive, true},
+		{AccountStatusFrozen, AccountStatusClosed, true},
+		{AccountStatusActive, AccountStatusActive, false},
+		{AccountStatusFrozen, AccountStatusFrozen, false},
+		{AccountStatusClosed, AccountStatusActive, false},
+		{AccountStatusClosed, AccountStatusFrozen, false},
+		{AccountStatusActive, "unknown", false},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.ok, CanChangeAccountStatus(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+}
+
+func TestStore_ChangeAccountStatusTx(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, 0)
+	require.Equal(t, AccountStatusActive, account.Status)
+	banker := createRandomUser(t)
+
+	result, err := store.ChangeAccountStatusTx(ctx, ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusFrozen,
+		Reason:    "suspicious activity",
+		ChangedBy: banker.Username,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusFrozen, result.Account.Status)
+	require.Equal(t, account.ID, result.Change.AccountID)
+	require.Equal(t, AccountStatusActive, result.Change.FromStatus)
+	require.Equal(t, AccountStatusFrozen, result.Change.ToStatus)
+	require.Equal(t, "suspicious activity", result.Change.Reason)
+	require.Equal(t, banker.Username, result.Change.ChangedBy)
+	require.NotZero(t, result.Change.CreatedAt)
+
+	// frozen twice
+	_, err = store.ChangeAccountStatusTx(ctx, ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusFrozen,
+		Reason:    "again",
+		ChangedBy: banker.Username,
+	})
+	require.ErrorIs(t, err, ErrInvalidStatusTransition)
+
+	_, err = store.ChangeAccountStatusTx(ctx, ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusClosed,
+		Reason:    "customer request",
+		ChangedBy: banker.Username,
+	})
+	require.NoError(t, err)
+
+	// closed is final
+	_, err = store.ChangeAccountStatusTx(ctx, ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusActive,
+		Reason:    "reopen",
+		ChangedBy: banker.Username,
+	})
+	require.ErrorIs(t, err, ErrInvalidStatusTransition)
+
+	changes, err := store.ListAccountStatusChanges(ctx, account.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, AccountStatusFrozen, changes[0].ToStatus)
+	require.Equal(t, AccountStatusFrozen, changes[1].FromStatus)
+	require.Equal(t, AccountStatusClosed, changes[1].ToStatus)
+}
+
+func TestStore_ChangeAccountStatusTxCloseWithBalance(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, 10)
+	banker := createRandomUser(t)
+
+	_, err := store.ChangeAccountStatusTx(ctx, ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusClosed,
+		Reason:    "customer request",
+		ChangedBy: banker.Username,
+	})
+	require.ErrorIs(t, err, ErrAccountBalanceNotZero)
+
+	// nothing was changed
+	updatedAccount, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusActive, updatedAccount.Status)
+
+	changes, err := store.ListAccountStatusChanges(ctx, account.ID)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
+
+func TestStore_CreateAccountAfterClosing(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, 0)
+	banker := createRandomUser(t)
+	arg := CreateAccountParams{
+		Owner:    account.Owner,
+		Currency: account.Currency,
+	}
+
+	// an owner has one open account per currency
+	_, err := store.CreateAccount(ctx, arg)
+	require.Error(t, err)
+
+	_, err = store.ChangeAccountStatusTx(ctx, ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusClosed,
+		Reason:    "customer request",
+		ChangedBy: banker.Username,
+	})
+	require.NoError(t, err)
+
+	// the closed account does not count
+	newAccount, err := store.CreateAccount(ctx, arg)
+	require.NoError(t, err)
+	require.NotEqual(t, account.ID, newAccount.ID)
+
+	_, err = store.CreateAccount(ctx, arg)
+	require.Error(t, err)
+}
@@ -11,10 +11,20 @@ import (
 )
 
 func createRandomAccount(t *testing.T) Account {
+	return createRandomAccountWithBalance(t, util.RandomMoney())
+}
+
+func createRandomAccountWithBalance(t *testing.T, balance int64) Account {
+	return createRandomAccountWithCurrency(t, balance, util.RandomCurrency())
+}
+
+func createRandomAccountWithCurrency(t *testing.T, balance int64, currency string) Account {
+	user := createRandomUser(t)
+
 	arg := CreateAccountParams{
-		Owner:    util.RandomOwner(),
-		Balance:  util.RandomMoney(),
-		Currency: util.RandomCurrency(),
+		Owner:    user.Username,
+		Balance:  balance,
+		Currency: currency,
 	}
 
 	account, err := testQueries.CreateAccount(context.Background(), arg)
@@ -24,6 +34,9 @@ func createRandomAccount(t *testing.T) Account {
 	require.Equal(t, arg.Owner, account.Owner)
 	require.Equal(t, arg.Balance, account.Balance)
 	require.Equal(t, arg.Currency, account.Currency)
+	require.Equal(t, AccountStatusActive, account.Status)
+	require.Zero(t, account.HeldBalance)
+	require.Equal(t, arg.Balance, account.AvailableBalance)
 
 	require.NotZero(t, account.ID)
 	require.NotZero(t, account.CreatedAt)
@@ -82,20 +95,52 @@ func TestQueries_DeleteAccount(t *testing.T) {
 }
 
 func TestQueries_ListAccounts(t *testing.T) {
+	var lastAccount Account
 	for i := 0; i < 10; i++ {
-		createRandomAccount(t)
+		lastAccount = createRandomAccount(t)
 	}
 
 	arg := ListAccountsParams{
+		Owner:  lastAccount.Owner,
 		Limit:  5,
-		Offset: 5,
+		Offset: 0,
 	}
 
 	accountsActual, err := testQueries.ListAccounts(context.Background(), arg)
 	require.NoError(t, err)
-	require.Len(t, accountsActual, 5)
+	require.NotEmpty(t, accountsActual)
 
 	for _, account := range accountsActual {
 		require.NotEmpty(t, account)
+		require.Equal(t, lastAccount.Owner, account.Owner)
+	}
+}
+
+func TestQueries_ListAccountsAfter(t *testing.T) {
+	user := createRandomUser(t)
+	var accountsExpected []Account
+	for _, currency := range []string{util.USD, util.EUR, "CAD"} {
+		account, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+			Owner:    user.Username,
+			Balance:  util.RandomMoney(),
+			Currency: currency,
+		})
+		require.NoError(t, err)
+		accountsExpected = append(accountsExpected, account)
 	}
+
+	arg := ListAccountsAfterParams{Owner: user.Username, Limit: 2}
+	accounts, err := testQueries.ListAccountsAfter(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, accountsExpected[:2], accounts)
+
+	arg.AfterCreatedAt, arg.AfterID = accounts[1].CreatedAt, accounts[1].ID
+	accounts, err = testQueries.ListAccountsAfter(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, accountsExpected[2:], accounts)
+
+	arg.AfterCreatedAt, arg.AfterID = accounts[0].CreatedAt, accounts[0].ID
+	accounts, err = testQueries.ListAccountsAfter(context.Background(), arg)
+	require.NoError(t, err)
+	require.Empty(t, accounts)
 }
@@ -0,0 +1,298 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/anilbolat/simple-bank/logging"
+)
+
+// Audited actions, named after the type of the resource and what was done to it.
+const (
+	AuditUserCreate              = "user.create"
+	AuditUserSessionsRevoke      = "user.sessions_revoke"
+	AuditSessionCreate           = "session.create"
+	AuditAccountCreate           = "account.create"
+	AuditAccountUpdate           = "account.update"
+	AuditAccountDelete           = "account.delete"
+	AuditAccountStatusChange     = "account.status_change"
+	AuditTransferCreate          = "transfer.create"
+	AuditTransferReverse         = "transfer.reverse"
+	AuditHoldAuthorize           = "hold.authorize"
+	AuditHoldCapture             = "hold.capture"
+	AuditHoldVoid                = "hold.void"
+	AuditHoldExpire              = "hold.expire"
+	AuditScheduledTransferCreate = "scheduled_transfer.create"
+	AuditScheduledTransferCancel = "scheduled_transfer.cancel"
+	AuditScheduledTransferRun    = "scheduled_transfer.run"
+	AuditWebhookCreate           = "webhook.create"
+	AuditWebhookDelete           = "webhook.delete"
+	AuditWebhookDeliveryReplay   = "webhook_delivery.replay"
+)
+
+// Types of the audited resources.
+const (
+	ResourceUser              = "user"
+	ResourceSession           = "session"
+	ResourceAccount           = "account"
+	ResourceTransfer          = "transfer"
+	ResourceHold              = "hold"
+	ResourceScheduledTransfer = "scheduled_transfer"
+	ResourceWebhook           = "webhook"
+	ResourceWebhookDelivery   = "webhook_delivery"
+)
+
+// SystemActor is the actor of the changes made without a user, like the ones of the background jobs.
+const SystemActor = "system"
+
+// verifyAuditLogBatchSize is how many entries VerifyAuditLog reads at a time.
+const verifyAuditLogBatchSize = 1000
+
+// DefaultAuditChainBatchSize is how many entries ChainAuditLog chains in a db tx, unless told otherwise.
+const DefaultAuditChainBatchSize = 1000
+
+// ErrAuditChainBroken is returned when an entry of the audit log was changed, or entries were removed before it.
+var ErrAuditChainBroken = errors.New("audit log hash chain is broken")
+
+// AuditActor is who makes the changes audited with a ctx carrying it.
+type AuditActor struct {
+	// Username is the user making the changes.
+	Username string
+	// ClientIP is the address the user made the request from.
+	ClientIP string
+}
+
+type auditActorContextKey struct{}
+
+// WithAuditActor returns a copy of ctx carrying the actor of the changes made with it.
+// The changes made with a ctx carrying none are audited as made by SystemActor.
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorContextKey{}, actor)
+}
+
+// AuditActorFrom returns the actor carried by ctx, SystemActor if there is none.
+func AuditActorFrom(ctx context.Context) AuditActor {
+	actor, ok := ctx.Value(auditActorContextKey{}).(AuditActor)
+	if !ok {
+		return AuditActor{Username: SystemActor}
+	}
+	return actor
+}
+
+// writeAudit appends an entry about a change of the resource to the audit log, within the db tx of the queries.
+// The actor, the request ID and the client IP are the ones carried by ctx.
+// The entry is not chained here, which would make every audited tx wait for the one before it to commit:
+// ChainAuditLog chains it once it is committed.
+func writeAudit(ctx context.Context, queries *Queries, action, resourceType, resourceID string, before, after any) error {
+	beforeJSON, err := auditState(before)
+	if err != nil {
+		return err
+	}
+
+	afterJSON, err := auditState(after)
+	if err != nil {
+		return err
+	}
+
+	actor := AuditActorFrom(ctx)
+	_, err = queries.CreateAuditLog(ctx, CreateAuditLogParams{
+		Actor:        actor.Username,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Before:       beforeJSON,
+		After:        afterJSON,
+		RequestID:    logging.RequestID(ctx),
+		ClientIp:     actor.ClientIP,
+		// the precision of timestamptz, so the hash matches the entry as it is read back
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	})
+	return err
+}
+
+// ChainAuditLog chains the entries of the audit log written since the last run, by their IDs, batchSize at a time.
+// Each entry gets the next position in the chain, and a hash over its content and the hash of the entry before it.
+// The chaining runs in db txs of its own, which wait for each other rather than for the audited txs,
+// so several workers can run it side by side. It returns the number of entries chained.
+//
+// An entry is chained once its tx is committed, an entry of a tx committed late is chained after entries
+// with a greater ID. Until it is chained an entry can be changed unnoticed, so the chaining is to run often.
+func (store *SQLStore) ChainAuditLog(ctx context.Context, batchSize int32) (int, error) {
+	chained := 0
+	for {
+		var n int
+		_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+			n = 0
+
+			err := queries.LockAuditLog(ctx)
+			if err != nil {
+				return err
+			}
+
+			var seq int64
+			var prevHash string
+			last, err := queries.GetLastChainedAuditLog(ctx)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if last.ChainSeq != nil {
+				seq, prevHash = *last.ChainSeq, last.Hash
+			}
+
+			entries, err := queries.ListUnchainedAuditLog(ctx, batchSize)
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range entries {
+				seq++
+				entry.PrevHash = prevHash
+				entry.Hash, err = auditHash(entry)
+				if err != nil {
+					return err
+				}
+
+				err = queries.ChainAuditLogEntry(ctx, ChainAuditLogEntryParams{
+					ChainSeq: &seq,
+					PrevHash: entry.PrevHash,
+					Hash:     entry.Hash,
+					ID:       entry.ID,
+				})
+				if err != nil {
+					return err
+				}
+
+				prevHash = entry.Hash
+				n++
+			}
+
+			return nil
+		})
+		if err != nil {
+			return chained, err
+		}
+
+		chained += n
+		if n < int(batchSize) {
+			return chained, nil
+		}
+	}
+}
+
+// auditID is the resource ID of a resource identified by a number.
+func auditID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// auditState encodes the state of a resource, nil if there is none.
+func auditState(state any) (*json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := json.RawMessage(data)
+	return &raw, nil
+}
+
+// auditHashedEntry is what the hash of an audit log entry covers: all of it but its ID and the hash itself.
+type auditHashedEntry struct {
+	PrevHash     string           `json:"prev_hash"`
+	Actor        string           `json:"actor"`
+	Action       string           `json:"action"`
+	ResourceType string           `json:"resource_type"`
+	ResourceID   string           `json:"resource_id"`
+	Before       *json.RawMessage `json:"before"`
+	After        *json.RawMessage `json:"after"`
+	RequestID    string           `json:"request_id"`
+	ClientIP     string           `json:"client_ip"`
+	CreatedAt    string           `json:"created_at"`
+}
+
+// auditHash is the hex encoded sha256 of the entry, chained to the one before it by its PrevHash.
+func auditHash(entry AuditLog) (string, error) {
+	data, err := json.Marshal(auditHashedEntry{
+		PrevHash:     entry.PrevHash,
+		Actor:        entry.Actor,
+		Action:       entry.Action,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		Before:       entry.Before,
+		After:        entry.After,
+		RequestID:    entry.RequestID,
+		ClientIP:     entry.ClientIp,
+		CreatedAt:    entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditLogVerification is the outcome of verifying the audit log.
+type AuditLogVerification struct {
+	// Entries is the number of entries verified.
+	Entries int64 `json:"entries"`
+	// LastID and LastHash are the ID and the hash of the latest entry verified.
+	LastID   int64  `json:"last_id"`
+	LastHash string `json:"last_hash"`
+	// Unchained is the number of entries not chained yet, which are not verified.
+	Unchained int64 `json:"unchained"`
+}
+
+// VerifyAuditLog walks the hash chain of the whole audit log, in the order the entries were chained.
+// It returns ErrAuditChainBroken at the first entry whose hash does not match its content,
+// or that is not chained to the entry before it, with the entries verified until then.
+// Removing the latest entries leaves a valid chain: comparing LastHash with one kept elsewhere tells it.
+func (store *SQLStore) VerifyAuditLog(ctx context.Context) (AuditLogVerification, error) {
+	var result AuditLogVerification
+	var lastSeq int64
+	for {
+		entries, err := store.ListChainedAuditLog(ctx, ListChainedAuditLogParams{
+			AfterSeq: lastSeq,
+			Limit:    verifyAuditLogBatchSize,
+		})
+		if err != nil {
+			return result, err
+		}
+
+		for _, entry := range entries {
+			if entry.PrevHash != result.LastHash {
+				return result, fmt.Errorf("%w: entry ID %d is not chained to the entry before it", ErrAuditChainBroken, entry.ID)
+			}
+
+			hash, err := auditHash(entry)
+			if err != nil {
+				return result, err
+			}
+			if hash != entry.Hash {
+				return result, fmt.Errorf("%w: entry ID %d does not match its hash", ErrAuditChainBroken, entry.ID)
+			}
+
+			result.Entries++
+			result.LastID, result.LastHash = entry.ID, entry.Hash
+			lastSeq = *entry.ChainSeq
+		}
+
+		if len(entries) < verifyAuditLogBatchSize {
+			break
+		}
+	}
+
+	var err error
+	result.Unchained, err = store.CountUnchainedAuditLog(ctx)
+	return result, err
+}
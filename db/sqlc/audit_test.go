@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/logging"
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// findAuditLog returns the entries about the resource, oldest first.
+func findAuditLog(t *testing.T, store Store, resourceType, resourceID string) []AuditLog {
+	entries, err := store.ListAuditLog(context.Background(), ListAuditLogParams{
+		ResourceType: sql.NullString{String: resourceType, Valid: true},
+		ResourceID:   sql.NullString{String: resourceID, Valid: true},
+		Limit:        10,
+	})
+	require.NoError(t, err)
+
+	return entries
+}
+
+// chainAll chains every entry of the audit log written so far.
+func chainAll(t *testing.T, store Store) {
+	_, err := store.ChainAuditLog(context.Background(), DefaultAuditChainBatchSize)
+	require.NoError(t, err)
+}
+
+func TestCreateAccountTxWritesAudit(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	ctx := WithAuditActor(context.Background(), AuditActor{Username: user.Username, ClientIP: "192.0.2.1"})
+	ctx = logging.WithRequestID(ctx, util.RandomString(16))
+	account, err := store.CreateAccountTx(ctx, CreateAccountParams{Owner: user.Username, Currency: util.EUR})
+	require.NoError(t, err)
+
+	entries := findAuditLog(t, store, ResourceAccount, auditID(account.ID))
+	require.Len(t, entries, 1)
+
+	entry := entries[0]
+	require.Equal(t, user.Username, entry.Actor)
+	require.Equal(t, AuditAccountCreate, entry.Action)
+	require.Equal(t, logging.RequestID(ctx), entry.RequestID)
+	require.Equal(t, "192.0.2.1", entry.ClientIp)
+	require.Nil(t, entry.Before)
+	require.NotNil(t, entry.After)
+
+	var after Account
+	require.NoError(t, json.Unmarshal(*entry.After, &after))
+	require.Equal(t, account.ID, after.ID)
+}
+
+func TestChangeAccountStatusTxWritesAudit(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccount(t)
+	banker := createRandomUser(t)
+
+	_, err := store.ChangeAccountStatusTx(context.Background(), ChangeAccountStatusTxParams{
+		AccountID: account.ID,
+		Status:    AccountStatusFrozen,
+		Reason:    "suspicious activity",
+		ChangedBy: banker.Username,
+	})
+	require.NoError(t, err)
+
+	entries := findAuditLog(t, store, ResourceAccount, auditID(account.ID))
+	require.Len(t, entries, 1)
+	// without an actor in the ctx, the change is made by the system
+	require.Equal(t, SystemActor, entries[0].Actor)
+	require.Equal(t, AuditAccountStatusChange, entries[0].Action)
+
+	var before, after Account
+	require.NoError(t, json.Unmarshal(*entries[0].Before, &before))
+	require.NoError(t, json.Unmarshal(*entries[0].After, &after))
+	require.Equal(t, AccountStatusActive, before.Status)
+	require.Equal(t, AccountStatusFrozen, after.Status)
+}
+
+func TestAuditedQueriesHideSecrets(t *testing.T) {
+	store := NewStore(testDB)
+
+	hashedPassword, err := util.HashPassword(util.RandomString(6))
+	require.NoError(t, err)
+	user, err := store.CreateUser(context.Background(), CreateUserParams{
+		Username:       util.RandomOwner(),
+		HashedPassword: hashedPassword,
+		FullName:       util.RandomOwner(),
+		Email:          util.RandomEmail(),
+	})
+	require.NoError(t, err)
+
+	entries := findAuditLog(t, store, ResourceUser, user.Username)
+	require.Len(t, entries, 1)
+	require.Equal(t, AuditUserCreate, entries[0].Action)
+	require.NotContains(t, string(*entries[0].After), "password\"")
+	require.NotContains(t, string(*entries[0].After), hashedPassword)
+
+	subscription, err := store.CreateWebhookSubscription(context.Background(), CreateWebhookSubscriptionParams{
+		Owner:      user.Username,
+		Url:        "https://partner.example.com/hooks",
+		Secret:     "whsec_" + util.RandomString(32),
+		EventTypes: []string{EventAccountCreated},
+	})
+	require.NoError(t, err)
+
+	err = store.DeleteWebhookSubscription(context.Background(), subscription.ID)
+	require.NoError(t, err)
+
+	entries = findAuditLog(t, store, ResourceWebhook, auditID(subscription.ID))
+	require.Len(t, entries, 2)
+	require.Equal(t, AuditWebhookCreate, entries[0].Action)
+	require.Equal(t, AuditWebhookDelete, entries[1].Action)
+	require.Nil(t, entries[1].After)
+	require.NotContains(t, string(*entries[0].After), subscription.Secret)
+	require.NotContains(t, string(*entries[1].Before), subscription.Secret)
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	store := NewStore(testDB)
+	_, err := store.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    createRandomUser(t).Username,
+		Currency: util.USD,
+	})
+	require.NoError(t, err)
+
+	_, err = testDB.Exec("UPDATE audit_log SET actor = 'someone else' WHERE id = (SELECT max(id) FROM audit_log)")
+	require.Error(t, err)
+
+	_, err = testDB.Exec("DELETE FROM audit_log WHERE id = (SELECT max(id) FROM audit_log)")
+	require.Error(t, err)
+}
+
+func TestChainAuditLog(t *testing.T) {
+	store := NewStore(testDB)
+	chainAll(t, store)
+
+	account1, err := store.CreateAccountTx(context.Background(), CreateAccountParams{Owner: createRandomUser(t).Username, Currency: util.EUR})
+	require.NoError(t, err)
+	account2, err := store.CreateAccountTx(context.Background(), CreateAccountParams{Owner: createRandomUser(t).Username, Currency: util.EUR})
+	require.NoError(t, err)
+
+	// the entries are written without being chained
+	entry1 := findAuditLog(t, store, ResourceAccount, auditID(account1.ID))[0]
+	entry2 := findAuditLog(t, store, ResourceAccount, auditID(account2.ID))[0]
+	require.Nil(t, entry1.ChainSeq)
+	require.Empty(t, entry1.Hash)
+
+	chained, err := store.ChainAuditLog(context.Background(), 1)
+	require.NoError(t, err)
+	// other tests may have written entries meanwhile
+	require.GreaterOrEqual(t, chained, 2)
+
+	entry1 = findAuditLog(t, store, ResourceAccount, auditID(account1.ID))[0]
+	entry2 = findAuditLog(t, store, ResourceAccount, auditID(account2.ID))[0]
+	require.NotNil(t, entry1.ChainSeq)
+	require.NotNil(t, entry2.ChainSeq)
+	require.Greater(t, *entry2.ChainSeq, *entry1.ChainSeq)
+
+	hash, err := auditHash(entry1)
+	require.NoError(t, err)
+	require.Equal(t, entry1.Hash, hash)
+	if *entry2.ChainSeq == *entry1.ChainSeq+1 {
+		require.Equal(t, entry1.Hash, entry2.PrevHash)
+	}
+
+	// a chained entry is not chained again
+	_, err = testDB.Exec("UPDATE audit_log SET hash = 'other' WHERE id = $1", entry1.ID)
+	require.Error(t, err)
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	store := NewStore(testDB)
+	account := createRandomAccountWithCurrency(t, 100, util.EUR)
+	_, err := store.UpdateAccount(context.Background(), UpdateAccountParams{ID: account.ID, Balance: 200})
+	require.NoError(t, err)
+
+	entries := findAuditLog(t, store, ResourceAccount, auditID(account.ID))
+	require.Len(t, entries, 1)
+
+	chainAll(t, store)
+	result, err := store.VerifyAuditLog(context.Background())
+	require.NoError(t, err)
+	require.Positive(t, result.Entries)
+	// other tests may have written entries meanwhile
+	require.GreaterOrEqual(t, result.LastID, entries[0].ID)
+	require.Len(t, result.LastHash, 64)
+}
+
+func TestAuditHash(t *testing.T) {
+	before := json.RawMessage(`{"id":1,"status":"active"}`)
+	after := json.RawMessage(`{"id":1,"status":"frozen"}`)
+	entry := AuditLog{
+		Actor:        "banker",
+		Action:       AuditAccountStatusChange,
+		ResourceType: ResourceAccount,
+		ResourceID:   "1",
+		Before:       &before,
+		After:        &after,
+		RequestID:    "request-1",
+		ClientIp:     "192.0.2.1",
+		CreatedAt:    time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC),
+		PrevHash:     "previous",
+	}
+
+	hash, err := auditHash(entry)
+	require.NoError(t, err)
+	require.Len(t, hash, 64)
+
+	// the instant is hashed, whatever the time zone it is read back in
+	readBack := entry
+	readBack.ID = 42
+	readBack.CreatedAt = entry.CreatedAt.In(time.FixedZone("CET", 3600))
+	readBackHash, err := auditHash(readBack)
+	require.NoError(t, err)
+	require.Equal(t, hash, readBackHash)
+
+	tampered := []func(entry *AuditLog){
+		func(entry *AuditLog) { entry.PrevHash = "other" },
+		func(entry *AuditLog) { entry.Actor = "someone else" },
+		func(entry *AuditLog) { entry.Action = AuditAccountDelete },
+		func(entry *AuditLog) { entry.ResourceID = "2" },
+		func(entry *AuditLog) { entry.Before = nil },
+		func(entry *AuditLog) { entry.After = &before },
+		func(entry *AuditLog) { entry.RequestID = "" },
+		func(entry *AuditLog) { entry.ClientIp = "192.0.2.2" },
+		func(entry *AuditLog) { entry.CreatedAt = entry.CreatedAt.Add(time.Microsecond) },
+	}
+	for _, tamper := range tampered {
+		changed := entry
+		tamper(&changed)
+
+		changedHash, err := auditHash(changed)
+		require.NoError(t, err)
+		require.NotEqual(t, hash, changedHash)
+	}
+}
+
+func TestAuditActorFrom(t *testing.T) {
+	require.Equal(t, AuditActor{Username: SystemActor}, AuditActorFrom(context.Background()))
+
+	actor := AuditActor{Username: "depositor", ClientIP: "192.0.2.1"}
+	require.Equal(t, actor, AuditActorFrom(WithAuditActor(context.Background(), actor)))
+}
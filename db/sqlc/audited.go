@@ -0,0 +1,273 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The methods below shadow the queries of the Querier that change data on their own, outside of the txs of the store.
+// Each runs its query and audits the change within a single db tx. The queries run by the txs of the store
+// are audited by the txs themselves.
+
+// auditedUser is a user as written to the audit log, without the hash of the password.
+type auditedUser struct {
+	Username          string    `json:"username"`
+	FullName          string    `json:"full_name"`
+	Email             string    `json:"email"`
+	Role              string    `json:"role"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// auditedSession is a session as written to the audit log, without the refresh token.
+type auditedSession struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	UserAgent string    `json:"user_agent"`
+	ClientIp  string    `json:"client_ip"`
+	IsBlocked bool      `json:"is_blocked"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// auditedWebhookSubscription is a webhook subscription as written to the audit log, without the secret.
+type auditedWebhookSubscription struct {
+	ID         int64     `json:"id"`
+	Owner      string    `json:"owner"`
+	Url        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (store *SQLStore) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	var user User
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		user, err = queries.CreateUser(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditUserCreate, ResourceUser, user.Username, nil, auditedUser{
+			Username:          user.Username,
+			FullName:          user.FullName,
+			Email:             user.Email,
+			Role:              user.Role,
+			PasswordChangedAt: user.PasswordChangedAt,
+			CreatedAt:         user.CreatedAt,
+		})
+	})
+
+	return user, err
+}
+
+func (store *SQLStore) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	var session Session
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		session, err = queries.CreateSession(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditSessionCreate, ResourceSession, session.ID.String(), nil, auditedSession{
+			ID:        session.ID,
+			Username:  session.Username,
+			UserAgent: session.UserAgent,
+			ClientIp:  session.ClientIp,
+			IsBlocked: session.IsBlocked,
+			ExpiresAt: session.ExpiresAt,
+			CreatedAt: session.CreatedAt,
+		})
+	})
+
+	return session, err
+}
+
+func (store *SQLStore) BlockUserSessions(ctx context.Context, username string) (int64, error) {
+	var blocked int64
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		blocked, err = queries.BlockUserSessions(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditUserSessionsRevoke, ResourceUser, username, nil, struct {
+			RevokedSessions int64 `json:"revoked_sessions"`
+		}{blocked})
+	})
+
+	return blocked, err
+}
+
+func (store *SQLStore) CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error) {
+	var account Account
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		account, err = queries.CreateAccount(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditAccountCreate, ResourceAccount, auditID(account.ID), nil, account)
+	})
+
+	return account, err
+}
+
+func (store *SQLStore) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error) {
+	var account Account
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		before, err := queries.GetAccountForUpdate(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+
+		account, err = queries.UpdateAccount(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditAccountUpdate, ResourceAccount, auditID(account.ID), before, account)
+	})
+
+	return account, err
+}
+
+// DeleteAccount deletes the account if it exists, a missing account is not an error.
+func (store *SQLStore) DeleteAccount(ctx context.Context, id int64) error {
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		before, err := queries.GetAccountForUpdate(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				// nothing to delete
+				return nil
+			}
+			return err
+		}
+
+		err = queries.DeleteAccount(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditAccountDelete, ResourceAccount, auditID(id), before, nil)
+	})
+
+	return err
+}
+
+func (store *SQLStore) CreateScheduledTransfer(ctx context.Context, arg CreateScheduledTransferParams) (ScheduledTransfer, error) {
+	var scheduled ScheduledTransfer
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		scheduled, err = queries.CreateScheduledTransfer(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditScheduledTransferCreate, ResourceScheduledTransfer, auditID(scheduled.ID), nil, scheduled)
+	})
+
+	return scheduled, err
+}
+
+func (store *SQLStore) CancelScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error) {
+	var scheduled ScheduledTransfer
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		scheduled, err = queries.CancelScheduledTransfer(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// only an active scheduled transfer is cancelled, and nothing but its status changes
+		before := scheduled
+		before.Status = ScheduledTransferStatusActive
+		return writeAudit(ctx, queries, AuditScheduledTransferCancel, ResourceScheduledTransfer, auditID(id), before, scheduled)
+	})
+
+	return scheduled, err
+}
+
+func (store *SQLStore) CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error) {
+	var subscription WebhookSubscription
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		subscription, err = queries.CreateWebhookSubscription(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditWebhookCreate, ResourceWebhook, auditID(subscription.ID), nil, newAuditedWebhookSubscription(subscription))
+	})
+
+	return subscription, err
+}
+
+// DeleteWebhookSubscription deletes the subscription if it exists, a missing subscription is not an error.
+func (store *SQLStore) DeleteWebhookSubscription(ctx context.Context, id int64) error {
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		before, err := queries.GetWebhookSubscription(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				// nothing to delete
+				return nil
+			}
+			return err
+		}
+
+		err = queries.DeleteWebhookSubscription(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditWebhookDelete, ResourceWebhook, auditID(id), newAuditedWebhookSubscription(before), nil)
+	})
+
+	return err
+}
+
+func (store *SQLStore) ReplayWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error) {
+	var delivery WebhookDelivery
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		// the row stays locked until the end of the tx, so the state audited is the one replayed
+		before, err := queries.GetWebhookDeliveryForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		delivery, err = queries.ReplayWebhookDelivery(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditWebhookDeliveryReplay, ResourceWebhookDelivery, auditID(id), before, delivery)
+	})
+
+	return delivery, err
+}
+
+func newAuditedWebhookSubscription(subscription WebhookSubscription) auditedWebhookSubscription {
+	return auditedWebhookSubscription{
+		ID:         subscription.ID,
+		Owner:      subscription.Owner,
+		Url:        subscription.Url,
+		EventTypes: subscription.EventTypes,
+		CreatedAt:  subscription.CreatedAt,
+	}
+}
@@ -70,3 +70,37 @@ func TestQueries_ListEntries(t *testing.T) {
 		require.Equal(t, accountExpected.ID, entry.AccountID)
 	}
 }
+
+func TestQueries_ListEntriesAfter(t *testing.T) {
+	// given
+	account := createRandomAccount(t)
+	for i := 0; i < 10; i++ {
+		createRandomEntry(account, t)
+	}
+
+	// test: page through all entries, starting before the first one
+	var listed []Entry
+	arg := ListEntriesAfterParams{AccountID: account.ID, Limit: 4}
+	for {
+		entries, err := testQueries.ListEntriesAfter(context.Background(), arg)
+		require.NoError(t, err)
+		if len(entries) == 0 {
+			break
+		}
+		listed = append(listed, entries...)
+
+		last := entries[len(entries)-1]
+		arg.AfterCreatedAt, arg.AfterID = last.CreatedAt, last.ID
+	}
+
+	// assert
+	require.Len(t, listed, 10)
+	for i, entry := range listed {
+		require.Equal(t, account.ID, entry.AccountID)
+		if i > 0 {
+			previous := listed[i-1]
+			require.True(t, previous.CreatedAt.Before(entry.CreatedAt) ||
+				previous.CreatedAt.Equal(entry.CreatedAt) && previous.ID < entry.ID)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// getMigrationVersion reads the table golang-migrate keeps its state in.
+// It is not a sqlc query, as the table is not part of the migrations.
+const getMigrationVersion = `SELECT version, dirty FROM schema_migrations LIMIT 1`
+
+// Ping checks the db can be reached.
+func (store *SQLStore) Ping(ctx context.Context) error {
+	return store.db.PingContext(ctx)
+}
+
+// Stats returns the connection pool statistics.
+func (store *SQLStore) Stats() sql.DBStats {
+	return store.db.Stats()
+}
+
+// MigrationVersion returns the version of the last applied migration, and whether it failed halfway.
+// The version is 0 if no migration was applied.
+func (store *SQLStore) MigrationVersion(ctx context.Context) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := store.db.QueryRowContext(ctx, getMigrationVersion).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Health(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	err := store.Ping(ctx)
+	require.NoError(t, err)
+
+	version, dirty, err := store.MigrationVersion(ctx)
+	require.NoError(t, err)
+	require.Positive(t, version)
+	require.False(t, dirty)
+
+	require.Positive(t, store.Stats().OpenConnections)
+}
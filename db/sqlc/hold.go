@@ -0,0 +1,363 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Hold statuses. A hold is pending until it is captured, voided or expired, all of which are final.
+const (
+	HoldStatusPending  = "pending"
+	HoldStatusCaptured = "captured"
+	HoldStatusVoided   = "voided"
+	HoldStatusExpired  = "expired"
+)
+
+// expireHoldsBatchSize is how many expired holds ExpireHolds looks up at a time.
+const expireHoldsBatchSize = 100
+
+var (
+	// ErrHoldNotPending is returned when capturing or voiding a hold that was already captured, voided or expired.
+	ErrHoldNotPending = errors.New("hold is not pending")
+	// ErrHoldExpired is returned when capturing a hold past its expiry.
+	ErrHoldExpired = errors.New("hold has expired")
+	// ErrInvalidCaptureAmount is returned when capturing more than the held amount, or a negative one.
+	ErrInvalidCaptureAmount = errors.New("capture amount must be positive and at most the held amount")
+	// ErrInvalidHoldAmount is returned when authorizing a hold of zero or a negative amount.
+	ErrInvalidHoldAmount = errors.New("hold amount must be positive")
+	// ErrHoldToSameAccount is returned when authorizing a hold whose money would go to the held account.
+	ErrHoldToSameAccount = errors.New("hold must be to another account")
+)
+
+type AuthorizeHoldTxParams struct {
+	AccountID int64 `json:"account_id"`
+	// ToAccountID is the account the money goes to once the hold is captured.
+	ToAccountID int64     `json:"to_account_id"`
+	Amount      int64     `json:"amount"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type HoldTxResult struct {
+	Hold    Hold    `json:"hold"`
+	Account Account `json:"account"`
+}
+
+// AuthorizeHoldTx reserves money on an account, to be captured or voided later.
+// The money stays in the balance of the account, but is no longer part of its available balance.
+// The tx is rolled back with ErrInsufficientFunds if the available balance does not cover the amount,
+// with ErrAccountNotActive if either account is frozen or closed, and with ErrCurrencyMismatch
+// if the accounts have different currencies.
+// A hold must be of a positive amount, to another account.
+func (store *SQLStore) AuthorizeHoldTx(ctx context.Context, arg AuthorizeHoldTxParams) (HoldTxResult, error) {
+	var result HoldTxResult
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		result = HoldTxResult{}
+
+		// a negative amount would raise the available balance
+		if arg.Amount <= 0 {
+			return ErrInvalidHoldAmount
+		}
+		if arg.AccountID == arg.ToAccountID {
+			return ErrHoldToSameAccount
+		}
+
+		result.Hold, err = queries.CreateHold(ctx, CreateHoldParams{
+			AccountID:   arg.AccountID,
+			ToAccountID: arg.ToAccountID,
+			Amount:      arg.Amount,
+			ExpiresAt:   arg.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		// the update holds the row lock, so concurrent holds and transfers see each other's amounts
+		result.Account, err = queries.AddAccountHeldBalance(ctx, AddAccountHeldBalanceParams{
+			ID:     arg.AccountID,
+			Amount: arg.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		if result.Account.Status != AccountStatusActive {
+			return ErrAccountNotActive
+		}
+
+		if result.Account.AvailableBalance < 0 {
+			return ErrInsufficientFunds
+		}
+
+		toAccount, err := queries.GetAccount(ctx, arg.ToAccountID)
+		if err != nil {
+			return err
+		}
+
+		if toAccount.Status != AccountStatusActive {
+			return ErrAccountNotActive
+		}
+
+		if toAccount.Currency != result.Account.Currency {
+			return ErrCurrencyMismatch
+		}
+
+		return writeAudit(ctx, queries, AuditHoldAuthorize, ResourceHold, auditID(result.Hold.ID), nil, result.Hold)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+type CaptureHoldTxParams struct {
+	HoldID int64 `json:"hold_id"`
+	// Amount is the amount to capture, zero capturing the whole hold.
+	// The rest of a partially captured hold is released.
+	Amount int64 `json:"amount"`
+}
+
+type CaptureHoldTxResult struct {
+	Hold        Hold     `json:"hold"`
+	Transfer    Transfer `json:"transfer"`
+	FromAccount Account  `json:"from_account"`
+	ToAccount   Account  `json:"to_account"`
+	FromEntry   Entry    `json:"from_entry"`
+	ToEntry     Entry    `json:"to_entry"`
+}
+
+// CaptureHoldTx settles a pending hold, fully or partially, with a transfer to the account given when it was authorized.
+// The whole hold is released in the same db tx, so a hold can be captured only once.
+// The tx is rolled back with ErrHoldNotPending if the hold was already captured, voided or expired,
+// with ErrHoldExpired if it is past its expiry, and with ErrAccountNotActive if either account is frozen or closed.
+func (store *SQLStore) CaptureHoldTx(ctx context.Context, arg CaptureHoldTxParams) (CaptureHoldTxResult, error) {
+	var result CaptureHoldTxResult
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		result = CaptureHoldTxResult{}
+
+		// lock the hold first, so a concurrent capture or void waits and then finds it settled
+		hold, err := queries.GetHoldForUpdate(ctx, arg.HoldID)
+		if err != nil {
+			return err
+		}
+
+		if hold.Status != HoldStatusPending {
+			return ErrHoldNotPending
+		}
+
+		if !time.Now().Before(hold.ExpiresAt) {
+			return ErrHoldExpired
+		}
+
+		amount := arg.Amount
+		if amount == 0 {
+			amount = hold.Amount
+		}
+		if amount < 0 || amount > hold.Amount {
+			return ErrInvalidCaptureAmount
+		}
+
+		result.Transfer, result.FromEntry, result.ToEntry, err = recordTransfer(ctx, queries, CreateTransferParams{
+			FromAccountID: hold.AccountID,
+			ToAccountID:   hold.ToAccountID,
+			Amount:        amount,
+			ToAmount:      amount,
+			ExchangeRate:  sameCurrencyRate,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.FromAccount, result.ToAccount, err = captureMoney(ctx, queries, hold, amount)
+		if err != nil {
+			return err
+		}
+
+		if result.FromAccount.Status != AccountStatusActive || result.ToAccount.Status != AccountStatusActive {
+			return ErrAccountNotActive
+		}
+
+		err = writeTransferEvents(ctx, queries, result.Transfer, result.FromAccount, result.FromEntry, result.ToAccount, result.ToEntry)
+		if err != nil {
+			return err
+		}
+
+		result.Hold, err = queries.UpdateHold(ctx, UpdateHoldParams{
+			Status:         HoldStatusCaptured,
+			CapturedAmount: amount,
+			TransferID:     &result.Transfer.ID,
+			ID:             hold.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = writeAudit(ctx, queries, AuditTransferCreate, ResourceTransfer, auditID(result.Transfer.ID), nil, result.Transfer)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditHoldCapture, ResourceHold, auditID(hold.ID), hold, result.Hold)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// captureMoney releases the hold and moves the captured amount.
+// Like addMoney, it updates the accounts in the order of their IDs to avoid deadlocks.
+func captureMoney(ctx context.Context, queries *Queries, hold Hold, amount int64) (Account, Account, error) {
+	debit := func() (Account, error) {
+		_, err := queries.AddAccountHeldBalance(ctx, AddAccountHeldBalanceParams{ID: hold.AccountID, Amount: -hold.Amount})
+		if err != nil {
+			return Account{}, err
+		}
+		return queries.AddAccountBalance(ctx, AddAccountBalanceParams{ID: hold.AccountID, Amount: -amount})
+	}
+	credit := func() (Account, error) {
+		return queries.AddAccountBalance(ctx, AddAccountBalanceParams{ID: hold.ToAccountID, Amount: amount})
+	}
+
+	var fromAccount, toAccount Account
+	var err error
+	if hold.AccountID < hold.ToAccountID {
+		fromAccount, err = debit()
+		if err != nil {
+			return Account{}, Account{}, err
+		}
+		toAccount, err = credit()
+	} else {
+		toAccount, err = credit()
+		if err != nil {
+			return Account{}, Account{}, err
+		}
+		fromAccount, err = debit()
+	}
+	if err != nil {
+		return Account{}, Account{}, err
+	}
+
+	return fromAccount, toAccount, nil
+}
+
+// VoidHoldTx releases a pending hold without moving any money.
+// The tx is rolled back with ErrHoldNotPending if the hold was already captured, voided or expired.
+func (store *SQLStore) VoidHoldTx(ctx context.Context, holdID int64) (HoldTxResult, error) {
+	var result HoldTxResult
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		result = HoldTxResult{}
+
+		hold, err := queries.GetHoldForUpdate(ctx, holdID)
+		if err != nil {
+			return err
+		}
+
+		if hold.Status != HoldStatusPending {
+			return ErrHoldNotPending
+		}
+
+		result.Hold, result.Account, err = releaseHold(ctx, queries, hold, HoldStatusVoided)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditHoldVoid, ResourceHold, auditID(hold.ID), hold, result.Hold)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// ExpireHolds releases the pending holds that expired by now, each in a db tx of its own.
+// Holds captured or voided in the meantime are skipped. It returns the number of holds expired.
+func (store *SQLStore) ExpireHolds(ctx context.Context, now time.Time) (int, error) {
+	expired := 0
+	for {
+		holdIDs, err := store.ListExpiredHolds(ctx, ListExpiredHoldsParams{
+			ExpiredAt: now,
+			Limit:     expireHoldsBatchSize,
+		})
+		if err != nil {
+			return expired, err
+		}
+
+		for _, holdID := range holdIDs {
+			ok, err := store.expireHold(ctx, holdID, now)
+			if err != nil {
+				return expired, err
+			}
+			if ok {
+				expired++
+			}
+		}
+
+		if len(holdIDs) < expireHoldsBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// expireHold releases a hold if it is still pending and expired by now.
+func (store *SQLStore) expireHold(ctx context.Context, holdID int64, now time.Time) (bool, error) {
+	var ok bool
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		ok = false
+
+		hold, err := queries.GetHoldForUpdate(ctx, holdID)
+		if err != nil {
+			return err
+		}
+
+		if hold.Status != HoldStatusPending || hold.ExpiresAt.After(now) {
+			return nil
+		}
+
+		expired, _, err := releaseHold(ctx, queries, hold, HoldStatusExpired)
+		if err != nil {
+			return err
+		}
+
+		err = writeAudit(ctx, queries, AuditHoldExpire, ResourceHold, auditID(hold.ID), hold, expired)
+		if err != nil {
+			return err
+		}
+
+		ok = true
+		return nil
+	})
+
+	return ok, err
+}
+
+// releaseHold gives the held amount back to the available balance of the account and settles the hold with the status.
+func releaseHold(ctx context.Context, queries *Queries, hold Hold, status string) (Hold, Account, error) {
+	account, err := queries.AddAccountHeldBalance(ctx, AddAccountHeldBalanceParams{
+		ID:     hold.AccountID,
+		Amount: -hold.Amount,
+	})
+	if err != nil {
+		return Hold{}, Account{}, err
+	}
+
+	hold, err = queries.UpdateHold(ctx, UpdateHoldParams{
+		Status: status,
+		ID:     hold.ID,
+	})
+	if err != nil {
+		return Hold{}, Account{}, err
+	}
+
+	return hold, account, nil
+}
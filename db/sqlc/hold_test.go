@@ -0,0 +1,323 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func authorizeRandomHold(t *testing.T, store Store, account, toAccount Account, amount int64, expiresAt time.Time) Hold {
+	result, err := store.AuthorizeHoldTx(context.Background(), AuthorizeHoldTxParams{
+		AccountID:   account.ID,
+		ToAccountID: toAccount.ID,
+		Amount:      amount,
+		ExpiresAt:   expiresAt,
+	})
+	require.NoError(t, err)
+
+	hold := result.Hold
+	require.NotZero(t, hold.ID)
+	require.Equal(t, account.ID, hold.AccountID)
+	require.Equal(t, toAccount.ID, hold.ToAccountID)
+	require.Equal(t, amount, hold.Amount)
+	require.Equal(t, HoldStatusPending, hold.Status)
+	require.Nil(t, hold.TransferID)
+	require.WithinDuration(t, expiresAt, hold.ExpiresAt, time.Second)
+
+	return hold
+}
+
+func TestStore_AuthorizeHoldTx(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithCurrency(t, 100, util.EUR)
+	toAccount := createRandomAccountWithCurrency(t, 0, util.EUR)
+
+	result, err := store.AuthorizeHoldTx(ctx, AuthorizeHoldTxParams{
+		AccountID:   account.ID,
+		ToAccountID: toAccount.ID,
+		Amount:      30,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	// the money stays in the balance, but is no longer available
+	require.Equal(t, int64(100), result.Account.Balance)
+	require.Equal(t, int64(30), result.Account.HeldBalance)
+	require.Equal(t, int64(70), result.Account.AvailableBalance)
+
+	hold, err := store.GetHold(ctx, result.Hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.Hold, hold)
+}
+
+func TestAuthorizeHoldTxInsufficientFunds(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithCurrency(t, 100, util.EUR)
+	toAccount := createRandomAccountWithCurrency(t, 0, util.EUR)
+
+	n := 10
+	amount := int64(30)
+	errs := make(chan error)
+
+	// holds and transfers compete for the same available balance
+	for i := 0; i < n; i++ {
+		i := i
+		go func() {
+			var err error
+			if i%2 == 0 {
+				_, err = store.AuthorizeHoldTx(ctx, AuthorizeHoldTxParams{
+					AccountID:   account.ID,
+					ToAccountID: toAccount.ID,
+					Amount:      amount,
+					ExpiresAt:   time.Now().Add(time.Hour),
+				})
+			} else {
+				_, err = store.TransferTx(ctx, TransferTxParams{
+					FromAccountID: account.ID,
+					ToAccountID:   toAccount.ID,
+					Amount:        amount,
+				})
+			}
+			errs <- err
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err != nil {
+			require.ErrorIs(t, err, ErrInsufficientFunds)
+			continue
+		}
+		succeeded++
+	}
+
+	// only as many as the initial balance covers may succeed
+	require.Equal(t, int(account.Balance/amount), succeeded)
+
+	updatedAccount, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance-int64(succeeded)*amount, updatedAccount.AvailableBalance)
+	require.GreaterOrEqual(t, updatedAccount.AvailableBalance, int64(0))
+}
+
+func TestAuthorizeHoldTxAccountNotActive(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithCurrency(t, 100, util.EUR)
+	toAccount := createRandomAccountWithCurrency(t, 0, util.EUR)
+	banker := createRandomUser(t)
+
+	_, err := store.ChangeAccountStatusTx(ctx, ChangeAccountStatusTxParams{
+		AccountID: toAccount.ID,
+		Status:    AccountStatusFrozen,
+		Reason:    "court order",
+		ChangedBy: banker.Username,
+	})
+	require.NoError(t, err)
+
+	_, err = store.AuthorizeHoldTx(ctx, AuthorizeHoldTxParams{
+		AccountID:   account.ID,
+		ToAccountID: toAccount.ID,
+		Amount:      30,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, ErrAccountNotActive)
+
+	updatedAccount, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Zero(t, updatedAccount.HeldBalance)
+}
+
+func TestAuthorizeHoldTxInvalid(t *testing.T) {
+	account := createRandomAccountWithCurrency(t, 100, util.EUR)
+	toAccount := createRandomAccountWithCurrency(t, 0, util.EUR)
+
+	testCases := []struct {
+		name        string
+		toAccountID int64
+		amount      int64
+		expectedErr error
+	}{
+		{"ZeroAmount", toAccount.ID, 0, ErrInvalidHoldAmount},
+		{"NegativeAmount", toAccount.ID, -30, ErrInvalidHoldAmount},
+		{"SameAccount", account.ID, 30, ErrHoldToSameAccount},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewStore(testDB)
+
+			_, err := store.AuthorizeHoldTx(ctx, AuthorizeHoldTxParams{
+				AccountID:   account.ID,
+				ToAccountID: tc.toAccountID,
+				Amount:      tc.amount,
+				ExpiresAt:   time.Now().Add(time.Hour),
+			})
+			require.ErrorIs(t, err, tc.expectedErr)
+
+			updatedAccount, err := store.GetAccount(ctx, account.ID)
+			require.NoError(t, err)
+			require.Zero(t, updatedAccount.HeldBalance)
+			require.Equal(t, account.Balance, updatedAccount.AvailableBalance)
+		})
+	}
+}
+
+func TestStore_CaptureHoldTx(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithCurrency(t, 100, util.EUR)
+	toAccount := createRandomAccountWithCurrency(t, 0, util.EUR)
+	hold := authorizeRandomHold(t, store, account, toAccount, 30, time.Now().Add(time.Hour))
+
+	// capturing more than held
+	_, err := store.CaptureHoldTx(ctx, CaptureHoldTxParams{HoldID: hold.ID, Amount: 31})
+	require.ErrorIs(t, err, ErrInvalidCaptureAmount)
+
+	// partial capture releases the rest
+	result, err := store.CaptureHoldTx(ctx, CaptureHoldTxParams{HoldID: hold.ID, Amount: 20})
+	require.NoError(t, err)
+
+	require.Equal(t, HoldStatusCaptured, result.Hold.Status)
+	require.Equal(t, int64(20), result.Hold.CapturedAmount)
+	require.NotNil(t, result.Hold.TransferID)
+	require.Equal(t, result.Transfer.ID, *result.Hold.TransferID)
+
+	assertTransfer(t, result.Transfer, account, toAccount, 20)
+	assertEntry(t, result.FromEntry, account, -20)
+	assertEntry(t, result.ToEntry, toAccount, 20)
+
+	require.Equal(t, int64(80), result.FromAccount.Balance)
+	require.Zero(t, result.FromAccount.HeldBalance)
+	require.Equal(t, int64(80), result.FromAccount.AvailableBalance)
+	require.Equal(t, int64(20), result.ToAccount.Balance)
+
+	// captured holds are settled
+	_, err = store.CaptureHoldTx(ctx, CaptureHoldTxParams{HoldID: hold.ID})
+	require.ErrorIs(t, err, ErrHoldNotPending)
+	_, err = store.VoidHoldTx(ctx, hold.ID)
+	require.ErrorIs(t, err, ErrHoldNotPending)
+}
+
+func TestCaptureHoldTxConcurrent(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithCurrency(t, 100, util.EUR)
+	toAccount := createRandomAccountWithCurrency(t, 0, util.EUR)
+	hold := authorizeRandomHold(t, store, account, toAccount, 30, time.Now().Add(time.Hour))
+
+	// captures and voids race for the same hold
+	n := 10
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		i := i
+		go func() {
+			var err error
+			if i%2 == 0 {
+				_, err = store.CaptureHoldTx(ctx, CaptureHoldTxParams{HoldID: hold.ID})
+			} else {
+				_, err = store.VoidHoldTx(ctx, hold.ID)
+			}
+			errs <- err
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err != nil {
+			require.ErrorIs(t, err, ErrHoldNotPending)
+			continue
+		}
+		succeeded++
+	}
+	require.Equal(t, 1, succeeded)
+
+	settledHold, err := store.GetHold(ctx, hold.ID)
+	require.NoError(t, err)
+	updatedAccount, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	updatedToAccount, err := store.GetAccount(ctx, toAccount.ID)
+	require.NoError(t, err)
+
+	// the hold is released exactly once, whoever won
+	require.Zero(t, updatedAccount.HeldBalance)
+	require.Equal(t, account.Balance-settledHold.CapturedAmount, updatedAccount.Balance)
+	require.Equal(t, toAccount.Balance+settledHold.CapturedAmount, updatedToAccount.Balance)
+}
+
+func TestCaptureHoldTxExpired(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithCurrency(t, 100, util.EUR)
+	toAccount := createRandomAccountWithCurrency(t, 0, util.EUR)
+	hold := authorizeRandomHold(t, store, account, toAccount, 30, time.Now().Add(-time.Second))
+
+	_, err := store.CaptureHoldTx(ctx, CaptureHoldTxParams{HoldID: hold.ID})
+	require.ErrorIs(t, err, ErrHoldExpired)
+
+	// nothing was moved
+	updatedAccount, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance, updatedAccount.Balance)
+	require.Equal(t, int64(30), updatedAccount.HeldBalance)
+}
+
+func TestStore_VoidHoldTx(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithCurrency(t, 100, util.EUR)
+	toAccount := createRandomAccountWithCurrency(t, 0, util.EUR)
+	hold := authorizeRandomHold(t, store, account, toAccount, 30, time.Now().Add(time.Hour))
+
+	result, err := store.VoidHoldTx(ctx, hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusVoided, result.Hold.Status)
+	require.Zero(t, result.Hold.CapturedAmount)
+	require.Equal(t, account.Balance, result.Account.Balance)
+	require.Zero(t, result.Account.HeldBalance)
+	require.Equal(t, account.Balance, result.Account.AvailableBalance)
+}
+
+func TestStore_ExpireHolds(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithCurrency(t, 100, util.EUR)
+	toAccount := createRandomAccountWithCurrency(t, 0, util.EUR)
+	now := time.Now()
+	expiredHold := authorizeRandomHold(t, store, account, toAccount, 30, now.Add(-time.Minute))
+	pendingHold := authorizeRandomHold(t, store, account, toAccount, 20, now.Add(time.Hour))
+
+	expired, err := store.ExpireHolds(ctx, now)
+	require.NoError(t, err)
+	// holds of other tests may have expired too
+	require.GreaterOrEqual(t, expired, 1)
+
+	hold, err := store.GetHold(ctx, expiredHold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusExpired, hold.Status)
+
+	hold, err = store.GetHold(ctx, pendingHold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusPending, hold.Status)
+
+	updatedAccount, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(20), updatedAccount.HeldBalance)
+	require.Equal(t, int64(80), updatedAccount.AvailableBalance)
+}
@@ -5,7 +5,10 @@
 package db
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Account struct {
@@ -14,6 +17,46 @@ type Account struct {
 	Balance   int64     `json:"balance"`
 	Currency  string    `json:"currency"`
 	CreatedAt time.Time `json:"created_at"`
+	// active, frozen or closed
+	Status string `json:"status"`
+	// sum of the pending holds on the account
+	HeldBalance int64 `json:"held_balance"`
+	// balance that can be spent, the balance less the held balance
+	AvailableBalance int64 `json:"available_balance"`
+}
+
+type AccountStatusChange struct {
+	ID         int64  `json:"id"`
+	AccountID  int64  `json:"account_id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	Reason     string `json:"reason"`
+	// username of the banker who made the change
+	ChangedBy string    `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AuditLog struct {
+	ID int64 `json:"id"`
+	// username of the user making the change, or system
+	Actor string `json:"actor"`
+	// like transfer.create or account.status_change
+	Action       string `json:"action"`
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+	// resource before the change, null if it was created
+	Before *json.RawMessage `json:"before"`
+	// resource after the change, null if it was deleted
+	After     *json.RawMessage `json:"after"`
+	RequestID string           `json:"request_id"`
+	ClientIp  string           `json:"client_ip"`
+	CreatedAt time.Time        `json:"created_at"`
+	// hash of the entry before in the chain, empty for the first one and until it is chained
+	PrevHash string `json:"prev_hash"`
+	// sha256 of the entry chained to prev_hash, empty until it is chained
+	Hash string `json:"hash"`
+	// position of the entry in the hash chain, null until it is chained
+	ChainSeq *int64 `json:"chain_seq"`
 }
 
 type Entry struct {
@@ -22,6 +65,90 @@ type Entry struct {
 	// can be negative or positive
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// transfer the entry was written for, null for money from outside the bank
+	TransferID *int64 `json:"transfer_id"`
+}
+
+type Hold struct {
+	ID          int64 `json:"id"`
+	AccountID   int64 `json:"account_id"`
+	ToAccountID int64 `json:"to_account_id"`
+	// must be positive
+	Amount         int64 `json:"amount"`
+	CapturedAmount int64 `json:"captured_amount"`
+	// pending, captured, voided or expired
+	Status string `json:"status"`
+	// transfer the hold was captured with
+	TransferID *int64    `json:"transfer_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type IdempotencyKey struct {
+	Username       string `json:"username"`
+	IdempotencyKey string `json:"idempotency_key"`
+	// hash of the request the key was first used with
+	RequestHash string          `json:"request_hash"`
+	Response    json.RawMessage `json:"response"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type OutboxEvent struct {
+	ID int64 `json:"id"`
+	// like TransferCompleted, AccountCreated or BalanceChanged
+	EventType string `json:"event_type"`
+	// kind of record the event is about, like transfer or account
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+	// when the relay published the event, null until then
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+type ScheduledTransfer struct {
+	ID            int64  `json:"id"`
+	Owner         string `json:"owner"`
+	FromAccountID int64  `json:"from_account_id"`
+	ToAccountID   int64  `json:"to_account_id"`
+	// must be positive
+	Amount int64 `json:"amount"`
+	// cron expression or descriptor, like @monthly or @every 24h
+	Schedule string    `json:"schedule"`
+	StartsAt time.Time `json:"starts_at"`
+	// no runs after it, runs forever if null
+	EndsAt    *time.Time `json:"ends_at"`
+	NextRunAt time.Time  `json:"next_run_at"`
+	// active, finished or cancelled
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	// the due run is being made by a worker, it is retried after it if it is not recorded by then
+	LeasedUntil *time.Time `json:"leased_until"`
+}
+
+type ScheduledTransferRun struct {
+	ID                  int64     `json:"id"`
+	ScheduledTransferID int64     `json:"scheduled_transfer_id"`
+	ScheduledFor        time.Time `json:"scheduled_for"`
+	// succeeded, failed or skipped
+	Status string `json:"status"`
+	// transfer made by a succeeded run
+	TransferID *int64 `json:"transfer_id"`
+	Error      string `json:"error"`
+	// earlier runs missed during a downtime that the catch-up policy did not execute
+	MissedRuns int64     `json:"missed_runs"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Session struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	RefreshToken string    `json:"refresh_token"`
+	UserAgent    string    `json:"user_agent"`
+	ClientIp     string    `json:"client_ip"`
+	IsBlocked    bool      `json:"is_blocked"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 type Transfer struct {
@@ -31,4 +158,51 @@ type Transfer struct {
 	// must be positive
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// amount credited to the to account, in its currency
+	ToAmount int64 `json:"to_amount"`
+	// rate used to convert amount into to_amount
+	ExchangeRate string `json:"exchange_rate"`
+	// transfer this one reverses, fully or partially
+	ReversalOfID *int64 `json:"reversal_of_id"`
+	// part of amount reversed so far, at most amount
+	ReversedAmount int64 `json:"reversed_amount"`
+}
+
+type User struct {
+	Username          string    `json:"username"`
+	HashedPassword    string    `json:"hashed_password"`
+	FullName          string    `json:"full_name"`
+	Email             string    `json:"email"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+	CreatedAt         time.Time `json:"created_at"`
+	Role              string    `json:"role"`
+}
+
+type WebhookDelivery struct {
+	ID             int64 `json:"id"`
+	SubscriptionID int64 `json:"subscription_id"`
+	// outbox event delivered
+	EventID   int64           `json:"event_id"`
+	EventType string          `json:"event_type"`
+	Payload   json.RawMessage `json:"payload"`
+	// pending, succeeded or failed
+	Status        string     `json:"status"`
+	Attempts      int32      `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastAttemptAt *time.Time `json:"last_attempt_at"`
+	// HTTP status of the last response, 0 if there was none
+	ResponseStatus int32     `json:"response_status"`
+	LastError      string    `json:"last_error"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type WebhookSubscription struct {
+	ID    int64  `json:"id"`
+	Owner string `json:"owner"`
+	Url   string `json:"url"`
+	// key the deliveries are signed with
+	Secret string `json:"secret"`
+	// types of the events delivered, like TransferCompleted
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
 }
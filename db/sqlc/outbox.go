@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Types of the domain events written to the outbox.
+const (
+	EventTransferCompleted = "TransferCompleted"
+	EventAccountCreated    = "AccountCreated"
+	EventBalanceChanged    = "BalanceChanged"
+)
+
+// Aggregate types, the kinds of records the events are about.
+const (
+	AggregateAccount  = "account"
+	AggregateTransfer = "transfer"
+)
+
+// TransferCompletedEvent is the payload of a TransferCompleted event, written for every transfer,
+// including the ones capturing a hold and the reversals.
+type TransferCompletedEvent struct {
+	TransferID    int64     `json:"transfer_id"`
+	FromAccountID int64     `json:"from_account_id"`
+	ToAccountID   int64     `json:"to_account_id"`
+	Amount        int64     `json:"amount"`
+	ToAmount      int64     `json:"to_amount"`
+	ExchangeRate  string    `json:"exchange_rate"`
+	ReversalOfID  *int64    `json:"reversal_of_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AccountCreatedEvent is the payload of an AccountCreated event.
+type AccountCreatedEvent struct {
+	AccountID int64     `json:"account_id"`
+	Owner     string    `json:"owner"`
+	Currency  string    `json:"currency"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BalanceChangedEvent is the payload of a BalanceChanged event, written for every entry moving the balance of an account.
+type BalanceChangedEvent struct {
+	AccountID  int64  `json:"account_id"`
+	EntryID    int64  `json:"entry_id"`
+	TransferID int64  `json:"transfer_id"`
+	Amount     int64  `json:"amount"`
+	Balance    int64  `json:"balance"`
+	Currency   string `json:"currency"`
+}
+
+// CreateAccountTx creates an account, writes its AccountCreated event to the outbox and audits it within a single db tx.
+func (store *SQLStore) CreateAccountTx(ctx context.Context, arg CreateAccountParams) (Account, error) {
+	var account Account
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		account, err = queries.CreateAccount(ctx, arg)
+		if err != nil {
+			return err
+		}
+
+		err = writeEvent(ctx, queries, EventAccountCreated, AggregateAccount, account.ID, AccountCreatedEvent{
+			AccountID: account.ID,
+			Owner:     account.Owner,
+			Currency:  account.Currency,
+			CreatedAt: account.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditAccountCreate, ResourceAccount, auditID(account.ID), nil, account)
+	})
+	if err != nil {
+		return account, err
+	}
+
+	return account, nil
+}
+
+// RelayOutboxEvents publishes the events not published yet with publishFn, oldest first, and marks them published.
+// It returns the number of events published.
+// Each batch of up to batchSize events is claimed in a db tx of its own, the events being claimed by another relay
+// are skipped, so several relays can run side by side.
+// Delivery is at least once: an event is marked published after publishFn returned, so it is published again
+// if the tx does not commit, and consumers should tell duplicates apart by the event ID.
+// An error of publishFn stops the relay, the events published before it stay marked.
+func (store *SQLStore) RelayOutboxEvents(ctx context.Context, batchSize int32,
+	publishFn func(ctx context.Context, event OutboxEvent) error,
+) (int, error) {
+	published := 0
+	for {
+		var batch int
+		var done bool
+		var errPublish error
+
+		_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+			batch, done, errPublish = 0, false, nil
+
+			events, err := queries.ClaimUnpublishedOutboxEvents(ctx, batchSize)
+			if err != nil {
+				return err
+			}
+
+			for _, event := range events {
+				errPublish = publishFn(ctx, event)
+				if errPublish != nil {
+					// commit the events published so far
+					return nil
+				}
+
+				err = queries.MarkOutboxEventPublished(ctx, event.ID)
+				if err != nil {
+					return err
+				}
+				batch++
+			}
+
+			// nothing left after a short batch
+			done = len(events) == 0 || len(events) < int(batchSize)
+			return nil
+		})
+		if err != nil {
+			return published, err
+		}
+
+		published += batch
+		if errPublish != nil {
+			return published, errPublish
+		}
+		if done {
+			return published, nil
+		}
+	}
+}
+
+// writeEvent writes an event with the payload to the outbox, within the db tx of the queries.
+func writeEvent(ctx context.Context, queries *Queries, eventType, aggregateType string, aggregateID int64, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = queries.CreateOutboxEvent(ctx, CreateOutboxEventParams{
+		EventType:     eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       data,
+	})
+	return err
+}
+
+// writeTransferEvents writes the TransferCompleted event of a transfer and the BalanceChanged events of both its accounts.
+func writeTransferEvents(ctx context.Context, queries *Queries, transfer Transfer,
+	fromAccount Account, fromEntry Entry,
+	toAccount Account, toEntry Entry,
+) error {
+	err := writeEvent(ctx, queries, EventTransferCompleted, AggregateTransfer, transfer.ID, TransferCompletedEvent{
+		TransferID:    transfer.ID,
+		FromAccountID: transfer.FromAccountID,
+		ToAccountID:   transfer.ToAccountID,
+		Amount:        transfer.Amount,
+		ToAmount:      transfer.ToAmount,
+		ExchangeRate:  transfer.ExchangeRate,
+		ReversalOfID:  transfer.ReversalOfID,
+		CreatedAt:     transfer.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, change := range []struct {
+		account Account
+		entry   Entry
+	}{{fromAccount, fromEntry}, {toAccount, toEntry}} {
+		err = writeEvent(ctx, queries, EventBalanceChanged, AggregateAccount, change.account.ID, BalanceChangedEvent{
+			AccountID:  change.account.ID,
+			EntryID:    change.entry.ID,
+			TransferID: transfer.ID,
+			Amount:     change.entry.Amount,
+			Balance:    change.account.Balance,
+			Currency:   change.account.Currency,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
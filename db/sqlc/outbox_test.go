@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// relayAll publishes every event not published yet and returns them.
+func relayAll(t *testing.T, store Store) []OutboxEvent {
+	var events []OutboxEvent
+	published, err := store.RelayOutboxEvents(context.Background(), 10, func(ctx context.Context, event OutboxEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(events), published)
+
+	return events
+}
+
+// findEvents returns the events of the type about the aggregate.
+func findEvents(events []OutboxEvent, eventType string, aggregateID int64) []OutboxEvent {
+	var found []OutboxEvent
+	for _, event := range events {
+		if event.EventType == eventType && event.AggregateID == aggregateID {
+			found = append(found, event)
+		}
+	}
+	return found
+}
+
+func TestCreateAccountTxWritesEvent(t *testing.T) {
+	store := NewStore(testDB)
+	relayAll(t, store)
+
+	user := createRandomUser(t)
+	account, err := store.CreateAccountTx(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Currency: util.EUR,
+	})
+	require.NoError(t, err)
+
+	events := findEvents(relayAll(t, store), EventAccountCreated, account.ID)
+	require.Len(t, events, 1)
+	require.Equal(t, AggregateAccount, events[0].AggregateType)
+
+	var payload AccountCreatedEvent
+	require.NoError(t, json.Unmarshal(events[0].Payload, &payload))
+	require.Equal(t, account.ID, payload.AccountID)
+	require.Equal(t, user.Username, payload.Owner)
+	require.Equal(t, util.EUR, payload.Currency)
+}
+
+func TestTransferTxWritesEvents(t *testing.T) {
+	store := NewStore(testDB)
+	relayAll(t, store)
+
+	account1 := createRandomAccountWithCurrency(t, 100, util.EUR)
+	account2 := createRandomAccountWithCurrency(t, 0, util.EUR)
+
+	arg := TransferTxParams{
+		FromAccountID:  account1.ID,
+		ToAccountID:    account2.ID,
+		Amount:         10,
+		IdempotencyKey: util.RandomString(16),
+		Username:       account1.Owner,
+	}
+	result, err := store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+
+	// a replayed transfer writes no events
+	_, err = store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+
+	events := relayAll(t, store)
+
+	completed := findEvents(events, EventTransferCompleted, result.Transfer.ID)
+	require.Len(t, completed, 1)
+	var transfer TransferCompletedEvent
+	require.NoError(t, json.Unmarshal(completed[0].Payload, &transfer))
+	require.Equal(t, account1.ID, transfer.FromAccountID)
+	require.Equal(t, account2.ID, transfer.ToAccountID)
+	require.Equal(t, int64(10), transfer.Amount)
+
+	for _, change := range []struct {
+		account Account
+		amount  int64
+		balance int64
+	}{{account1, -10, 90}, {account2, 10, 10}} {
+		changed := findEvents(events, EventBalanceChanged, change.account.ID)
+		require.Len(t, changed, 1)
+
+		var balance BalanceChangedEvent
+		require.NoError(t, json.Unmarshal(changed[0].Payload, &balance))
+		require.Equal(t, result.Transfer.ID, balance.TransferID)
+		require.Equal(t, change.amount, balance.Amount)
+		require.Equal(t, change.balance, balance.Balance)
+	}
+}
+
+func TestTransferTxRolledBackWritesNoEvents(t *testing.T) {
+	store := NewStore(testDB)
+	relayAll(t, store)
+
+	account1 := createRandomAccountWithCurrency(t, 5, util.EUR)
+	account2 := createRandomAccountWithCurrency(t, 0, util.EUR)
+
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	events := relayAll(t, store)
+	require.Empty(t, findEvents(events, EventBalanceChanged, account1.ID))
+	require.Empty(t, findEvents(events, EventBalanceChanged, account2.ID))
+}
+
+func TestRelayOutboxEventsPublishError(t *testing.T) {
+	store := NewStore(testDB)
+	relayAll(t, store)
+
+	user := createRandomUser(t)
+	for _, currency := range []string{util.EUR, util.USD} {
+		_, err := store.CreateAccountTx(context.Background(), CreateAccountParams{Owner: user.Username, Currency: currency})
+		require.NoError(t, err)
+	}
+
+	// the first event is published, the second fails and is left for the next run
+	errPublish := errors.New("publisher is down")
+	var first OutboxEvent
+	published, err := store.RelayOutboxEvents(context.Background(), 10, func(ctx context.Context, event OutboxEvent) error {
+		if first.ID != 0 {
+			return errPublish
+		}
+		first = event
+		return nil
+	})
+	require.ErrorIs(t, err, errPublish)
+	require.Equal(t, 1, published)
+
+	events := relayAll(t, store)
+	require.Len(t, events, 1)
+	require.Greater(t, events[0].ID, first.ID)
+}
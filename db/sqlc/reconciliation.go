@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Kinds of the discrepancies found by ReconcileLedger.
+const (
+	// DiscrepancyAccountBalance is an account whose balance is not the sum of its entries.
+	DiscrepancyAccountBalance = "account_balance"
+	// DiscrepancyTransferEntries is a transfer without exactly one entry of the right amount for one of its accounts.
+	DiscrepancyTransferEntries = "transfer_entries"
+	// DiscrepancyCurrencyTotal is a currency whose balances do not add up to the money that entered it.
+	DiscrepancyCurrencyTotal = "currency_total"
+)
+
+// ErrLedgerDiscrepancy is returned by ReconcileLedger when the ledger does not reconcile.
+var ErrLedgerDiscrepancy = errors.New("ledger has discrepancies")
+
+// reconciliationTxOptions makes all the checks of a reconciliation see the same snapshot,
+// so that a transfer committed halfway through is not reported as a discrepancy.
+var reconciliationTxOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+// ReconciliationDiscrepancy is a part of the ledger that does not agree with the rest of it.
+// What Expected and Actual count depends on the kind:
+//   - DiscrepancyAccountBalance: the sum of the entries of the account, and its balance.
+//   - DiscrepancyTransferEntries: the one entry of the account of the transfer, and the number of its matching entries.
+//   - DiscrepancyCurrencyTotal: the money that entered the currency from outside the bank and by the transfers
+//     between currencies, and the sum of the balances of its accounts.
+type ReconciliationDiscrepancy struct {
+	Kind       string `json:"kind"`
+	AccountID  int64  `json:"account_id,omitempty"`
+	TransferID int64  `json:"transfer_id,omitempty"`
+	Currency   string `json:"currency,omitempty"`
+	Expected   int64  `json:"expected"`
+	Actual     int64  `json:"actual"`
+}
+
+// ReconciliationReport is the outcome of reconciling the ledger.
+type ReconciliationReport struct {
+	CheckedAt time.Time `json:"checked_at"`
+	// Reconciled is whether no discrepancy was found.
+	Reconciled bool `json:"reconciled"`
+	// Accounts is the number of accounts checked.
+	Accounts      int64                       `json:"accounts"`
+	Currencies    []ListCurrencyTotalsRow     `json:"currencies"`
+	Discrepancies []ReconciliationDiscrepancy `json:"discrepancies"`
+}
+
+// ReconcileLedger checks the ledger against itself: the balance of each account against the sum of its entries,
+// each transfer against the entries written for it, and the balances of the accounts of each currency against
+// the money that entered it. Money enters a currency from outside the bank, with an entry written for no transfer,
+// or by a transfer between currencies. The transfers within a currency net to zero.
+// The accounts created with a balance rather than with an entry show as discrepancies.
+// It returns ErrLedgerDiscrepancy with the full report if any is found.
+func (store *SQLStore) ReconcileLedger(ctx context.Context) (ReconciliationReport, error) {
+	var report ReconciliationReport
+
+	_, err := store.execTx(ctx, reconciliationTxOptions, func(queries *Queries) error {
+		report = ReconciliationReport{
+			CheckedAt:     time.Now().UTC(),
+			Discrepancies: []ReconciliationDiscrepancy{},
+		}
+
+		accounts, err := queries.ListAccountBalanceMismatches(ctx)
+		if err != nil {
+			return err
+		}
+		for _, account := range accounts {
+			report.Discrepancies = append(report.Discrepancies, ReconciliationDiscrepancy{
+				Kind:      DiscrepancyAccountBalance,
+				AccountID: account.ID,
+				Currency:  account.Currency,
+				Expected:  account.EntriesTotal,
+				Actual:    account.Balance,
+			})
+		}
+
+		transfers, err := queries.ListTransferEntryMismatches(ctx)
+		if err != nil {
+			return err
+		}
+		for _, transfer := range transfers {
+			if transfer.FromEntries != 1 {
+				report.Discrepancies = append(report.Discrepancies, transferEntriesDiscrepancy(transfer.ID, transfer.FromAccountID, transfer.FromEntries))
+			}
+			if transfer.ToEntries != 1 {
+				report.Discrepancies = append(report.Discrepancies, transferEntriesDiscrepancy(transfer.ID, transfer.ToAccountID, transfer.ToEntries))
+			}
+		}
+
+		report.Currencies, err = queries.ListCurrencyTotals(ctx)
+		if err != nil {
+			return err
+		}
+		for _, currency := range report.Currencies {
+			report.Accounts += currency.Accounts
+			if currency.BalanceTotal != currency.ExpectedTotal {
+				report.Discrepancies = append(report.Discrepancies, ReconciliationDiscrepancy{
+					Kind:     DiscrepancyCurrencyTotal,
+					Currency: currency.Currency,
+					Expected: currency.ExpectedTotal,
+					Actual:   currency.BalanceTotal,
+				})
+			}
+		}
+
+		report.Reconciled = len(report.Discrepancies) == 0
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if !report.Reconciled {
+		return report, ErrLedgerDiscrepancy
+	}
+	return report, nil
+}
+
+func transferEntriesDiscrepancy(transferID, accountID, entries int64) ReconciliationDiscrepancy {
+	return ReconciliationDiscrepancy{
+		Kind:       DiscrepancyTransferEntries,
+		AccountID:  accountID,
+		TransferID: transferID,
+		Expected:   1,
+		Actual:     entries,
+	}
+}
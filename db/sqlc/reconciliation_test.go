@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// findDiscrepancies returns the discrepancies of the report about the account or the transfer.
+func findDiscrepancies(report ReconciliationReport, kind string, accountID, transferID int64) []ReconciliationDiscrepancy {
+	var found []ReconciliationDiscrepancy
+	for _, discrepancy := range report.Discrepancies {
+		if discrepancy.Kind == kind && discrepancy.AccountID == accountID && discrepancy.TransferID == transferID {
+			found = append(found, discrepancy)
+		}
+	}
+	return found
+}
+
+func TestReconcileLedger(t *testing.T) {
+	store := NewStore(testDB)
+	ctx := context.Background()
+
+	// an account funded with an entry, and one funded by a transfer from it
+	accountFrom := createRandomAccountWithCurrency(t, 100, util.EUR)
+	_, err := testQueries.CreateEntry(ctx, CreateEntryParams{AccountID: accountFrom.ID, Amount: 100})
+	require.NoError(t, err)
+	accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+
+	transfer, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountFrom.ID,
+		ToAccountID:   accountTo.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	// an account with a balance but no entry, and a transfer without entries
+	accountWithoutEntries := createRandomAccountWithCurrency(t, 50, util.EUR)
+	transferWithoutEntries, err := testQueries.CreateTransfer(ctx, CreateTransferParams{
+		FromAccountID: accountFrom.ID,
+		ToAccountID:   accountTo.ID,
+		Amount:        5,
+		ToAmount:      5,
+		ExchangeRate:  "1",
+	})
+	require.NoError(t, err)
+
+	report, err := store.ReconcileLedger(ctx)
+	require.ErrorIs(t, err, ErrLedgerDiscrepancy)
+	require.False(t, report.Reconciled)
+	require.NotZero(t, report.CheckedAt)
+	require.Positive(t, report.Accounts)
+
+	require.Empty(t, findDiscrepancies(report, DiscrepancyAccountBalance, accountFrom.ID, 0))
+	require.Empty(t, findDiscrepancies(report, DiscrepancyAccountBalance, accountTo.ID, 0))
+	require.Empty(t, findDiscrepancies(report, DiscrepancyTransferEntries, accountFrom.ID, transfer.Transfer.ID))
+	require.Empty(t, findDiscrepancies(report, DiscrepancyTransferEntries, accountTo.ID, transfer.Transfer.ID))
+
+	require.Equal(t, []ReconciliationDiscrepancy{{
+		Kind:      DiscrepancyAccountBalance,
+		AccountID: accountWithoutEntries.ID,
+		Currency:  util.EUR,
+		Expected:  0,
+		Actual:    50,
+	}}, findDiscrepancies(report, DiscrepancyAccountBalance, accountWithoutEntries.ID, 0))
+
+	for _, accountID := range []int64{accountFrom.ID, accountTo.ID} {
+		require.Equal(t, []ReconciliationDiscrepancy{{
+			Kind:       DiscrepancyTransferEntries,
+			AccountID:  accountID,
+			TransferID: transferWithoutEntries.ID,
+			Expected:   1,
+			Actual:     0,
+		}}, findDiscrepancies(report, DiscrepancyTransferEntries, accountID, transferWithoutEntries.ID))
+	}
+
+	var currencies []string
+	for _, currency := range report.Currencies {
+		currencies = append(currencies, currency.Currency)
+	}
+	require.Contains(t, currencies, util.EUR)
+}
+
+// currencyGap is how far the balances of the currency are from the total expected, zero if it reconciles.
+func currencyGap(report ReconciliationReport, currency string) int64 {
+	for _, discrepancy := range report.Discrepancies {
+		if discrepancy.Kind == DiscrepancyCurrencyTotal && discrepancy.Currency == currency {
+			return discrepancy.Actual - discrepancy.Expected
+		}
+	}
+	return 0
+}
+
+func TestReconcileLedgerCurrencyTotal(t *testing.T) {
+	store := NewStore(testDB)
+	ctx := context.Background()
+	// the ISO 4217 code for testing, so that no account of the other tests is in the currency
+	currency := "XTS"
+
+	// two accounts funded from outside the bank, and a transfer between them
+	accountFrom := createRandomAccountWithCurrency(t, 100, currency)
+	_, err := testQueries.CreateEntry(ctx, CreateEntryParams{AccountID: accountFrom.ID, Amount: 100})
+	require.NoError(t, err)
+	accountTo := createRandomAccountWithCurrency(t, 20, currency)
+	_, err = testQueries.CreateEntry(ctx, CreateEntryParams{AccountID: accountTo.ID, Amount: 20})
+	require.NoError(t, err)
+
+	_, err = store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountFrom.ID,
+		ToAccountID:   accountTo.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	// the accounts of the earlier runs of the test, if any, may be off already
+	report, _ := store.ReconcileLedger(ctx)
+	gap := currencyGap(report, currency)
+
+	// money appears in the currency without an entry
+	_, err = testQueries.AddAccountBalance(ctx, AddAccountBalanceParams{ID: accountTo.ID, Amount: 7})
+	require.NoError(t, err)
+
+	report, err = store.ReconcileLedger(ctx)
+	require.ErrorIs(t, err, ErrLedgerDiscrepancy)
+	require.Equal(t, gap+7, currencyGap(report, currency))
+
+	for _, row := range report.Currencies {
+		if row.Currency == currency {
+			require.Equal(t, row.FundingTotal+row.ExchangeTotal, row.ExpectedTotal)
+			require.Equal(t, row.ExpectedTotal+gap+7, row.BalanceTotal)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Postgres error codes of tx failures which succeed when the tx is simply run again.
+const (
+	serializationFailureCode = "40001"
+	deadlockDetectedCode     = "40P01"
+)
+
+// RetryPolicy controls how a db tx is retried after a serialization failure or a deadlock.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a tx is run at most, including the first attempt.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, it doubles with every further retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between two attempts.
+	MaxDelay time.Duration
+	// OnRetry is called before every retry with the retry number and the error that caused it. It is optional.
+	OnRetry func(retry int, err error)
+}
+
+// DefaultRetryPolicy is used by NewStore unless WithRetryPolicy is given.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    500 * time.Millisecond,
+}
+
+// backoff returns the delay before the given retry, using exponential backoff with jitter.
+// The jitter spreads concurrent retries of conflicting txs, so they do not collide again.
+func (policy RetryPolicy) backoff(retry int) time.Duration {
+	delay := policy.BaseDelay
+	for i := 1; i < retry && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := int64(delay) / 2
+	return time.Duration(half + rand.Int63n(half+1)) //nolint:gosec // jitter does not need a secure source
+}
+
+// wait sleeps before the given retry, it returns early with the ctx error if ctx is done.
+func (policy RetryPolicy) wait(ctx context.Context, retry int) error {
+	timer := time.NewTimer(policy.backoff(retry))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// IsRetryableTxError reports whether the tx failed because of a serialization failure or a deadlock.
+func IsRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == serializationFailureCode || pqErr.Code == deadlockDetectedCode
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableTxError(t *testing.T) {
+	require.True(t, IsRetryableTxError(&pq.Error{Code: serializationFailureCode}))
+	require.True(t, IsRetryableTxError(&pq.Error{Code: deadlockDetectedCode}))
+	require.True(t, IsRetryableTxError(fmt.Errorf("wrapped: %w", &pq.Error{Code: deadlockDetectedCode})))
+
+	require.False(t, IsRetryableTxError(&pq.Error{Code: "23505"}))
+	require.False(t, IsRetryableTxError(sql.ErrNoRows))
+	require.False(t, IsRetryableTxError(ErrInsufficientFunds))
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 10,
+		BaseDelay:   10 * time.Millisecond,
+		MaxDelay:    50 * time.Millisecond,
+	}
+
+	for retry := 1; retry <= 10; retry++ {
+		expected := policy.BaseDelay << (retry - 1)
+		if expected > policy.MaxDelay {
+			expected = policy.MaxDelay
+		}
+
+		delay := policy.backoff(retry)
+		require.GreaterOrEqual(t, delay, expected/2)
+		require.LessOrEqual(t, delay, expected)
+	}
+}
+
+func TestExecTxRetriesSerializationFailure(t *testing.T) {
+	ctx := context.Background()
+
+	var retries int64
+	store := NewStore(testDB, WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 20,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    20 * time.Millisecond,
+		OnRetry: func(retry int, err error) {
+			require.True(t, IsRetryableTxError(err))
+			atomic.AddInt64(&retries, 1)
+		},
+	})).(*SQLStore)
+
+	account := createRandomAccountWithBalance(t, 0)
+
+	// every tx reads the balance before any of them writes it, so all but one first attempt
+	// fail with a serialization failure under serializable isolation
+	n := 5
+	var read sync.WaitGroup
+	read.Add(n)
+
+	errs := make(chan error)
+	txRetries := make(chan int)
+	for i := 0; i < n; i++ {
+		go func() {
+			attempt := 0
+			retried, err := store.execTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}, func(queries *Queries) error {
+				attempt++
+
+				current, err := queries.GetAccount(ctx, account.ID)
+				if err != nil {
+					return err
+				}
+
+				if attempt == 1 {
+					read.Done()
+					read.Wait()
+				}
+
+				_, err = queries.UpdateAccount(ctx, UpdateAccountParams{
+					ID:      account.ID,
+					Balance: current.Balance + 1,
+				})
+				return err
+			})
+
+			errs <- err
+			txRetries <- retried
+		}()
+	}
+
+	totalRetries := 0
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+		totalRetries += <-txRetries
+	}
+
+	require.GreaterOrEqual(t, totalRetries, n-1)
+	require.Equal(t, int64(totalRetries), atomic.LoadInt64(&retries))
+
+	// no increment was lost
+	updatedAccount, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(n), updatedAccount.Balance)
+}
+
+func TestExecTxDoesNotRetryOtherErrors(t *testing.T) {
+	store := NewStore(testDB).(*SQLStore)
+
+	attempts := 0
+	retries, err := store.execTx(context.Background(), nil, func(queries *Queries) error {
+		attempts++
+		return ErrInsufficientFunds
+	})
+
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+	require.Zero(t, retries)
+	require.Equal(t, 1, attempts)
+}
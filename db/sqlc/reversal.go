@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/big"
+)
+
+// Reversal states of a transfer, as told by the part of its amount reversed so far.
+const (
+	ReversalStateNone    = "none"
+	ReversalStatePartial = "partial"
+	ReversalStateFull    = "full"
+)
+
+// reversalRateScale is the number of decimals kept for the exchange rate of a reversal between currencies.
+const reversalRateScale = 10
+
+var (
+	// ErrTransferAlreadyReversed is returned when reversing a transfer whose whole amount was reversed already.
+	ErrTransferAlreadyReversed = errors.New("transfer was already reversed")
+	// ErrInvalidReversalAmount is returned when reversing more than is left of a transfer, or a negative amount.
+	ErrInvalidReversalAmount = errors.New("reversal amount must be positive and at most the amount not reversed yet")
+	// ErrReversalNotReversible is returned when reversing a transfer that is a reversal itself.
+	ErrReversalNotReversible = errors.New("a reversal cannot be reversed")
+)
+
+// ReversalState tells whether none, part or all of the transfer was reversed.
+func (transfer Transfer) ReversalState() string {
+	switch {
+	case transfer.ReversedAmount == 0:
+		return ReversalStateNone
+	case transfer.ReversedAmount < transfer.Amount:
+		return ReversalStatePartial
+	default:
+		return ReversalStateFull
+	}
+}
+
+// ReversibleAmount is the part of the amount of the transfer that can still be reversed.
+// It is always zero for a reversal.
+func (transfer Transfer) ReversibleAmount() int64 {
+	if transfer.ReversalOfID != nil {
+		return 0
+	}
+	return transfer.Amount - transfer.ReversedAmount
+}
+
+type ReverseTransferTxParams struct {
+	TransferID int64 `json:"transfer_id"`
+	// Amount is the part of the amount of the transfer to give back, in the currency of its from account.
+	// Zero reverses all that was not reversed yet.
+	Amount int64 `json:"amount"`
+}
+
+type ReverseTransferTxResult struct {
+	// Original is the reversed transfer, with its reversed amount updated.
+	Original Transfer `json:"original"`
+	// Reversal is the compensating transfer, from the to account of the original back to its from account.
+	Reversal    Transfer `json:"reversal"`
+	FromAccount Account  `json:"from_account"`
+	ToAccount   Account  `json:"to_account"`
+	FromEntry   Entry    `json:"from_entry"`
+	ToEntry     Entry    `json:"to_entry"`
+}
+
+// ReverseTransferTx gives back a transfer, fully or partially, with a compensating transfer linked to it.
+// A transfer between currencies is reversed at its own exchange rate, so the reversals of all its amount
+// debit exactly what it credited.
+// The original is locked while it is reversed, so the same amount cannot be reversed twice. The tx is rolled back
+// with ErrTransferAlreadyReversed, ErrInvalidReversalAmount or ErrReversalNotReversible if the reversal is not possible,
+// with ErrAccountNotActive if either account is frozen or closed, and with ErrInsufficientFunds if the to account
+// of the original does not have the money anymore.
+func (store *SQLStore) ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (ReverseTransferTxResult, error) {
+	var result ReverseTransferTxResult
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		result = ReverseTransferTxResult{}
+
+		original, err := queries.GetTransferForUpdate(ctx, arg.TransferID)
+		if err != nil {
+			return err
+		}
+
+		if original.ReversalOfID != nil {
+			return ErrReversalNotReversible
+		}
+
+		reversible := original.ReversibleAmount()
+		if reversible == 0 {
+			return ErrTransferAlreadyReversed
+		}
+
+		amount := arg.Amount
+		if amount == 0 {
+			amount = reversible
+		}
+		if amount < 0 || amount > reversible {
+			return ErrInvalidReversalAmount
+		}
+
+		debit := reversalDebit(original, amount)
+		if debit <= 0 {
+			return ErrInvalidReversalAmount
+		}
+
+		exchangeRate := sameCurrencyRate
+		if original.ExchangeRate != sameCurrencyRate {
+			exchangeRate = new(big.Rat).SetFrac64(amount, debit).FloatString(reversalRateScale)
+		}
+
+		result.Reversal, result.FromEntry, result.ToEntry, err = recordTransfer(ctx, queries, CreateTransferParams{
+			FromAccountID: original.ToAccountID,
+			ToAccountID:   original.FromAccountID,
+			Amount:        debit,
+			ToAmount:      amount,
+			ExchangeRate:  exchangeRate,
+			ReversalOfID:  &original.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		// to avoid deadlock
+		if result.Reversal.FromAccountID < result.Reversal.ToAccountID {
+			result.FromAccount, result.ToAccount, err = addMoney(ctx, queries, result.Reversal.FromAccountID, -debit, result.Reversal.ToAccountID, amount)
+		} else {
+			result.ToAccount, result.FromAccount, err = addMoney(ctx, queries, result.Reversal.ToAccountID, amount, result.Reversal.FromAccountID, -debit)
+		}
+		if err != nil {
+			return err
+		}
+
+		if result.FromAccount.Status != AccountStatusActive || result.ToAccount.Status != AccountStatusActive {
+			return ErrAccountNotActive
+		}
+
+		if result.FromAccount.AvailableBalance < 0 {
+			return ErrInsufficientFunds
+		}
+
+		err = writeTransferEvents(ctx, queries, result.Reversal, result.FromAccount, result.FromEntry, result.ToAccount, result.ToEntry)
+		if err != nil {
+			return err
+		}
+
+		result.Original, err = queries.AddTransferReversedAmount(ctx, AddTransferReversedAmountParams{
+			Amount: amount,
+			ID:     original.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = writeAudit(ctx, queries, AuditTransferCreate, ResourceTransfer, auditID(result.Reversal.ID), nil, result.Reversal)
+		if err != nil {
+			return err
+		}
+
+		return writeAudit(ctx, queries, AuditTransferReverse, ResourceTransfer, auditID(original.ID), original, result.Original)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// reversalDebit is what reversing amount of the transfer takes back from its to account, in that account's currency.
+// It is worked out from the total reversed before and after, rounded down, so that the reversals of the whole
+// amount add up to exactly the amount the transfer credited.
+func reversalDebit(transfer Transfer, amount int64) int64 {
+	reversedBefore := new(big.Int).Mul(big.NewInt(transfer.ToAmount), big.NewInt(transfer.ReversedAmount))
+	reversedAfter := new(big.Int).Mul(big.NewInt(transfer.ToAmount), big.NewInt(transfer.ReversedAmount+amount))
+
+	total := big.NewInt(transfer.Amount)
+	reversedBefore.Quo(reversedBefore, total)
+	reversedAfter.Quo(reversedAfter, total)
+
+	return reversedAfter.Sub(reversedAfter, reversedBefore).Int64()
+}
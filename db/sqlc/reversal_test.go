@@ -0,0 +1,212 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferReversalState(t *testing.T) {
+	transfer := Transfer{Amount: 100}
+	require.Equal(t, ReversalStateNone, transfer.ReversalState())
+	require.Equal(t, int64(100), transfer.ReversibleAmount())
+
+	transfer.ReversedAmount = 40
+	require.Equal(t, ReversalStatePartial, transfer.ReversalState())
+	require.Equal(t, int64(60), transfer.ReversibleAmount())
+
+	transfer.ReversedAmount = 100
+	require.Equal(t, ReversalStateFull, transfer.ReversalState())
+	require.Zero(t, transfer.ReversibleAmount())
+
+	originalID := int64(1)
+	reversal := Transfer{Amount: 100, ReversalOfID: &originalID}
+	require.Zero(t, reversal.ReversibleAmount())
+}
+
+func TestReversalDebit(t *testing.T) {
+	// within one currency the debit is the amount
+	require.Equal(t, int64(30), reversalDebit(Transfer{Amount: 100, ToAmount: 100}, 30))
+
+	// 100 USD credited as 92 EUR, reversed in three parts
+	transfer := Transfer{Amount: 100, ToAmount: 92}
+	debits := int64(0)
+	for _, amount := range []int64{33, 33, 34} {
+		debit := reversalDebit(transfer, amount)
+		require.Positive(t, debit)
+		debits += debit
+		transfer.ReversedAmount += amount
+	}
+	// the parts add up to what was credited, without rounding losses
+	require.Equal(t, int64(92), debits)
+}
+
+func TestStore_ReverseTransferTx(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 1000, util.EUR)
+
+	transfer, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountFrom.ID,
+		ToAccountID:   accountTo.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+	original := transfer.Transfer
+
+	// partial refund
+	result, err := store.ReverseTransferTx(ctx, ReverseTransferTxParams{TransferID: original.ID, Amount: 40})
+	require.NoError(t, err)
+
+	require.Equal(t, int64(40), result.Original.ReversedAmount)
+	require.Equal(t, ReversalStatePartial, result.Original.ReversalState())
+
+	assertTransfer(t, result.Reversal, accountTo, accountFrom, 40)
+	require.NotNil(t, result.Reversal.ReversalOfID)
+	require.Equal(t, original.ID, *result.Reversal.ReversalOfID)
+	assertEntry(t, result.FromEntry, accountTo, -40)
+	assertEntry(t, result.ToEntry, accountFrom, 40)
+
+	require.Equal(t, accountTo.Balance+100-40, result.FromAccount.Balance)
+	require.Equal(t, accountFrom.Balance-100+40, result.ToAccount.Balance)
+
+	// more than is left
+	_, err = store.ReverseTransferTx(ctx, ReverseTransferTxParams{TransferID: original.ID, Amount: 61})
+	require.ErrorIs(t, err, ErrInvalidReversalAmount)
+
+	// the rest
+	result, err = store.ReverseTransferTx(ctx, ReverseTransferTxParams{TransferID: original.ID})
+	require.NoError(t, err)
+	require.Equal(t, int64(60), result.Reversal.Amount)
+	require.Equal(t, ReversalStateFull, result.Original.ReversalState())
+
+	_, err = store.ReverseTransferTx(ctx, ReverseTransferTxParams{TransferID: original.ID})
+	require.ErrorIs(t, err, ErrTransferAlreadyReversed)
+
+	// reversals are not reversible
+	_, err = store.ReverseTransferTx(ctx, ReverseTransferTxParams{TransferID: result.Reversal.ID})
+	require.ErrorIs(t, err, ErrReversalNotReversible)
+
+	reversals, err := store.ListTransferReversals(ctx, original.ID)
+	require.NoError(t, err)
+	require.Len(t, reversals, 2)
+
+	// balances are back where they started
+	updatedAccountFrom, err := store.GetAccount(ctx, accountFrom.ID)
+	require.NoError(t, err)
+	updatedAccountTo, err := store.GetAccount(ctx, accountTo.ID)
+	require.NoError(t, err)
+	require.Equal(t, accountFrom.Balance, updatedAccountFrom.Balance)
+	require.Equal(t, accountTo.Balance, updatedAccountTo.Balance)
+}
+
+func TestReverseTransferTxConcurrent(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 1000, util.EUR)
+
+	transfer, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountFrom.ID,
+		ToAccountID:   accountTo.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	// refunds racing for the same transfer
+	n := 10
+	amount := int64(30)
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.ReverseTransferTx(ctx, ReverseTransferTxParams{
+				TransferID: transfer.Transfer.ID,
+				Amount:     amount,
+			})
+			errs <- err
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err != nil {
+			require.ErrorIs(t, err, ErrInvalidReversalAmount)
+			continue
+		}
+		succeeded++
+	}
+
+	// only as many as the transfer covers may succeed
+	require.Equal(t, 3, succeeded)
+
+	original, err := store.GetTransfer(ctx, transfer.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(succeeded)*amount, original.ReversedAmount)
+
+	updatedAccountTo, err := store.GetAccount(ctx, accountTo.ID)
+	require.NoError(t, err)
+	require.Equal(t, accountTo.Balance+100-int64(succeeded)*amount, updatedAccountTo.Balance)
+}
+
+func TestReverseTransferTxConvertsCurrency(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.USD)
+	accountTo := createRandomAccountWithCurrency(t, 1000, util.EUR)
+
+	transfer, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountFrom.ID,
+		ToAccountID:   accountTo.ID,
+		Amount:        100,
+		ToAmount:      92,
+		ExchangeRate:  "0.92",
+	})
+	require.NoError(t, err)
+
+	result, err := store.ReverseTransferTx(ctx, ReverseTransferTxParams{TransferID: transfer.Transfer.ID, Amount: 50})
+	require.NoError(t, err)
+
+	// at the rate of the original
+	require.Equal(t, int64(46), result.Reversal.Amount)
+	require.Equal(t, int64(50), result.Reversal.ToAmount)
+	assertEntry(t, result.FromEntry, accountTo, -46)
+	assertEntry(t, result.ToEntry, accountFrom, 50)
+}
+
+func TestReverseTransferTxInsufficientFunds(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 100, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+	otherAccount := createRandomAccountWithCurrency(t, 0, util.EUR)
+
+	transfer, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountFrom.ID,
+		ToAccountID:   accountTo.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	// the money was spent already
+	_, err = store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountTo.ID,
+		ToAccountID:   otherAccount.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	_, err = store.ReverseTransferTx(ctx, ReverseTransferTxParams{TransferID: transfer.Transfer.ID})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	original, err := store.GetTransfer(ctx, transfer.Transfer.ID)
+	require.NoError(t, err)
+	require.Zero(t, original.ReversedAmount)
+}
@@ -0,0 +1,298 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Scheduled transfer statuses. A scheduled transfer is active until its last run, or until it is cancelled.
+const (
+	ScheduledTransferStatusActive    = "active"
+	ScheduledTransferStatusFinished  = "finished"
+	ScheduledTransferStatusCancelled = "cancelled"
+)
+
+// Outcomes of a run of a scheduled transfer.
+const (
+	ScheduledTransferRunSucceeded = "succeeded"
+	ScheduledTransferRunFailed    = "failed"
+	ScheduledTransferRunSkipped   = "skipped"
+)
+
+// Catch-up policies, telling what to do with the runs of scheduled transfers missed during a downtime.
+const (
+	// CatchUpAll executes every missed run, oldest first.
+	CatchUpAll = "all"
+	// CatchUpOnce executes the missed runs as a single run.
+	CatchUpOnce = "once"
+	// CatchUpSkip executes none of the missed runs, the scheduled transfer goes on with its next run.
+	CatchUpSkip = "skip"
+)
+
+// scheduledTransferRunInvalidSchedule is the error recorded for a run of a scheduled transfer
+// whose stored schedule no longer parses. The scheduled transfer is finished with it.
+const scheduledTransferRunInvalidSchedule = "schedule can no longer be parsed"
+
+// defaultScheduledTransferLease is used by RunScheduledTransfers unless RunScheduledTransfersParams.Lease is given.
+const defaultScheduledTransferLease = time.Minute
+
+// minScheduleInterval is the shortest interval allowed between the runs of a scheduled transfer.
+const minScheduleInterval = time.Minute
+
+var (
+	// ErrInvalidSchedule is returned when a schedule cannot be parsed, or never runs.
+	ErrInvalidSchedule = errors.New("schedule must be a cron expression or a descriptor like @monthly or @every 24h")
+	// ErrInvalidCatchUpPolicy is returned when running scheduled transfers with an unknown catch-up policy.
+	ErrInvalidCatchUpPolicy = errors.New("catch-up policy must be all, once or skip")
+)
+
+// ParseSchedule parses the schedule of a scheduled transfer. It is either a standard cron expression,
+// like "0 9 1 * *" for 9:00 on the 1st of each month, or a descriptor like "@monthly" or "@every 24h".
+// Schedules are in UTC, unless the expression starts with a time zone like "CRON_TZ=Europe/Berlin".
+func ParseSchedule(spec string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok && every.Delay < minScheduleInterval {
+		return nil, fmt.Errorf("%w: runs must be at least %s apart", ErrInvalidSchedule, minScheduleInterval)
+	}
+
+	return schedule, nil
+}
+
+// FirstRun is the first run of the schedule at or after startsAt, or the zero time if there is none.
+// An interval schedule first runs at startsAt.
+func FirstRun(schedule cron.Schedule, startsAt time.Time) time.Time {
+	if _, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		return startsAt
+	}
+	return schedule.Next(startsAt.UTC().Add(-time.Nanosecond))
+}
+
+// IsCatchUpPolicy tells whether the policy is one of CatchUpAll, CatchUpOnce and CatchUpSkip.
+func IsCatchUpPolicy(policy string) bool {
+	switch policy {
+	case CatchUpAll, CatchUpOnce, CatchUpSkip:
+		return true
+	default:
+		return false
+	}
+}
+
+type RunScheduledTransfersParams struct {
+	Now time.Time `json:"now"`
+	// CatchUp is the catch-up policy for the runs missed during a downtime.
+	CatchUp string `json:"catch_up"`
+	// MissedAfter is how late a run may be before it counts as missed. Runs less late are executed as usual.
+	MissedAfter time.Duration `json:"missed_after"`
+	// Lease is how long after Now a worker has to make the transfer of a run and record it. A run not recorded
+	// by then is due again, so it also serves as the delay before a run that failed unexpectedly is retried.
+	// It defaults to a minute.
+	Lease time.Duration `json:"lease"`
+}
+
+// RunScheduledTransfers executes the runs of the scheduled transfers due by now and records their outcomes.
+// It returns the number of runs recorded.
+// Each run is claimed with a lease in a db tx of its own, made by TransferTx, then recorded in another db tx,
+// so a worker uses one connection of the pool at a time and holds no lock during the transfer.
+// Scheduled transfers leased by another worker are skipped, so several workers can run them side by side.
+// A run failing because of the accounts, like with ErrInsufficientFunds or ErrAccountNotActive, is recorded
+// as failed and not retried. A run whose transfer fails with any other error, like a lost connection, is left
+// due and retried with the same idempotency key once its lease is over. These errors are returned once all due
+// runs are done. An error of the db tx claiming or recording a run stops the runs and is returned.
+func (store *SQLStore) RunScheduledTransfers(ctx context.Context, arg RunScheduledTransfersParams) (int, error) {
+	if !IsCatchUpPolicy(arg.CatchUp) {
+		return 0, ErrInvalidCatchUpPolicy
+	}
+	if arg.Lease <= 0 {
+		arg.Lease = defaultScheduledTransferLease
+	}
+
+	runs := 0
+	var runErrs []error
+	for {
+		result, err := store.runScheduledTransfer(ctx, arg)
+		if err != nil {
+			return runs, errors.Join(append(runErrs, err)...)
+		}
+		if !result.claimed {
+			return runs, errors.Join(runErrs...)
+		}
+		if result.err != nil {
+			runErrs = append(runErrs, result.err)
+		}
+		if result.recorded {
+			runs++
+		}
+	}
+}
+
+// scheduledTransferRunResult is the outcome of runScheduledTransfer.
+type scheduledTransferRunResult struct {
+	// claimed is false if no scheduled transfer was due.
+	claimed bool
+	// recorded is false if the run is left due, to be retried once its lease is over.
+	recorded bool
+	// err is the unexpected error of the run, which does not stop the runs of the other scheduled transfers.
+	err error
+}
+
+// runScheduledTransfer claims a due scheduled transfer and executes its next run.
+// The transfer is made by TransferTx in a db tx of its own, with an idempotency key of the run,
+// so a run retried after its transfer was committed does not move the money again.
+func (store *SQLStore) runScheduledTransfer(ctx context.Context, arg RunScheduledTransfersParams) (scheduledTransferRunResult, error) {
+	var result scheduledTransferRunResult
+	var scheduled ScheduledTransfer
+	var schedule cron.Schedule
+	var run CreateScheduledTransferRunParams
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		result = scheduledTransferRunResult{}
+		run = CreateScheduledTransferRunParams{}
+
+		// the row stays locked until the run is leased or recorded, other workers skip it meanwhile
+		scheduled, err = queries.ClaimDueScheduledTransfer(ctx, arg.Now)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		result.claimed = true
+
+		schedule, err = ParseSchedule(scheduled.Schedule)
+		if err != nil {
+			// the scheduled transfer cannot run again, it is finished so that it does not hold up the others
+			result.recorded = true
+			result.err = fmt.Errorf("scheduled transfer ID %d: %w", scheduled.ID, err)
+			return recordScheduledTransferRun(ctx, queries, scheduled, nil, CreateScheduledTransferRunParams{
+				ScheduledTransferID: scheduled.ID,
+				ScheduledFor:        scheduled.NextRunAt,
+				Status:              ScheduledTransferRunFailed,
+				Error:               scheduledTransferRunInvalidSchedule,
+			})
+		}
+
+		runAt, missedRuns := catchUpRun(schedule, scheduled, arg)
+		run = CreateScheduledTransferRunParams{
+			ScheduledTransferID: scheduled.ID,
+			ScheduledFor:        runAt,
+			MissedRuns:          missedRuns,
+		}
+
+		if arg.CatchUp == CatchUpSkip && runAt.Before(arg.Now.Add(-arg.MissedAfter)) {
+			result.recorded = true
+			run.Status = ScheduledTransferRunSkipped
+			return recordScheduledTransferRun(ctx, queries, scheduled, schedule, run)
+		}
+
+		leasedUntil := arg.Now.Add(arg.Lease)
+		_, err = queries.LeaseScheduledTransfer(ctx, LeaseScheduledTransferParams{
+			LeasedUntil: &leasedUntil,
+			ID:          scheduled.ID,
+		})
+		return err
+	})
+	if err != nil || !result.claimed || result.recorded {
+		return result, err
+	}
+
+	transfer, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID:  scheduled.FromAccountID,
+		ToAccountID:    scheduled.ToAccountID,
+		Amount:         scheduled.Amount,
+		IdempotencyKey: fmt.Sprintf("scheduled_transfer:%d:%d", scheduled.ID, run.ScheduledFor.Unix()),
+		Username:       scheduled.Owner,
+	})
+	switch {
+	case err == nil:
+		run.Status = ScheduledTransferRunSucceeded
+		run.TransferID = &transfer.Transfer.ID
+	case errors.Is(err, ErrInsufficientFunds), errors.Is(err, ErrAccountNotActive), errors.Is(err, ErrCurrencyMismatch):
+		run.Status = ScheduledTransferRunFailed
+		run.Error = err.Error()
+	default:
+		// the run stays due under its lease, and is retried once the lease is over
+		result.err = fmt.Errorf("scheduled transfer ID %d: %w", scheduled.ID, err)
+		return result, nil
+	}
+
+	_, err = store.execTx(ctx, nil, func(queries *Queries) error {
+		result.recorded = false
+
+		current, err := queries.GetScheduledTransferForUpdate(ctx, scheduled.ID)
+		if err != nil {
+			return err
+		}
+		// the lease ran out and another worker recorded the run, with the transfer made under the same idempotency key
+		if !current.NextRunAt.Equal(scheduled.NextRunAt) {
+			return nil
+		}
+
+		result.recorded = true
+		return recordScheduledTransferRun(ctx, queries, current, schedule, run)
+	})
+	return result, err
+}
+
+// recordScheduledTransferRun records the run of the scheduled transfer, and moves it on to its next run.
+// A scheduled transfer is finished after its last run, or after a run without a schedule, and stays cancelled
+// if it was cancelled while the run was being made.
+func recordScheduledTransferRun(ctx context.Context, queries *Queries, scheduled ScheduledTransfer, schedule cron.Schedule, run CreateScheduledTransferRunParams) error {
+	_, err := queries.CreateScheduledTransferRun(ctx, run)
+	if err != nil {
+		return err
+	}
+
+	next, status := run.ScheduledFor, ScheduledTransferStatusFinished
+	if schedule != nil {
+		next, status = schedule.Next(run.ScheduledFor.UTC()), ScheduledTransferStatusActive
+		if next.IsZero() || (scheduled.EndsAt != nil && next.After(*scheduled.EndsAt)) {
+			next, status = run.ScheduledFor, ScheduledTransferStatusFinished
+		}
+	}
+	if scheduled.Status != ScheduledTransferStatusActive {
+		status = scheduled.Status
+	}
+
+	updated, err := queries.UpdateScheduledTransfer(ctx, UpdateScheduledTransferParams{
+		NextRunAt: next,
+		Status:    status,
+		ID:        scheduled.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// the transfer of the run was audited by TransferTx, whose tx is committed by now
+	return writeAudit(ctx, queries, AuditScheduledTransferRun, ResourceScheduledTransfer, auditID(scheduled.ID), scheduled, updated)
+}
+
+// catchUpRun picks the run of the scheduled transfer to execute now, and counts the missed runs before it
+// that the catch-up policy leaves out.
+// A run that is not missed is executed as it is. Otherwise, unless every missed run is to be executed,
+// the latest run due by now stands in for all of them.
+func catchUpRun(schedule cron.Schedule, scheduled ScheduledTransfer, arg RunScheduledTransfersParams) (time.Time, int64) {
+	runAt := scheduled.NextRunAt
+	if arg.CatchUp == CatchUpAll || !runAt.Before(arg.Now.Add(-arg.MissedAfter)) {
+		return runAt, 0
+	}
+
+	var missedRuns int64
+	for {
+		next := schedule.Next(runAt.UTC())
+		if next.IsZero() || next.After(arg.Now) || (scheduled.EndsAt != nil && next.After(*scheduled.EndsAt)) {
+			return runAt, missedRuns
+		}
+		runAt = next
+		missedRuns++
+	}
+}
@@ -0,0 +1,443 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomScheduledTransfer(t *testing.T, from, to Account, amount int64, schedule string, nextRunAt time.Time, endsAt *time.Time) ScheduledTransfer {
+	arg := CreateScheduledTransferParams{
+		Owner:         from.Owner,
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        amount,
+		Schedule:      schedule,
+		StartsAt:      nextRunAt,
+		EndsAt:        endsAt,
+		NextRunAt:     nextRunAt,
+	}
+
+	scheduled, err := testQueries.CreateScheduledTransfer(context.Background(), arg)
+	require.NoError(t, err)
+
+	require.NotZero(t, scheduled.ID)
+	require.Equal(t, arg.Owner, scheduled.Owner)
+	require.Equal(t, arg.FromAccountID, scheduled.FromAccountID)
+	require.Equal(t, arg.ToAccountID, scheduled.ToAccountID)
+	require.Equal(t, arg.Amount, scheduled.Amount)
+	require.Equal(t, arg.Schedule, scheduled.Schedule)
+	require.WithinDuration(t, arg.NextRunAt, scheduled.NextRunAt, time.Second)
+	require.Equal(t, ScheduledTransferStatusActive, scheduled.Status)
+
+	return scheduled
+}
+
+func listRuns(t *testing.T, scheduledTransferID int64) []ScheduledTransferRun {
+	runs, err := testQueries.ListScheduledTransferRuns(context.Background(), ListScheduledTransferRunsParams{
+		ScheduledTransferID: scheduledTransferID,
+		Limit:               100,
+	})
+	require.NoError(t, err)
+	return runs
+}
+
+func TestParseSchedule(t *testing.T) {
+	for _, spec := range []string{"0 9 1 * *", "@monthly", "@every 24h", "CRON_TZ=Europe/Berlin 0 9 * * 1-5"} {
+		_, err := ParseSchedule(spec)
+		require.NoError(t, err, spec)
+	}
+
+	for _, spec := range []string{"", "every day", "0 9 1 *", "@every 30s", "@every 0"} {
+		_, err := ParseSchedule(spec)
+		require.ErrorIs(t, err, ErrInvalidSchedule, spec)
+	}
+}
+
+func TestFirstRun(t *testing.T) {
+	monthly, err := ParseSchedule("0 9 1 * *")
+	require.NoError(t, err)
+
+	startsAt := time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC), FirstRun(monthly, startsAt))
+
+	// a start on a run is the first run
+	startsAt = time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC)
+	require.Equal(t, startsAt, FirstRun(monthly, startsAt))
+
+	daily, err := ParseSchedule("@every 24h")
+	require.NoError(t, err)
+	require.Equal(t, startsAt, FirstRun(daily, startsAt))
+
+	never, err := ParseSchedule("0 9 30 2 *")
+	require.NoError(t, err)
+	require.True(t, FirstRun(never, startsAt).IsZero())
+}
+
+func TestCatchUpRun(t *testing.T) {
+	hourly, err := ParseSchedule("@every 1h")
+	require.NoError(t, err)
+
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	// due 3.5h ago, the runs of 2.5h, 1.5h and 0.5h ago are due too
+	scheduled := ScheduledTransfer{NextRunAt: now.Add(-3*time.Hour - 30*time.Minute)}
+	arg := RunScheduledTransfersParams{Now: now, MissedAfter: 2 * time.Minute}
+
+	arg.CatchUp = CatchUpAll
+	runAt, missedRuns := catchUpRun(hourly, scheduled, arg)
+	require.Equal(t, scheduled.NextRunAt, runAt)
+	require.Zero(t, missedRuns)
+
+	for _, catchUp := range []string{CatchUpOnce, CatchUpSkip} {
+		arg.CatchUp = catchUp
+		runAt, missedRuns = catchUpRun(hourly, scheduled, arg)
+		require.Equal(t, now.Add(-30*time.Minute), runAt)
+		require.Equal(t, int64(3), missedRuns)
+	}
+
+	// no run after the end
+	endsAt := now.Add(-2 * time.Hour)
+	scheduled.EndsAt = &endsAt
+	runAt, missedRuns = catchUpRun(hourly, scheduled, arg)
+	require.Equal(t, now.Add(-2*time.Hour-30*time.Minute), runAt)
+	require.Equal(t, int64(1), missedRuns)
+
+	// a run a little late is not missed
+	scheduled = ScheduledTransfer{NextRunAt: now.Add(-time.Minute)}
+	runAt, missedRuns = catchUpRun(hourly, scheduled, arg)
+	require.Equal(t, scheduled.NextRunAt, runAt)
+	require.Zero(t, missedRuns)
+}
+
+func TestStore_RunScheduledTransfers(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+	now := time.Now().Truncate(time.Second)
+	scheduled := createRandomScheduledTransfer(t, accountFrom, accountTo, 100, "@every 24h", now.Add(-time.Second), nil)
+
+	runs, err := store.RunScheduledTransfers(ctx, RunScheduledTransfersParams{
+		Now:         now,
+		CatchUp:     CatchUpOnce,
+		MissedAfter: 2 * time.Minute,
+	})
+	require.NoError(t, err)
+	// scheduled transfers of other tests may have been due too
+	require.GreaterOrEqual(t, runs, 1)
+
+	scheduledRuns := listRuns(t, scheduled.ID)
+	require.Len(t, scheduledRuns, 1)
+	require.Equal(t, ScheduledTransferRunSucceeded, scheduledRuns[0].Status)
+	require.WithinDuration(t, scheduled.NextRunAt, scheduledRuns[0].ScheduledFor, time.Second)
+	require.NotNil(t, scheduledRuns[0].TransferID)
+
+	transfer, err := store.GetTransfer(ctx, *scheduledRuns[0].TransferID)
+	require.NoError(t, err)
+	assertTransfer(t, transfer, accountFrom, accountTo, 100)
+
+	updated, err := store.GetScheduledTransfer(ctx, scheduled.ID)
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferStatusActive, updated.Status)
+	require.WithinDuration(t, scheduled.NextRunAt.Add(24*time.Hour), updated.NextRunAt, time.Second)
+
+	// not due again until the next run
+	_, err = store.RunScheduledTransfers(ctx, RunScheduledTransfersParams{
+		Now:         now,
+		CatchUp:     CatchUpOnce,
+		MissedAfter: 2 * time.Minute,
+	})
+	require.NoError(t, err)
+	require.Len(t, listRuns(t, scheduled.ID), 1)
+
+	updatedAccountFrom, err := store.GetAccount(ctx, accountFrom.ID)
+	require.NoError(t, err)
+	require.Equal(t, accountFrom.Balance-100, updatedAccountFrom.Balance)
+}
+
+func TestRunScheduledTransfersCatchUp(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+	now := time.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		catchUp        string
+		wantStatuses   []string
+		wantMissedRuns int64
+	}{
+		{
+			catchUp: CatchUpAll,
+			wantStatuses: []string{
+				ScheduledTransferRunSucceeded,
+				ScheduledTransferRunSucceeded,
+				ScheduledTransferRunSucceeded,
+				ScheduledTransferRunSucceeded,
+			},
+		},
+		{
+			catchUp:        CatchUpOnce,
+			wantStatuses:   []string{ScheduledTransferRunSucceeded},
+			wantMissedRuns: 3,
+		},
+		{
+			catchUp:        CatchUpSkip,
+			wantStatuses:   []string{ScheduledTransferRunSkipped},
+			wantMissedRuns: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.catchUp, func(t *testing.T) {
+			accountFrom := createRandomAccountWithCurrency(t, 1000, util.EUR)
+			accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+			// the worker was down for 4 runs
+			scheduled := createRandomScheduledTransfer(t, accountFrom, accountTo, 10, "@every 1h",
+				now.Add(-3*time.Hour-30*time.Minute), nil)
+
+			_, err := store.RunScheduledTransfers(ctx, RunScheduledTransfersParams{
+				Now:         now,
+				CatchUp:     tc.catchUp,
+				MissedAfter: 2 * time.Minute,
+			})
+			require.NoError(t, err)
+
+			scheduledRuns := listRuns(t, scheduled.ID)
+			require.Len(t, scheduledRuns, len(tc.wantStatuses))
+			succeeded := int64(0)
+			for i, run := range scheduledRuns {
+				require.Equal(t, tc.wantStatuses[i], run.Status)
+				if run.Status == ScheduledTransferRunSucceeded {
+					succeeded++
+				}
+			}
+			// the latest run first
+			require.WithinDuration(t, now.Add(-30*time.Minute), scheduledRuns[0].ScheduledFor, time.Second)
+			require.Equal(t, tc.wantMissedRuns, scheduledRuns[0].MissedRuns)
+
+			updated, err := store.GetScheduledTransfer(ctx, scheduled.ID)
+			require.NoError(t, err)
+			require.WithinDuration(t, now.Add(30*time.Minute), updated.NextRunAt, time.Second)
+
+			updatedAccountFrom, err := store.GetAccount(ctx, accountFrom.ID)
+			require.NoError(t, err)
+			require.Equal(t, accountFrom.Balance-succeeded*10, updatedAccountFrom.Balance)
+		})
+	}
+}
+
+func TestRunScheduledTransfersFailed(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 50, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+	now := time.Now().Truncate(time.Second)
+	scheduled := createRandomScheduledTransfer(t, accountFrom, accountTo, 100, "@every 24h", now.Add(-time.Second), nil)
+
+	_, err := store.RunScheduledTransfers(ctx, RunScheduledTransfersParams{
+		Now:         now,
+		CatchUp:     CatchUpOnce,
+		MissedAfter: 2 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	scheduledRuns := listRuns(t, scheduled.ID)
+	require.Len(t, scheduledRuns, 1)
+	require.Equal(t, ScheduledTransferRunFailed, scheduledRuns[0].Status)
+	require.Equal(t, ErrInsufficientFunds.Error(), scheduledRuns[0].Error)
+	require.Nil(t, scheduledRuns[0].TransferID)
+
+	// a failed run is not retried, the next one is awaited
+	updated, err := store.GetScheduledTransfer(ctx, scheduled.ID)
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferStatusActive, updated.Status)
+	require.True(t, updated.NextRunAt.After(now))
+}
+
+func TestRunScheduledTransfersUnexpectedError(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+	now := time.Now().Truncate(time.Second)
+	failing := createRandomScheduledTransfer(t, accountFrom, accountTo, 100, "@every 24h", now.Add(-2*time.Second), nil)
+	scheduled := createRandomScheduledTransfer(t, accountFrom, accountTo, 100, "@every 24h", now.Add(-time.Second), nil)
+
+	// the idempotency key of the run is already used by another transfer, so the transfer of the run fails
+	_, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID:  accountFrom.ID,
+		ToAccountID:    accountTo.ID,
+		Amount:         10,
+		IdempotencyKey: fmt.Sprintf("scheduled_transfer:%d:%d", failing.ID, failing.NextRunAt.Unix()),
+		Username:       accountFrom.Owner,
+	})
+	require.NoError(t, err)
+
+	arg := RunScheduledTransfersParams{
+		Now:         now,
+		CatchUp:     CatchUpOnce,
+		MissedAfter: 2 * time.Minute,
+		Lease:       time.Minute,
+	}
+	_, err = store.RunScheduledTransfers(ctx, arg)
+	require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// the run is not recorded, it stays due under its lease
+	require.Empty(t, listRuns(t, failing.ID))
+	leased, err := store.GetScheduledTransfer(ctx, failing.ID)
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferStatusActive, leased.Status)
+	require.WithinDuration(t, failing.NextRunAt, leased.NextRunAt, time.Second)
+	require.NotNil(t, leased.LeasedUntil)
+	require.WithinDuration(t, now.Add(arg.Lease), *leased.LeasedUntil, time.Second)
+
+	// the failing run did not hold up the others
+	scheduledRuns := listRuns(t, scheduled.ID)
+	require.Len(t, scheduledRuns, 1)
+	require.Equal(t, ScheduledTransferRunSucceeded, scheduledRuns[0].Status)
+
+	// not retried before the lease is over
+	_, err = store.RunScheduledTransfers(ctx, arg)
+	require.NoError(t, err)
+	require.Empty(t, listRuns(t, failing.ID))
+
+	// retried with the same idempotency key after it
+	arg.Now = now.Add(arg.Lease)
+	_, err = store.RunScheduledTransfers(ctx, arg)
+	require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	require.Empty(t, listRuns(t, failing.ID))
+
+	// so that the runs of the other tests do not retry it
+	_, err = store.CancelScheduledTransfer(ctx, failing.ID)
+	require.NoError(t, err)
+}
+
+func TestRunScheduledTransfersInvalidSchedule(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+	now := time.Now().Truncate(time.Second)
+	// a schedule stored before its rules were tightened
+	broken := createRandomScheduledTransfer(t, accountFrom, accountTo, 100, "@every 10s", now.Add(-2*time.Second), nil)
+	scheduled := createRandomScheduledTransfer(t, accountFrom, accountTo, 100, "@every 24h", now.Add(-time.Second), nil)
+
+	runs, err := store.RunScheduledTransfers(ctx, RunScheduledTransfersParams{
+		Now:         now,
+		CatchUp:     CatchUpOnce,
+		MissedAfter: 2 * time.Minute,
+	})
+	require.ErrorIs(t, err, ErrInvalidSchedule)
+	require.GreaterOrEqual(t, runs, 2)
+
+	brokenRuns := listRuns(t, broken.ID)
+	require.Len(t, brokenRuns, 1)
+	require.Equal(t, ScheduledTransferRunFailed, brokenRuns[0].Status)
+	require.Equal(t, scheduledTransferRunInvalidSchedule, brokenRuns[0].Error)
+
+	updated, err := store.GetScheduledTransfer(ctx, broken.ID)
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferStatusFinished, updated.Status)
+
+	// the broken schedule did not hold up the others
+	scheduledRuns := listRuns(t, scheduled.ID)
+	require.Len(t, scheduledRuns, 1)
+	require.Equal(t, ScheduledTransferRunSucceeded, scheduledRuns[0].Status)
+}
+
+func TestRunScheduledTransfersFinished(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+	now := time.Now().Truncate(time.Second)
+	endsAt := now.Add(time.Hour)
+	scheduled := createRandomScheduledTransfer(t, accountFrom, accountTo, 100, "@every 24h", now.Add(-time.Second), &endsAt)
+
+	_, err := store.RunScheduledTransfers(ctx, RunScheduledTransfersParams{
+		Now:         now,
+		CatchUp:     CatchUpOnce,
+		MissedAfter: 2 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	updated, err := store.GetScheduledTransfer(ctx, scheduled.ID)
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferStatusFinished, updated.Status)
+	require.Len(t, listRuns(t, scheduled.ID), 1)
+}
+
+func TestRunScheduledTransfersConcurrent(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+	now := time.Now().Truncate(time.Second)
+	scheduled := createRandomScheduledTransfer(t, accountFrom, accountTo, 100, "@every 24h", now.Add(-time.Second), nil)
+
+	// workers racing for the same due runs
+	n := 5
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.RunScheduledTransfers(ctx, RunScheduledTransfersParams{
+				Now:         now,
+				CatchUp:     CatchUpAll,
+				MissedAfter: 2 * time.Minute,
+			})
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		err := <-errs
+		require.NoError(t, err)
+	}
+
+	// each run is executed exactly once
+	require.Len(t, listRuns(t, scheduled.ID), 1)
+
+	updatedAccountFrom, err := store.GetAccount(ctx, accountFrom.ID)
+	require.NoError(t, err)
+	require.Equal(t, accountFrom.Balance-100, updatedAccountFrom.Balance)
+}
+
+func TestRunScheduledTransfersInvalidCatchUpPolicy(t *testing.T) {
+	store := NewStore(testDB)
+
+	_, err := store.RunScheduledTransfers(context.Background(), RunScheduledTransfersParams{
+		Now:     time.Now(),
+		CatchUp: "sometimes",
+	})
+	require.ErrorIs(t, err, ErrInvalidCatchUpPolicy)
+}
+
+func TestCancelScheduledTransfer(t *testing.T) {
+	ctx := context.Background()
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+	scheduled := createRandomScheduledTransfer(t, accountFrom, accountTo, 100, "@monthly", time.Now().Add(time.Hour), nil)
+
+	cancelled, err := testQueries.CancelScheduledTransfer(ctx, scheduled.ID)
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferStatusCancelled, cancelled.Status)
+
+	_, err = testQueries.CancelScheduledTransfer(ctx, scheduled.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	scheduledTransfers, err := testQueries.ListScheduledTransfers(ctx, accountFrom.Owner)
+	require.NoError(t, err)
+	require.Len(t, scheduledTransfers, 1)
+	require.Equal(t, cancelled, scheduledTransfers[0])
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomSession(t *testing.T, user User) Session {
+	arg := CreateSessionParams{
+		ID:           uuid.New(),
+		Username:     user.Username,
+		RefreshToken: util.RandomString(32),
+		UserAgent:    "test-agent",
+		ClientIp:     "127.0.0.1",
+		IsBlocked:    false,
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+
+	session, err := testQueries.CreateSession(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, session)
+
+	require.Equal(t, arg.ID, session.ID)
+	require.Equal(t, arg.Username, session.Username)
+	require.Equal(t, arg.RefreshToken, session.RefreshToken)
+	require.Equal(t, arg.UserAgent, session.UserAgent)
+	require.Equal(t, arg.ClientIp, session.ClientIp)
+	require.False(t, session.IsBlocked)
+	require.WithinDuration(t, arg.ExpiresAt, session.ExpiresAt, time.Second)
+	require.NotZero(t, session.CreatedAt)
+
+	return session
+}
+
+func TestQueries_CreateSession(t *testing.T) {
+	createRandomSession(t, createRandomUser(t))
+}
+
+func TestQueries_GetSession(t *testing.T) {
+	sessionExpected := createRandomSession(t, createRandomUser(t))
+
+	sessionActual, err := testQueries.GetSession(context.Background(), sessionExpected.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, sessionActual)
+
+	require.Equal(t, sessionExpected.ID, sessionActual.ID)
+	require.Equal(t, sessionExpected.Username, sessionActual.Username)
+	require.Equal(t, sessionExpected.RefreshToken, sessionActual.RefreshToken)
+	require.Equal(t, sessionExpected.IsBlocked, sessionActual.IsBlocked)
+	require.WithinDuration(t, sessionExpected.ExpiresAt, sessionActual.ExpiresAt, time.Second)
+}
+
+func TestQueries_BlockUserSessions(t *testing.T) {
+	user := createRandomUser(t)
+	session1 := createRandomSession(t, user)
+	session2 := createRandomSession(t, user)
+	otherSession := createRandomSession(t, createRandomUser(t))
+
+	revoked, err := testQueries.BlockUserSessions(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), revoked)
+
+	for _, id := range []uuid.UUID{session1.ID, session2.ID} {
+		session, err := testQueries.GetSession(context.Background(), id)
+		require.NoError(t, err)
+		require.True(t, session.IsBlocked)
+	}
+
+	session, err := testQueries.GetSession(context.Background(), otherSession.ID)
+	require.NoError(t, err)
+	require.False(t, session.IsBlocked)
+
+	// already blocked sessions are not counted again
+	revoked, err = testQueries.BlockUserSessions(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+}
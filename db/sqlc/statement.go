@@ -0,0 +1,166 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type StatementTxParams struct {
+	AccountID int64     `json:"account_id"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+}
+
+// StatementSummary is the account and its balances at the start and at the end of a statement period.
+type StatementSummary struct {
+	Account        Account   `json:"account"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	OpeningBalance int64     `json:"opening_balance"`
+	ClosingBalance int64     `json:"closing_balance"`
+}
+
+// StatementLine is an entry of a statement with the balance of the account right after it.
+type StatementLine struct {
+	Entry
+	Balance int64 `json:"balance"`
+}
+
+type StatementTxResult struct {
+	StatementSummary
+	Entries []StatementLine `json:"entries"`
+}
+
+// statementTxOptions makes all reads of a statement see the same snapshot,
+// since the balances are worked back from the current balance and the entries since then.
+var statementTxOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+// StatementTx builds the statement of an account for the period [From, To).
+func (store *SQLStore) StatementTx(ctx context.Context, arg StatementTxParams) (StatementTxResult, error) {
+	var result StatementTxResult
+
+	_, err := store.execTx(ctx, statementTxOptions, func(queries *Queries) error {
+		var err error
+		result = StatementTxResult{}
+
+		result.StatementSummary, err = statementSummary(ctx, queries, arg)
+		if err != nil {
+			return err
+		}
+
+		entries, err := queries.ListEntriesBetween(ctx, ListEntriesBetweenParams{
+			AccountID: arg.AccountID,
+			FromTime:  arg.From,
+			ToTime:    arg.To,
+		})
+		if err != nil {
+			return err
+		}
+
+		balance := result.OpeningBalance
+		result.Entries = make([]StatementLine, 0, len(entries))
+		for _, entry := range entries {
+			balance += entry.Amount
+			result.Entries = append(result.Entries, StatementLine{Entry: entry, Balance: balance})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// StreamStatementTx is StatementTx for periods too large to hold in memory.
+// summaryFn is called first, then lineFn for every entry of the period in order, reading one entry
+// at a time from the db. An error returned by either stops the stream and is returned as is.
+// Unlike other txs it is never retried, as the callbacks are expected to write their output right away.
+func (store *SQLStore) StreamStatementTx(ctx context.Context, arg StatementTxParams,
+	summaryFn func(summary StatementSummary) error,
+	lineFn func(line StatementLine) error,
+) error {
+	return store.execTxOnce(ctx, statementTxOptions, func(queries *Queries) error {
+		summary, err := statementSummary(ctx, queries, arg)
+		if err != nil {
+			return err
+		}
+
+		err = summaryFn(summary)
+		if err != nil {
+			return err
+		}
+
+		balance := summary.OpeningBalance
+		return queries.eachEntryBetween(ctx, ListEntriesBetweenParams{
+			AccountID: arg.AccountID,
+			FromTime:  arg.From,
+			ToTime:    arg.To,
+		}, func(entry Entry) error {
+			balance += entry.Amount
+			return lineFn(StatementLine{Entry: entry, Balance: balance})
+		})
+	})
+}
+
+// statementSummary works the opening and closing balances back from the current balance.
+func statementSummary(ctx context.Context, queries *Queries, arg StatementTxParams) (StatementSummary, error) {
+	account, err := queries.GetAccount(ctx, arg.AccountID)
+	if err != nil {
+		return StatementSummary{}, err
+	}
+
+	sinceFrom, err := queries.SumEntriesSince(ctx, SumEntriesSinceParams{AccountID: arg.AccountID, Since: arg.From})
+	if err != nil {
+		return StatementSummary{}, err
+	}
+
+	sinceTo, err := queries.SumEntriesSince(ctx, SumEntriesSinceParams{AccountID: arg.AccountID, Since: arg.To})
+	if err != nil {
+		return StatementSummary{}, err
+	}
+
+	return StatementSummary{
+		Account:        account,
+		From:           arg.From,
+		To:             arg.To,
+		OpeningBalance: account.Balance - sinceFrom,
+		ClosingBalance: account.Balance - sinceTo,
+	}, nil
+}
+
+// eachEntryBetween runs the ListEntriesBetween query and calls fn for every row as it is read.
+func (q *Queries) eachEntryBetween(ctx context.Context, arg ListEntriesBetweenParams, fn func(entry Entry) error) error {
+	rows, err := q.db.QueryContext(ctx, listEntriesBetween, arg.AccountID, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i Entry
+		if err := rows.Scan(entryScanTargets(&i)...); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// entryScanTargets lists the fields of an entry in the column order of the entry queries,
+// so a hand-written row loop scans the same columns as the generated code.
+func entryScanTargets(i *Entry) []any {
+	return []any{
+		&i.ID,
+		&i.AccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.TransferID,
+	}
+}
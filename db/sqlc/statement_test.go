@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_StatementTx(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithCurrency(t, 1000, util.USD)
+	account2 := createRandomAccountWithCurrency(t, 1000, util.USD)
+
+	transfers := []TransferTxParams{
+		{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 10},
+		{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 20},
+		{FromAccountID: account2.ID, ToAccountID: account1.ID, Amount: 5},
+	}
+	for _, transfer := range transfers {
+		_, err := store.TransferTx(ctx, transfer)
+		require.NoError(t, err)
+	}
+
+	from := account1.CreatedAt
+	to := time.Now().Add(time.Hour)
+	result, err := store.StatementTx(ctx, StatementTxParams{AccountID: account1.ID, From: from, To: to})
+	require.NoError(t, err)
+
+	require.Equal(t, account1.ID, result.Account.ID)
+	require.Equal(t, int64(975), result.Account.Balance)
+	require.Equal(t, int64(1000), result.OpeningBalance)
+	require.Equal(t, int64(975), result.ClosingBalance)
+
+	require.Len(t, result.Entries, 3)
+	wantBalances := []int64{990, 970, 975}
+	for i, line := range result.Entries {
+		require.Equal(t, account1.ID, line.AccountID)
+		require.Equal(t, wantBalances[i], line.Balance)
+	}
+
+	// a period after the last entry opens and closes with the current balance
+	result, err = store.StatementTx(ctx, StatementTxParams{AccountID: account1.ID, From: to, To: to.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, int64(975), result.OpeningBalance)
+	require.Equal(t, int64(975), result.ClosingBalance)
+	require.Empty(t, result.Entries)
+}
+
+func TestStore_StreamStatementTx(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithCurrency(t, 1000, util.USD)
+	account2 := createRandomAccountWithCurrency(t, 1000, util.USD)
+
+	for i := 0; i < 3; i++ {
+		_, err := store.TransferTx(ctx, TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 10})
+		require.NoError(t, err)
+	}
+
+	arg := StatementTxParams{AccountID: account1.ID, From: account1.CreatedAt, To: time.Now().Add(time.Hour)}
+	want, err := store.StatementTx(ctx, arg)
+	require.NoError(t, err)
+
+	var summary StatementSummary
+	var lines []StatementLine
+	err = store.StreamStatementTx(ctx, arg,
+		func(s StatementSummary) error {
+			require.Empty(t, lines)
+			summary = s
+			return nil
+		},
+		func(line StatementLine) error {
+			lines = append(lines, line)
+			return nil
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, want.StatementSummary, summary)
+	require.Equal(t, int64(970), summary.ClosingBalance)
+	require.Equal(t, want.Entries, lines)
+	require.Len(t, lines, 3)
+	for _, line := range lines {
+		require.NotNil(t, line.TransferID)
+	}
+
+	// an error of a callback stops the stream
+	errStop := errors.New("stop")
+	calls := 0
+	err = store.StreamStatementTx(ctx, arg,
+		func(s StatementSummary) error { return nil },
+		func(line StatementLine) error {
+			calls++
+			return errStop
+		},
+	)
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, 1, calls)
+}
+
+func TestEntryScanTargets(t *testing.T) {
+	var entry Entry
+	targets := entryScanTargets(&entry)
+	require.Len(t, targets, reflect.TypeOf(entry).NumField())
+
+	columns := listEntriesBetween[strings.Index(listEntriesBetween, "SELECT")+len("SELECT") : strings.Index(listEntriesBetween, "FROM")]
+	require.Len(t, targets, len(strings.Split(columns, ",")))
+}
@@ -2,35 +2,135 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrInsufficientFunds is returned when a transfer would overdraw the source account.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrIdempotencyKeyReused is returned when an idempotency key is replayed with a different request.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+	// ErrCurrencyMismatch is returned when a transfer between accounts of different currencies has no exchange rate,
+	// or a transfer within one currency has one.
+	ErrCurrencyMismatch = errors.New("currency mismatch")
 )
 
+// sameCurrencyRate is the exchange rate recorded for transfers without conversion.
+const sameCurrencyRate = "1"
+
 // Store provides all funcs to execute db queries and transactions
 type Store interface {
 	Querier
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	CreateAccountTx(ctx context.Context, arg CreateAccountParams) (Account, error)
+	ChangeAccountStatusTx(ctx context.Context, arg ChangeAccountStatusTxParams) (ChangeAccountStatusTxResult, error)
+	AuthorizeHoldTx(ctx context.Context, arg AuthorizeHoldTxParams) (HoldTxResult, error)
+	CaptureHoldTx(ctx context.Context, arg CaptureHoldTxParams) (CaptureHoldTxResult, error)
+	VoidHoldTx(ctx context.Context, holdID int64) (HoldTxResult, error)
+	ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (ReverseTransferTxResult, error)
+	ExpireHolds(ctx context.Context, now time.Time) (int, error)
+	RunScheduledTransfers(ctx context.Context, arg RunScheduledTransfersParams) (int, error)
+	RelayOutboxEvents(ctx context.Context, batchSize int32,
+		publishFn func(ctx context.Context, event OutboxEvent) error,
+	) (int, error)
+	EnqueueWebhookDeliveries(ctx context.Context, event OutboxEvent) (int, error)
+	DeliverWebhooks(ctx context.Context, arg DeliverWebhooksParams,
+		deliverFn func(ctx context.Context, delivery WebhookDelivery, subscription WebhookSubscription) WebhookAttempt,
+	) (int, error)
+	ChainAuditLog(ctx context.Context, batchSize int32) (int, error)
+	VerifyAuditLog(ctx context.Context) (AuditLogVerification, error)
+	ReconcileLedger(ctx context.Context) (ReconciliationReport, error)
+	StatementTx(ctx context.Context, arg StatementTxParams) (StatementTxResult, error)
+	StreamStatementTx(ctx context.Context, arg StatementTxParams,
+		summaryFn func(summary StatementSummary) error,
+		lineFn func(line StatementLine) error,
+	) error
+	Ping(ctx context.Context) error
+	Stats() sql.DBStats
+	MigrationVersion(ctx context.Context) (version int64, dirty bool, err error)
 }
 
 // SQLStore provides all funcs to execute SQL queries and transactions
 type SQLStore struct {
 	*Queries
-	db *sql.DB
+	db          *sql.DB
+	retryPolicy RetryPolicy
+	tracer      trace.Tracer
+}
+
+// StoreOption configures a SQLStore created by NewStore.
+type StoreOption func(store *SQLStore)
+
+// WithRetryPolicy sets the policy for retrying txs that failed with a serialization failure or a deadlock.
+func WithRetryPolicy(policy RetryPolicy) StoreOption {
+	return func(store *SQLStore) {
+		store.retryPolicy = policy
+	}
+}
+
+func NewStore(db *sql.DB, opts ...StoreOption) Store {
+	store := &SQLStore{
+		db:          db,
+		retryPolicy: DefaultRetryPolicy,
+		tracer:      otel.GetTracerProvider().Tracer(tracerName),
+	}
+
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	store.Queries = New(tracedDBTX{db: db, tracer: store.tracer})
+	return store
 }
 
-func NewStore(db *sql.DB) Store {
-	return &SQLStore{
-		Queries: New(db),
-		db:      db,
+// execTx runs queryFn within a db tx with the given options, nil meaning the driver defaults.
+// A tx failing with a serialization failure or a deadlock is rolled back and run again according to
+// the retry policy, so queryFn must not have side effects outside the tx.
+// It returns the number of retries it took.
+func (store *SQLStore) execTx(ctx context.Context, txOptions *sql.TxOptions, queryFn func(queries *Queries) error) (int, error) {
+	retries := 0
+	for {
+		err := store.execTxOnce(ctx, txOptions, queryFn)
+		if err == nil || !IsRetryableTxError(err) || retries+1 >= store.retryPolicy.MaxAttempts {
+			return retries, err
+		}
+
+		retries++
+		slog.WarnContext(ctx, "retrying db tx", "retry", retries, "error", err)
+		if store.retryPolicy.OnRetry != nil {
+			store.retryPolicy.OnRetry(retries, err)
+		}
+
+		if errWait := store.retryPolicy.wait(ctx, retries); errWait != nil {
+			return retries, err
+		}
 	}
 }
 
-func (store *SQLStore) execTx(ctx context.Context, queryFn func(queries *Queries) error) error {
-	tx, err := store.db.BeginTx(ctx, nil)
+// execTxOnce runs queryFn within a db tx, traced by a span of its own, every attempt of execTx getting one.
+func (store *SQLStore) execTxOnce(ctx context.Context, txOptions *sql.TxOptions, queryFn func(queries *Queries) error) (err error) {
+	ctx, span := store.startTxSpan(ctx, txOptions)
+	defer func() {
+		recordError(span, err)
+		span.End()
+	}()
+
+	tx, err := store.db.BeginTx(ctx, txOptions)
 	if err != nil {
 		return err
 	}
 
-	queries := New(tx)
+	queries := New(tracedDBTX{db: tx, tracer: store.tracer, txSpan: span})
 	err = queryFn(queries)
 	if err != nil {
 		if errRb := tx.Rollback(); errRb != nil {
@@ -51,6 +151,21 @@ type TransferTxParams struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
 	Amount        int64 `json:"amount"`
+	// ToAmount and ExchangeRate are only set for a transfer between accounts of different currencies.
+	// ToAmount is the amount credited to the to account, converted from Amount with ExchangeRate.
+	ToAmount     int64  `json:"to_amount,omitempty"`
+	ExchangeRate string `json:"exchange_rate,omitempty"`
+	// IdempotencyKey is optional. When set, a retry with the same key by the same Username
+	// returns the result of the first transfer instead of moving the money again.
+	IdempotencyKey string `json:"-"`
+	Username       string `json:"-"`
+}
+
+// requestHash identifies the transfer an idempotency key was first used for.
+// The converted amount is left out on purpose, so a retry still matches after the exchange rate moved.
+func (arg TransferTxParams) requestHash() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%d:%d", arg.FromAccountID, arg.ToAccountID, arg.Amount)))
+	return hex.EncodeToString(sum[:])
 }
 
 type TransferTxResult struct {
@@ -59,35 +174,47 @@ type TransferTxResult struct {
 	ToAccount   Account  `json:"to_account"`
 	FromEntry   Entry    `json:"from_entry"`
 	ToEntry     Entry    `json:"to_entry"`
+	// Replayed is true if the result was stored by an earlier transfer with the same idempotency key.
+	Replayed bool `json:"-"`
+	// Retries is the number of times the db tx was retried after a serialization failure or a deadlock.
+	Retries int `json:"-"`
 }
 
 // TransferTx performs a money transfer from one account to the other.
-// It creates a transfer record, an entry record, and update accounts' balances within a single db tx
+// It creates a transfer record, an entry record, and update accounts' balances within a single db tx.
+// The tx is rolled back with ErrInsufficientFunds if the from account would end up with a negative available balance,
+// so money reserved by holds cannot be transferred.
+// If an idempotency key is given, it is stored with the result in the same db tx.
+// The events of the transfer are written to the outbox and the transfer to the audit log in the same db tx,
+// a replayed transfer writes neither.
+// Accounts of different currencies need an exchange rate, otherwise the tx is rolled back with ErrCurrencyMismatch.
+// It is rolled back with ErrAccountNotActive if either account is frozen or closed.
 func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
 	var result TransferTxResult
 
-	err := store.execTx(ctx, func(queries *Queries) error {
+	retries, err := store.execTx(ctx, nil, func(queries *Queries) error {
 		var err error
+		// start from scratch on every attempt
+		result = TransferTxResult{}
 
-		// create transfer
-		result.Transfer, err = queries.CreateTransfer(ctx, CreateTransferParams(arg))
-		if err != nil {
-			return err
+		if arg.IdempotencyKey != "" {
+			replayed, err := claimIdempotencyKey(ctx, queries, arg, &result)
+			if err != nil || replayed {
+				return err
+			}
 		}
 
-		// create entry for 'the from account'
-		result.FromEntry, err = queries.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.FromAccountID,
-			Amount:    -arg.Amount,
-		})
-		if err != nil {
-			return err
+		toAmount, exchangeRate := arg.Amount, sameCurrencyRate
+		if arg.ExchangeRate != "" {
+			toAmount, exchangeRate = arg.ToAmount, arg.ExchangeRate
 		}
 
-		// create entry for 'the to account'
-		result.ToEntry, err = queries.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.ToAccountID,
-			Amount:    arg.Amount,
+		result.Transfer, result.FromEntry, result.ToEntry, err = recordTransfer(ctx, queries, CreateTransferParams{
+			FromAccountID: arg.FromAccountID,
+			ToAccountID:   arg.ToAccountID,
+			Amount:        arg.Amount,
+			ToAmount:      toAmount,
+			ExchangeRate:  exchangeRate,
 		})
 		if err != nil {
 			return err
@@ -96,19 +223,55 @@ func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (Tr
 		// update balances
 		// to avoid deadlock
 		if arg.FromAccountID < arg.ToAccountID {
-			result.FromAccount, result.ToAccount, err = addMoney(ctx, queries, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount)
+			result.FromAccount, result.ToAccount, err = addMoney(ctx, queries, arg.FromAccountID, -arg.Amount, arg.ToAccountID, toAmount)
 			if err != nil {
 				return err
 			}
 		} else {
-			result.ToAccount, result.FromAccount, err = addMoney(ctx, queries, arg.ToAccountID, arg.Amount, arg.FromAccountID, -arg.Amount)
+			result.ToAccount, result.FromAccount, err = addMoney(ctx, queries, arg.ToAccountID, toAmount, arg.FromAccountID, -arg.Amount)
 			if err != nil {
 				return err
 			}
 		}
 
-		return nil
+		// the balance update holds the row lock, so the returned status and balance are the ones being committed
+		if result.FromAccount.Status != AccountStatusActive || result.ToAccount.Status != AccountStatusActive {
+			return ErrAccountNotActive
+		}
+
+		if result.FromAccount.AvailableBalance < 0 {
+			return ErrInsufficientFunds
+		}
+
+		convert := result.FromAccount.Currency != result.ToAccount.Currency
+		if convert != (arg.ExchangeRate != "") {
+			return ErrCurrencyMismatch
+		}
+
+		err = writeTransferEvents(ctx, queries, result.Transfer, result.FromAccount, result.FromEntry, result.ToAccount, result.ToEntry)
+		if err != nil {
+			return err
+		}
+
+		if arg.IdempotencyKey != "" {
+			response, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+
+			err = queries.UpdateIdempotencyKeyResponse(ctx, UpdateIdempotencyKeyResponseParams{
+				Username:       arg.Username,
+				IdempotencyKey: arg.IdempotencyKey,
+				Response:       response,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return writeAudit(ctx, queries, AuditTransferCreate, ResourceTransfer, auditID(result.Transfer.ID), nil, result.Transfer)
 	})
+	result.Retries = retries
 	if err != nil {
 		return result, err
 	}
@@ -116,6 +279,76 @@ func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (Tr
 	return result, nil
 }
 
+// claimIdempotencyKey stores the idempotency key of the transfer.
+// If the key is already taken, the stored result is loaded into result and replayed is true.
+// An insert racing with an uncommitted one for the same key waits until the other tx finishes.
+func claimIdempotencyKey(ctx context.Context, queries *Queries, arg TransferTxParams, result *TransferTxResult) (bool, error) {
+	requestHash := arg.requestHash()
+
+	_, err := queries.CreateIdempotencyKey(ctx, CreateIdempotencyKeyParams{
+		Username:       arg.Username,
+		IdempotencyKey: arg.IdempotencyKey,
+		RequestHash:    requestHash,
+	})
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	// the key exists already
+	idempotencyKey, err := queries.GetIdempotencyKey(ctx, GetIdempotencyKeyParams{
+		Username:       arg.Username,
+		IdempotencyKey: arg.IdempotencyKey,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if idempotencyKey.RequestHash != requestHash {
+		return false, ErrIdempotencyKeyReused
+	}
+
+	err = json.Unmarshal(idempotencyKey.Response, result)
+	if err != nil {
+		return false, err
+	}
+	result.Replayed = true
+
+	return true, nil
+}
+
+// recordTransfer records a transfer and the entries of both accounts, leaving the balances to the caller.
+func recordTransfer(ctx context.Context, queries *Queries, arg CreateTransferParams) (Transfer, Entry, Entry, error) {
+	transfer, err := queries.CreateTransfer(ctx, arg)
+	if err != nil {
+		return Transfer{}, Entry{}, Entry{}, err
+	}
+
+	// create entry for 'the from account'
+	fromEntry, err := queries.CreateEntry(ctx, CreateEntryParams{
+		AccountID:  arg.FromAccountID,
+		Amount:     -arg.Amount,
+		TransferID: &transfer.ID,
+	})
+	if err != nil {
+		return Transfer{}, Entry{}, Entry{}, err
+	}
+
+	// create entry for 'the to account'
+	toEntry, err := queries.CreateEntry(ctx, CreateEntryParams{
+		AccountID:  arg.ToAccountID,
+		Amount:     arg.ToAmount,
+		TransferID: &transfer.ID,
+	})
+	if err != nil {
+		return Transfer{}, Entry{}, Entry{}, err
+	}
+
+	return transfer, fromEntry, toEntry, nil
+}
+
 func addMoney(ctx context.Context, query *Queries,
 	accountIDFrom int64, amountFrom int64,
 	accountIDTo int64, amountTo int64,
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/anilbolat/simple-bank/util"
 	"github.com/stretchr/testify/require"
 )
 
@@ -12,8 +13,8 @@ func TestStore_TransferTx(t *testing.T) {
 	ctx := context.Background()
 	store := NewStore(testDB)
 
-	accountFromInit := createRandomAccount(t)
-	accountToInit := createRandomAccount(t)
+	accountFromInit := createRandomAccountWithCurrency(t, 1000, util.USD)
+	accountToInit := createRandomAccountWithCurrency(t, 1000, util.USD)
 	fmt.Println(">> before:", accountFromInit.Balance, accountToInit.Balance)
 
 	amount := int64(10)
@@ -57,6 +58,10 @@ func TestStore_TransferTx(t *testing.T) {
 		_, err = store.GetEntry(ctx, result.ToEntry.ID)
 		require.NoError(t, err)
 
+		// the entries are linked to the transfer they were written for
+		require.Equal(t, &result.Transfer.ID, result.FromEntry.TransferID)
+		require.Equal(t, &result.Transfer.ID, result.ToEntry.TransferID)
+
 		// check accounts in transfer obj
 		accountFrom := result.FromAccount
 		require.NotEmpty(t, accountFrom)
@@ -97,8 +102,8 @@ func TestTransferTxDeadlock(t *testing.T) {
 	ctx := context.Background()
 	store := NewStore(testDB)
 
-	accountFrom := createRandomAccount(t)
-	accountTo := createRandomAccount(t)
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.USD)
+	accountTo := createRandomAccountWithCurrency(t, 1000, util.USD)
 	fmt.Println(">> before:", accountFrom.Balance, accountTo.Balance)
 
 	n := 10
@@ -142,6 +147,222 @@ func TestTransferTxDeadlock(t *testing.T) {
 	require.Equal(t, accountTo.Balance, updatedAccount2.Balance)
 }
 
+func TestTransferTxInsufficientFunds(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 100, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+	fmt.Println(">> before:", accountFrom.Balance, accountTo.Balance)
+
+	n := 10
+	amount := int64(30)
+	errs := make(chan error)
+	results := make(chan TransferTxResult)
+
+	for i := 0; i < n; i++ {
+		go func() {
+			result, err := store.TransferTx(ctx, TransferTxParams{
+				FromAccountID: accountFrom.ID,
+				ToAccountID:   accountTo.ID,
+				Amount:        amount,
+			})
+
+			errs <- err
+			results <- result
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		result := <-results
+		if err != nil {
+			require.ErrorIs(t, err, ErrInsufficientFunds)
+			continue
+		}
+
+		succeeded++
+		require.GreaterOrEqual(t, result.FromAccount.Balance, int64(0))
+	}
+
+	// only as many transfers as the initial balance covers may succeed
+	expectedSucceeded := int(accountFrom.Balance / amount)
+	require.Equal(t, expectedSucceeded, succeeded)
+
+	updatedAccountFrom, err := store.GetAccount(ctx, accountFrom.ID)
+	require.NoError(t, err)
+	updatedAccountTo, err := store.GetAccount(ctx, accountTo.ID)
+	require.NoError(t, err)
+
+	fmt.Println(">> after:", updatedAccountFrom.Balance, updatedAccountTo.Balance)
+	require.GreaterOrEqual(t, updatedAccountFrom.Balance, int64(0))
+	require.Equal(t, accountFrom.Balance-int64(succeeded)*amount, updatedAccountFrom.Balance)
+	require.Equal(t, accountTo.Balance+int64(succeeded)*amount, updatedAccountTo.Balance)
+}
+
+func TestTransferTxIdempotency(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.USD)
+	accountTo := createRandomAccountWithCurrency(t, 1000, util.USD)
+
+	amount := int64(10)
+	arg := TransferTxParams{
+		FromAccountID:  accountFrom.ID,
+		ToAccountID:    accountTo.ID,
+		Amount:         amount,
+		IdempotencyKey: util.RandomString(16),
+		Username:       accountFrom.Owner,
+	}
+
+	// run n concurrent retries of the same transfer
+	n := 5
+	errs := make(chan error)
+	results := make(chan TransferTxResult)
+	for i := 0; i < n; i++ {
+		go func() {
+			result, err := store.TransferTx(ctx, arg)
+			errs <- err
+			results <- result
+		}()
+	}
+
+	var transferID int64
+	replayed := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		require.NoError(t, err)
+		result := <-results
+
+		if transferID == 0 {
+			transferID = result.Transfer.ID
+		}
+		require.Equal(t, transferID, result.Transfer.ID)
+		assertTransfer(t, result.Transfer, accountFrom, accountTo, amount)
+
+		if result.Replayed {
+			replayed++
+		}
+	}
+	require.Equal(t, n-1, replayed)
+
+	// the money moved only once
+	updatedAccountFrom, err := store.GetAccount(ctx, accountFrom.ID)
+	require.NoError(t, err)
+	require.Equal(t, accountFrom.Balance-amount, updatedAccountFrom.Balance)
+
+	updatedAccountTo, err := store.GetAccount(ctx, accountTo.ID)
+	require.NoError(t, err)
+	require.Equal(t, accountTo.Balance+amount, updatedAccountTo.Balance)
+
+	// the same key with a different payload is a conflict
+	arg.Amount = amount * 2
+	_, err = store.TransferTx(ctx, arg)
+	require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+}
+
+func TestTransferTxConvertsCurrency(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.USD)
+	accountTo := createRandomAccountWithCurrency(t, 1000, util.EUR)
+
+	result, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountFrom.ID,
+		ToAccountID:   accountTo.ID,
+		Amount:        100,
+		ToAmount:      92,
+		ExchangeRate:  "0.92",
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, int64(100), result.Transfer.Amount)
+	require.Equal(t, int64(92), result.Transfer.ToAmount)
+	require.Equal(t, "0.92", result.Transfer.ExchangeRate)
+
+	assertEntry(t, result.FromEntry, accountFrom, -100)
+	assertEntry(t, result.ToEntry, accountTo, 92)
+
+	require.Equal(t, accountFrom.Balance-100, result.FromAccount.Balance)
+	require.Equal(t, accountTo.Balance+92, result.ToAccount.Balance)
+}
+
+func TestTransferTxCurrencyMismatch(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountUSD := createRandomAccountWithCurrency(t, 1000, util.USD)
+	accountEUR := createRandomAccountWithCurrency(t, 1000, util.EUR)
+	otherAccountUSD := createRandomAccountWithCurrency(t, 1000, util.USD)
+
+	// different currencies without conversion
+	_, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountUSD.ID,
+		ToAccountID:   accountEUR.ID,
+		Amount:        100,
+	})
+	require.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	// conversion within one currency
+	_, err = store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountUSD.ID,
+		ToAccountID:   otherAccountUSD.ID,
+		Amount:        100,
+		ToAmount:      92,
+		ExchangeRate:  "0.92",
+	})
+	require.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	// nothing was moved
+	for _, account := range []Account{accountUSD, accountEUR, otherAccountUSD} {
+		updatedAccount, err := store.GetAccount(ctx, account.ID)
+		require.NoError(t, err)
+		require.Equal(t, account.Balance, updatedAccount.Balance)
+	}
+}
+
+func TestTransferTxAccountNotActive(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(testDB)
+
+	accountFrom := createRandomAccountWithCurrency(t, 1000, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 1000, util.EUR)
+	banker := createRandomUser(t)
+
+	_, err := store.ChangeAccountStatusTx(ctx, ChangeAccountStatusTxParams{
+		AccountID: accountTo.ID,
+		Status:    AccountStatusFrozen,
+		Reason:    "court order",
+		ChangedBy: banker.Username,
+	})
+	require.NoError(t, err)
+
+	// neither debiting nor crediting a frozen account is allowed
+	_, err = store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountFrom.ID,
+		ToAccountID:   accountTo.ID,
+		Amount:        100,
+	})
+	require.ErrorIs(t, err, ErrAccountNotActive)
+
+	_, err = store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountTo.ID,
+		ToAccountID:   accountFrom.ID,
+		Amount:        100,
+	})
+	require.ErrorIs(t, err, ErrAccountNotActive)
+
+	// nothing was moved
+	for _, account := range []Account{accountFrom, accountTo} {
+		updatedAccount, err := store.GetAccount(ctx, account.ID)
+		require.NoError(t, err)
+		require.Equal(t, account.Balance, updatedAccount.Balance)
+	}
+}
+
 func assertEntry(t *testing.T, entry Entry, account Account, amount int64) {
 	require.NotEmpty(t, entry)
 	require.Equal(t, account.ID, entry.AccountID)
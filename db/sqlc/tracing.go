@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer of the store.
+const tracerName = "github.com/anilbolat/simple-bank/db/sqlc"
+
+// WithTracerProvider sets the provider of the spans of the queries and txs, the global provider being the default.
+func WithTracerProvider(provider trace.TracerProvider) StoreOption {
+	return func(store *SQLStore) {
+		store.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// tracedDBTX creates a span for every query, named after the sqlc query it runs.
+// Wrapping the DBTX rather than the Querier also traces the queries run within a tx.
+type tracedDBTX struct {
+	db     DBTX
+	tracer trace.Tracer
+	// txSpan is the span of the tx the queries run in, if any. The queries are its children, even though
+	// the queryFn of a tx runs them with the ctx of the caller.
+	txSpan trace.Span
+}
+
+func (traced tracedDBTX) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := traced.start(ctx, query)
+	defer span.End()
+
+	result, err := traced.db.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return result, err
+}
+
+func (traced tracedDBTX) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := traced.start(ctx, query)
+	defer span.End()
+
+	stmt, err := traced.db.PrepareContext(ctx, query)
+	recordError(span, err)
+	return stmt, err
+}
+
+// QueryContext ends the span when the query returns its rows, before they are read.
+func (traced tracedDBTX) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := traced.start(ctx, query)
+	defer span.End()
+
+	rows, err := traced.db.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+func (traced tracedDBTX) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := traced.start(ctx, query)
+	defer span.End()
+
+	row := traced.db.QueryRowContext(ctx, query, args...)
+	recordError(span, row.Err())
+	return row
+}
+
+func (traced tracedDBTX) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	if traced.txSpan != nil {
+		ctx = trace.ContextWithSpan(ctx, traced.txSpan)
+	}
+
+	operation := queryName(query)
+	return traced.tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(operation),
+			semconv.DBStatement(query),
+		),
+	)
+}
+
+// queryName returns the name sqlc gives the query in its first line, like GetAccount for "-- name: GetAccount :one".
+func queryName(query string) string {
+	line, _, _ := strings.Cut(query, "\n")
+	name, found := strings.CutPrefix(line, "-- name: ")
+	if !found {
+		return "query"
+	}
+
+	name, _, _ = strings.Cut(name, " ")
+	return name
+}
+
+// startTxSpan starts the span of a db tx run by execTx.
+func (store *SQLStore) startTxSpan(ctx context.Context, txOptions *sql.TxOptions) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{semconv.DBSystemPostgreSQL}
+	if txOptions != nil {
+		attributes = append(attributes,
+			attribute.String("db.tx.isolation", txOptions.Isolation.String()),
+			attribute.Bool("db.tx.read_only", txOptions.ReadOnly),
+		)
+	}
+
+	return store.tracer.Start(ctx, "execTx", trace.WithAttributes(attributes...))
+}
+
+// recordError marks the span as failed, unless no rows were found, which is an expected outcome of a query.
+func recordError(span trace.Span, err error) {
+	if err == nil || errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryName(t *testing.T) {
+	require.Equal(t, "GetAccount", queryName(getAccount))
+	require.Equal(t, "ListEntriesBetween", queryName(listEntriesBetween))
+	require.Equal(t, "query", queryName("SELECT 1"))
+}
+
+func TestStore_TransferTxSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	store := NewStore(testDB, WithTracerProvider(provider))
+
+	accountFrom := createRandomAccountWithCurrency(t, 100, util.EUR)
+	accountTo := createRandomAccountWithCurrency(t, 0, util.EUR)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "POST /transfers")
+	_, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: accountFrom.ID,
+		ToAccountID:   accountTo.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	spansByName := make(map[string]tracetest.SpanStub)
+	for _, span := range spans {
+		spansByName[span.Name] = span
+	}
+
+	txSpan, ok := spansByName["execTx"]
+	require.True(t, ok)
+	require.Equal(t, parent.SpanContext().SpanID(), txSpan.Parent.SpanID())
+
+	// the queries run within the tx are children of its span
+	for _, name := range []string{"CreateTransfer", "CreateEntry", "AddAccountBalance"} {
+		span, ok := spansByName[name]
+		require.True(t, ok, name)
+		require.Equal(t, txSpan.SpanContext.SpanID(), span.Parent.SpanID(), name)
+		require.Equal(t, parent.SpanContext().TraceID(), span.SpanContext.TraceID(), name)
+	}
+
+	// queries outside of a tx are children of the span of the caller
+	ctx, parent = provider.Tracer("test").Start(context.Background(), "GET /accounts/:id")
+	_, err = store.GetAccount(ctx, accountFrom.ID)
+	require.NoError(t, err)
+	parent.End()
+
+	spans = exporter.GetSpans()
+	getAccountSpan := spans[len(spans)-2]
+	require.Equal(t, "GetAccount", getAccountSpan.Name)
+	require.Equal(t, parent.SpanContext().SpanID(), getAccountSpan.Parent.SpanID())
+}
@@ -9,10 +9,13 @@ import (
 )
 
 func createRandomTransfer(accountFromExpected Account, accountToExpected Account, t *testing.T) Transfer {
+	amount := util.RandomMoney()
 	arg := CreateTransferParams{
 		FromAccountID: accountFromExpected.ID,
 		ToAccountID:   accountToExpected.ID,
-		Amount:        util.RandomMoney(),
+		Amount:        amount,
+		ToAmount:      amount,
+		ExchangeRate:  "1",
 	}
 
 	transferActual, err := testQueries.CreateTransfer(context.Background(), arg)
@@ -22,6 +25,8 @@ func createRandomTransfer(accountFromExpected Account, accountToExpected Account
 	require.Equal(t, arg.FromAccountID, transferActual.FromAccountID)
 	require.Equal(t, arg.ToAccountID, transferActual.ToAccountID)
 	require.Equal(t, arg.Amount, transferActual.Amount)
+	require.Equal(t, arg.ToAmount, transferActual.ToAmount)
+	require.Equal(t, arg.ExchangeRate, transferActual.ExchangeRate)
 
 	require.NotZero(t, transferActual.CreatedAt)
 	require.NotZero(t, transferActual.ID)
@@ -76,3 +81,34 @@ func TestQueries_ListTransfers(t *testing.T) {
 		require.True(t, transfer.FromAccountID == accountFromExpected.ID || transfer.ToAccountID == accountFromExpected.ID)
 	}
 }
+
+func TestQueries_ListTransfersAfter(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+
+	for i := 0; i < 5; i++ {
+		createRandomTransfer(account1, account2, t)
+		createRandomTransfer(account2, account1, t)
+	}
+
+	seen := make(map[int64]bool)
+	arg := ListTransfersAfterParams{FromAccountID: account1.ID, ToAccountID: account1.ID, Limit: 3}
+	for {
+		transfers, err := testQueries.ListTransfersAfter(context.Background(), arg)
+		require.NoError(t, err)
+		if len(transfers) == 0 {
+			break
+		}
+
+		for _, transfer := range transfers {
+			require.False(t, seen[transfer.ID])
+			seen[transfer.ID] = true
+			require.True(t, transfer.FromAccountID == account1.ID || transfer.ToAccountID == account1.ID)
+		}
+
+		last := transfers[len(transfers)-1]
+		arg.AfterCreatedAt, arg.AfterID = last.CreatedAt, last.ID
+	}
+
+	require.Len(t, seen, 10)
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomUser(t *testing.T) User {
+	hashedPassword, err := util.HashPassword(util.RandomString(6))
+	require.NoError(t, err)
+
+	arg := CreateUserParams{
+		Username:       util.RandomOwner(),
+		HashedPassword: hashedPassword,
+		FullName:       util.RandomOwner(),
+		Email:          util.RandomEmail(),
+	}
+
+	user, err := testQueries.CreateUser(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, user)
+
+	require.Equal(t, arg.Username, user.Username)
+	require.Equal(t, arg.HashedPassword, user.HashedPassword)
+	require.Equal(t, arg.FullName, user.FullName)
+	require.Equal(t, arg.Email, user.Email)
+
+	require.Equal(t, util.DepositorRole, user.Role)
+	require.True(t, user.PasswordChangedAt.IsZero())
+	require.NotZero(t, user.CreatedAt)
+
+	return user
+}
+
+func TestQueries_CreateUser(t *testing.T) {
+	createRandomUser(t)
+}
+
+func TestQueries_GetUser(t *testing.T) {
+	userExpected := createRandomUser(t)
+	userActual, err := testQueries.GetUser(context.Background(), userExpected.Username)
+
+	require.NoError(t, err)
+	require.NotEmpty(t, userActual)
+
+	require.Equal(t, userExpected.Username, userActual.Username)
+	require.Equal(t, userExpected.HashedPassword, userActual.HashedPassword)
+	require.Equal(t, userExpected.FullName, userActual.FullName)
+	require.Equal(t, userExpected.Email, userActual.Email)
+	require.WithinDuration(t, userExpected.PasswordChangedAt, userActual.PasswordChangedAt, time.Second)
+	require.WithinDuration(t, userExpected.CreatedAt, userActual.CreatedAt, time.Second)
+}
+
+func TestQueries_GetUserNotFound(t *testing.T) {
+	userActual, err := testQueries.GetUser(context.Background(), util.RandomOwner())
+	require.Error(t, err)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+	require.Empty(t, userActual)
+}
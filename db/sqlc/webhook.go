@@ -0,0 +1,222 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Webhook delivery statuses. A delivery is pending until it succeeds, or fails for good after its last attempt.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// EventTypes are the types of the events that can be subscribed to.
+var EventTypes = []string{EventTransferCompleted, EventAccountCreated, EventBalanceChanged}
+
+// WebhookAttempt is the outcome of an attempt to deliver a webhook.
+type WebhookAttempt struct {
+	// Status is the status of the delivery after the attempt, pending if it is to be attempted again.
+	Status string
+	// NextAttemptAt is when a pending delivery is attempted again.
+	NextAttemptAt time.Time
+	// ResponseStatus is the HTTP status of the response, zero if there was none.
+	ResponseStatus int32
+	// Error tells why the attempt failed, empty if it succeeded.
+	Error string
+}
+
+// EnqueueWebhookDeliveries creates a pending delivery of the event for every webhook subscription to it,
+// within a single db tx. The subscriptions are those of the owners of the accounts the event is about.
+// An event already enqueued is not enqueued again, so it can be published more than once.
+// It returns the number of deliveries created.
+func (store *SQLStore) EnqueueWebhookDeliveries(ctx context.Context, event OutboxEvent) (int, error) {
+	accountIDs, err := eventAccountIDs(event)
+	if err != nil {
+		return 0, err
+	}
+
+	var enqueued int
+	_, err = store.execTx(ctx, nil, func(queries *Queries) error {
+		enqueued = 0
+
+		subscriptions, err := queries.ListWebhookSubscriptionsForEvent(ctx, ListWebhookSubscriptionsForEventParams{
+			EventType:  event.EventType,
+			AccountIds: accountIDs,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, subscription := range subscriptions {
+			_, err = queries.CreateWebhookDelivery(ctx, CreateWebhookDeliveryParams{
+				SubscriptionID: subscription.ID,
+				EventID:        event.ID,
+				EventType:      event.EventType,
+				Payload:        event.Payload,
+			})
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					// enqueued already
+					continue
+				}
+				return err
+			}
+			enqueued++
+		}
+
+		return nil
+	})
+
+	return enqueued, err
+}
+
+// defaultWebhookDeliveryLease is used by DeliverWebhooks unless DeliverWebhooksParams.Lease is given.
+const defaultWebhookDeliveryLease = time.Minute
+
+type DeliverWebhooksParams struct {
+	Now time.Time `json:"now"`
+	// Lease is how long a worker has to attempt a delivery and record the attempt, from the time it claims it.
+	// A delivery not recorded by then is attempted again. It must be longer than an attempt takes,
+	// and defaults to a minute.
+	Lease time.Duration `json:"lease"`
+}
+
+// DeliverWebhooks attempts the webhook deliveries due by now and records the outcome deliverFn returns
+// for each of them. It returns the number of attempts recorded.
+// Each delivery is claimed with a lease in a db tx of its own, attempted outside of any db tx, then recorded
+// in another db tx, so a slow receiver holds neither a lock nor a connection of the pool.
+// Deliveries leased by another worker are skipped, so several workers can deliver side by side.
+func (store *SQLStore) DeliverWebhooks(ctx context.Context, arg DeliverWebhooksParams,
+	deliverFn func(ctx context.Context, delivery WebhookDelivery, subscription WebhookSubscription) WebhookAttempt,
+) (int, error) {
+	if arg.Lease <= 0 {
+		arg.Lease = defaultWebhookDeliveryLease
+	}
+
+	attempts := 0
+	for {
+		result, err := store.deliverWebhook(ctx, arg, deliverFn)
+		if err != nil {
+			return attempts, err
+		}
+		if !result.claimed {
+			return attempts, nil
+		}
+		if result.recorded {
+			attempts++
+		}
+	}
+}
+
+// webhookDeliveryResult is the outcome of deliverWebhook.
+type webhookDeliveryResult struct {
+	// claimed is false if no delivery was due.
+	claimed bool
+	// recorded is false if the delivery was replayed, or its lease ran out and another worker took it over,
+	// while it was being attempted.
+	recorded bool
+}
+
+// deliverWebhook claims a due delivery and attempts it.
+func (store *SQLStore) deliverWebhook(ctx context.Context, arg DeliverWebhooksParams,
+	deliverFn func(ctx context.Context, delivery WebhookDelivery, subscription WebhookSubscription) WebhookAttempt,
+) (webhookDeliveryResult, error) {
+	var result webhookDeliveryResult
+	var delivery, leased WebhookDelivery
+	var subscription WebhookSubscription
+
+	_, err := store.execTx(ctx, nil, func(queries *Queries) error {
+		var err error
+		result = webhookDeliveryResult{}
+
+		// the row stays locked until it is leased, other workers skip it meanwhile
+		delivery, err = queries.ClaimDueWebhookDelivery(ctx, arg.Now)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		subscription, err = queries.GetWebhookSubscription(ctx, delivery.SubscriptionID)
+		if err != nil {
+			return err
+		}
+
+		// the lease runs from the time of the claim, so that it does not run out early on a long run
+		leasedFrom := time.Now()
+		if leasedFrom.Before(arg.Now) {
+			leasedFrom = arg.Now
+		}
+		leased, err = queries.LeaseWebhookDelivery(ctx, LeaseWebhookDeliveryParams{
+			NextAttemptAt: leasedFrom.Add(arg.Lease),
+			ID:            delivery.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.claimed = true
+		return nil
+	})
+	if err != nil || !result.claimed {
+		return result, err
+	}
+
+	attempt := deliverFn(ctx, delivery, subscription)
+	attemptedAt := time.Now()
+
+	_, err = store.execTx(ctx, nil, func(queries *Queries) error {
+		result.recorded = false
+
+		current, err := queries.GetWebhookDeliveryForUpdate(ctx, delivery.ID)
+		if err != nil {
+			return err
+		}
+		if current.Status != WebhookDeliveryPending || current.Attempts != leased.Attempts ||
+			!current.NextAttemptAt.Equal(leased.NextAttemptAt) {
+			return nil
+		}
+
+		_, err = queries.UpdateWebhookDelivery(ctx, UpdateWebhookDeliveryParams{
+			Status:         attempt.Status,
+			Attempts:       delivery.Attempts + 1,
+			NextAttemptAt:  attempt.NextAttemptAt,
+			LastAttemptAt:  &attemptedAt,
+			ResponseStatus: attempt.ResponseStatus,
+			LastError:      attempt.Error,
+			ID:             delivery.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.recorded = true
+		return nil
+	})
+
+	return result, err
+}
+
+// eventAccountIDs are the IDs of the accounts the event is about, whose owners get it delivered.
+// Events of other types are delivered to no one.
+func eventAccountIDs(event OutboxEvent) ([]int64, error) {
+	switch event.EventType {
+	case EventTransferCompleted:
+		var payload TransferCompletedEvent
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("cannot decode %s event ID %d: %w", event.EventType, event.ID, err)
+		}
+		return []int64{payload.FromAccountID, payload.ToAccountID}, nil
+	case EventAccountCreated, EventBalanceChanged:
+		return []int64{event.AggregateID}, nil
+	default:
+		return nil, nil
+	}
+}
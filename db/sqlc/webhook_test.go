@@ -0,0 +1,223 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomWebhookSubscription(t *testing.T, owner string, eventTypes ...string) WebhookSubscription {
+	subscription, err := testQueries.CreateWebhookSubscription(context.Background(), CreateWebhookSubscriptionParams{
+		Owner:      owner,
+		Url:        "https://" + util.RandomString(8) + ".example.com/hooks",
+		Secret:     "whsec_" + util.RandomString(32),
+		EventTypes: eventTypes,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, subscription.ID)
+	require.Equal(t, eventTypes, subscription.EventTypes)
+
+	return subscription
+}
+
+// deliverAll attempts every delivery due, each with the same outcome, and returns the deliveries attempted.
+func deliverAll(t *testing.T, store Store, attempt WebhookAttempt) []WebhookDelivery {
+	var deliveries []WebhookDelivery
+	attempts, err := store.DeliverWebhooks(context.Background(), DeliverWebhooksParams{Now: time.Now(), Lease: time.Minute},
+		func(ctx context.Context, delivery WebhookDelivery, subscription WebhookSubscription) WebhookAttempt {
+			require.Equal(t, subscription.ID, delivery.SubscriptionID)
+			deliveries = append(deliveries, delivery)
+			return attempt
+		})
+	require.NoError(t, err)
+	require.Equal(t, len(deliveries), attempts)
+
+	return deliveries
+}
+
+func TestEnqueueWebhookDeliveries(t *testing.T) {
+	store := NewStore(testDB)
+	deliverAll(t, store, WebhookAttempt{Status: WebhookDeliverySucceeded})
+
+	account1 := createRandomAccountWithCurrency(t, 100, util.EUR)
+	account2 := createRandomAccountWithCurrency(t, 0, util.EUR)
+	subscription1 := createRandomWebhookSubscription(t, account1.Owner, EventTransferCompleted)
+	subscription2 := createRandomWebhookSubscription(t, account2.Owner, EventTransferCompleted, EventBalanceChanged)
+	// subscribed to other events only
+	createRandomWebhookSubscription(t, account2.Owner, EventAccountCreated)
+
+	payload, err := json.Marshal(TransferCompletedEvent{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 10})
+	require.NoError(t, err)
+	event := OutboxEvent{
+		ID:            util.RandomInt(1_000_000, 2_000_000),
+		EventType:     EventTransferCompleted,
+		AggregateType: AggregateTransfer,
+		AggregateID:   1,
+		Payload:       payload,
+	}
+
+	// the owners of both accounts get the event
+	enqueued, err := store.EnqueueWebhookDeliveries(context.Background(), event)
+	require.NoError(t, err)
+	require.Equal(t, 2, enqueued)
+
+	// an event published again is not enqueued again
+	enqueued, err = store.EnqueueWebhookDeliveries(context.Background(), event)
+	require.NoError(t, err)
+	require.Zero(t, enqueued)
+
+	for _, subscription := range []WebhookSubscription{subscription1, subscription2} {
+		deliveries, err := store.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{
+			SubscriptionID: subscription.ID,
+			Limit:          10,
+		})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, event.ID, deliveries[0].EventID)
+		require.Equal(t, WebhookDeliveryPending, deliveries[0].Status)
+		require.JSONEq(t, string(payload), string(deliveries[0].Payload))
+	}
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	store := NewStore(testDB)
+	deliverAll(t, store, WebhookAttempt{Status: WebhookDeliverySucceeded})
+
+	user := createRandomUser(t)
+	subscription := createRandomWebhookSubscription(t, user.Username, EventAccountCreated)
+	account, err := store.CreateAccountTx(context.Background(), CreateAccountParams{Owner: user.Username, Currency: util.EUR})
+	require.NoError(t, err)
+
+	events := findEvents(relayAll(t, store), EventAccountCreated, account.ID)
+	require.Len(t, events, 1)
+	_, err = store.EnqueueWebhookDeliveries(context.Background(), events[0])
+	require.NoError(t, err)
+
+	// a failed attempt is retried later, not within the same run
+	retryAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	deliveries := deliverAll(t, store, WebhookAttempt{
+		Status:         WebhookDeliveryPending,
+		NextAttemptAt:  retryAt,
+		ResponseStatus: 500,
+		Error:          "receiver is down",
+	})
+	require.Len(t, deliveries, 1)
+	require.Equal(t, subscription.ID, deliveries[0].SubscriptionID)
+
+	delivery, err := store.GetWebhookDelivery(context.Background(), deliveries[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, WebhookDeliveryPending, delivery.Status)
+	require.Equal(t, int32(1), delivery.Attempts)
+	require.WithinDuration(t, retryAt, delivery.NextAttemptAt, time.Second)
+	require.NotNil(t, delivery.LastAttemptAt)
+	require.Equal(t, int32(500), delivery.ResponseStatus)
+	require.Equal(t, "receiver is down", delivery.LastError)
+
+	// a replayed delivery is due right away
+	_, err = store.ReplayWebhookDelivery(context.Background(), delivery.ID)
+	require.NoError(t, err)
+
+	deliveries = deliverAll(t, store, WebhookAttempt{Status: WebhookDeliverySucceeded, ResponseStatus: 200})
+	require.Len(t, deliveries, 1)
+	require.Zero(t, deliveries[0].Attempts)
+
+	delivery, err = store.GetWebhookDelivery(context.Background(), delivery.ID)
+	require.NoError(t, err)
+	require.Equal(t, WebhookDeliverySucceeded, delivery.Status)
+	require.Equal(t, int32(1), delivery.Attempts)
+}
+
+func TestDeliverWebhooksLease(t *testing.T) {
+	store := NewStore(testDB)
+	deliverAll(t, store, WebhookAttempt{Status: WebhookDeliverySucceeded})
+
+	user := createRandomUser(t)
+	subscription := createRandomWebhookSubscription(t, user.Username, EventAccountCreated)
+	account, err := store.CreateAccountTx(context.Background(), CreateAccountParams{Owner: user.Username, Currency: util.EUR})
+	require.NoError(t, err)
+
+	events := findEvents(relayAll(t, store), EventAccountCreated, account.ID)
+	require.Len(t, events, 1)
+	_, err = store.EnqueueWebhookDeliveries(context.Background(), events[0])
+	require.NoError(t, err)
+
+	now := time.Now()
+	attempts, err := store.DeliverWebhooks(context.Background(), DeliverWebhooksParams{Now: now, Lease: time.Minute},
+		func(ctx context.Context, delivery WebhookDelivery, subscription WebhookSubscription) WebhookAttempt {
+			// the delivery being attempted is leased, another worker skips it
+			leased, err := store.GetWebhookDelivery(ctx, delivery.ID)
+			require.NoError(t, err)
+			require.True(t, leased.NextAttemptAt.After(now))
+			require.Empty(t, deliverAll(t, store, WebhookAttempt{Status: WebhookDeliverySucceeded}))
+
+			// and it is replayed meanwhile, which the attempt does not overwrite
+			_, err = store.ReplayWebhookDelivery(ctx, delivery.ID)
+			require.NoError(t, err)
+
+			return WebhookAttempt{Status: WebhookDeliveryFailed, Error: "receiver is down"}
+		})
+	require.NoError(t, err)
+	require.Zero(t, attempts)
+
+	deliveries, err := store.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{
+		SubscriptionID: subscription.ID,
+		Limit:          10,
+	})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, WebhookDeliveryPending, deliveries[0].Status)
+	require.Zero(t, deliveries[0].Attempts)
+}
+
+func TestEventAccountIDs(t *testing.T) {
+	payload, err := json.Marshal(TransferCompletedEvent{FromAccountID: 1, ToAccountID: 2})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		event      OutboxEvent
+		accountIDs []int64
+		err        bool
+	}{
+		{
+			name:       "TransferCompleted",
+			event:      OutboxEvent{EventType: EventTransferCompleted, AggregateID: 9, Payload: payload},
+			accountIDs: []int64{1, 2},
+		},
+		{
+			name:       "AccountCreated",
+			event:      OutboxEvent{EventType: EventAccountCreated, AggregateID: 3},
+			accountIDs: []int64{3},
+		},
+		{
+			name:       "BalanceChanged",
+			event:      OutboxEvent{EventType: EventBalanceChanged, AggregateID: 4},
+			accountIDs: []int64{4},
+		},
+		{
+			name:  "UnknownEvent",
+			event: OutboxEvent{EventType: "AccountDeleted", AggregateID: 5},
+		},
+		{
+			name:  "InvalidPayload",
+			event: OutboxEvent{EventType: EventTransferCompleted, Payload: json.RawMessage(`[]`)},
+			err:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			accountIDs, err := eventAccountIDs(tc.event)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.accountIDs, accountIDs)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/util"
+)
+
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+type camt053Format struct{}
+
+func (camt053Format) Name() string {
+	return "camt053"
+}
+
+func (camt053Format) ContentType() string {
+	return "application/xml"
+}
+
+func (camt053Format) NewWriter(w io.Writer) Writer {
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return &camt053Writer{w: w, encoder: encoder}
+}
+
+// camt053Writer writes an ISO 20022 bank to customer statement, camt.053.001.02.
+// Amounts are always positive decimals in the currency of the account, the direction is in CdtDbtInd.
+type camt053Writer struct {
+	w        io.Writer
+	encoder  *xml.Encoder
+	currency string
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtGroupHeader struct {
+	XMLName xml.Name `xml:"GrpHdr"`
+	MsgID   string   `xml:"MsgId"`
+	CreDtTm string   `xml:"CreDtTm"`
+}
+
+type camtPeriod struct {
+	XMLName xml.Name `xml:"FrToDt"`
+	FrDtTm  string   `xml:"FrDtTm"`
+	ToDtTm  string   `xml:"ToDtTm"`
+}
+
+type camtAccount struct {
+	XMLName xml.Name `xml:"Acct"`
+	ID      string   `xml:"Id>Othr>Id"`
+	Ccy     string   `xml:"Ccy"`
+	Ownr    string   `xml:"Ownr>Nm"`
+}
+
+type camtBalance struct {
+	XMLName   xml.Name   `xml:"Bal"`
+	Type      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amt       camtAmount `xml:"Amt"`
+	CdtDbtInd string     `xml:"CdtDbtInd"`
+	DtTm      string     `xml:"Dt>DtTm"`
+}
+
+type camtEntry struct {
+	XMLName   xml.Name   `xml:"Ntry"`
+	NtryRef   string     `xml:"NtryRef"`
+	Amt       camtAmount `xml:"Amt"`
+	CdtDbtInd string     `xml:"CdtDbtInd"`
+	Sts       string     `xml:"Sts"`
+	BookgDtTm string     `xml:"BookgDt>DtTm"`
+	BkTxCd    string     `xml:"BkTxCd>Prtry>Cd"`
+}
+
+func (writer *camt053Writer) Begin(summary db.StatementSummary) error {
+	writer.currency = summary.Account.Currency
+	createdAt := now()
+	statementID := fmt.Sprintf("%d-%s", summary.Account.ID, summary.From.UTC().Format("20060102150405"))
+
+	_, err := io.WriteString(writer.w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	err = startElement(writer.encoder, "Document", xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: camt053Namespace})
+	if err != nil {
+		return err
+	}
+	err = startElement(writer.encoder, "BkToCstmrStmt")
+	if err != nil {
+		return err
+	}
+	err = writer.encoder.Encode(camtGroupHeader{
+		MsgID:   fmt.Sprintf("%s-%s", statementID, createdAt.UTC().Format("20060102150405")),
+		CreDtTm: camtTime(createdAt),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = startElement(writer.encoder, "Stmt")
+	if err != nil {
+		return err
+	}
+	err = encodeElement(writer.encoder, statementID, "Id")
+	if err != nil {
+		return err
+	}
+	err = encodeElement(writer.encoder, camtTime(createdAt), "CreDtTm")
+	if err != nil {
+		return err
+	}
+	err = writer.encoder.Encode(camtPeriod{FrDtTm: camtTime(summary.From), ToDtTm: camtTime(summary.To)})
+	if err != nil {
+		return err
+	}
+	err = writer.encoder.Encode(camtAccount{
+		ID:   strconv.FormatInt(summary.Account.ID, 10),
+		Ccy:  summary.Account.Currency,
+		Ownr: summary.Account.Owner,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = writer.encoder.Encode(writer.balance("OPBD", summary.OpeningBalance, summary.From))
+	if err != nil {
+		return err
+	}
+	return writer.encoder.Encode(writer.balance("CLBD", summary.ClosingBalance, summary.To))
+}
+
+func (writer *camt053Writer) Line(line db.StatementLine) error {
+	value, indicator := camtValue(line.Amount, writer.currency)
+	return writer.encoder.Encode(camtEntry{
+		NtryRef:   strconv.FormatInt(line.ID, 10),
+		Amt:       camtAmount{Currency: writer.currency, Value: value},
+		CdtDbtInd: indicator,
+		Sts:       "BOOK",
+		BookgDtTm: camtTime(line.CreatedAt),
+		BkTxCd:    "TRANSFER",
+	})
+}
+
+func (writer *camt053Writer) End() error {
+	for _, name := range []string{"Stmt", "BkToCstmrStmt", "Document"} {
+		err := endElement(writer.encoder, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.encoder.Flush()
+}
+
+func (writer *camt053Writer) balance(balanceType string, amount int64, at time.Time) camtBalance {
+	value, indicator := camtValue(amount, writer.currency)
+	return camtBalance{
+		Type:      balanceType,
+		Amt:       camtAmount{Currency: writer.currency, Value: value},
+		CdtDbtInd: indicator,
+		DtTm:      camtTime(at),
+	}
+}
+
+// camtValue splits a signed amount in minor units into its absolute value, as a decimal in the currency,
+// and the credit or debit indicator.
+func camtValue(amount int64, currency string) (string, string) {
+	indicator := "CRDT"
+	if amount < 0 {
+		amount, indicator = -amount, "DBIT"
+	}
+	return camtDecimal(amount, util.CurrencyExponent(currency)), indicator
+}
+
+// camtDecimal formats a positive amount in minor units as a decimal with exponent digits after the point,
+// e.g. 1050 with an exponent of 2 is "10.50".
+func camtDecimal(amount int64, exponent int) string {
+	digits := strconv.FormatInt(amount, 10)
+	if exponent <= 0 {
+		return digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+func camtTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
@@ -0,0 +1,53 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+)
+
+type csvFormat struct{}
+
+func (csvFormat) Name() string {
+	return "csv"
+}
+
+func (csvFormat) ContentType() string {
+	return "text/csv"
+}
+
+func (csvFormat) NewWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+// csvWriter writes a row per entry below a header row. Amounts are signed, debits being negative.
+type csvWriter struct {
+	w        *csv.Writer
+	currency string
+}
+
+var csvHeader = []string{"entry_id", "account_id", "created_at", "amount", "currency", "balance"}
+
+func (writer *csvWriter) Begin(summary db.StatementSummary) error {
+	writer.currency = summary.Account.Currency
+	return writer.w.Write(csvHeader)
+}
+
+func (writer *csvWriter) Line(line db.StatementLine) error {
+	return writer.w.Write([]string{
+		strconv.FormatInt(line.ID, 10),
+		strconv.FormatInt(line.AccountID, 10),
+		line.CreatedAt.UTC().Format(time.RFC3339),
+		strconv.FormatInt(line.Amount, 10),
+		writer.currency,
+		strconv.FormatInt(line.Balance, 10),
+	})
+}
+
+func (writer *csvWriter) End() error {
+	writer.w.Flush()
+	return writer.w.Error()
+}
@@ -0,0 +1,88 @@
+// Package export renders account statements in formats accounting tools can import.
+package export
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+)
+
+// ErrUnknownFormat is returned when no format has the given name or content type.
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Writer writes one statement as it is read from the db.
+// Begin is called once with the summary, then Line for every entry in order and End at last.
+// Nothing but the summary has to be kept in memory.
+type Writer interface {
+	Begin(summary db.StatementSummary) error
+	Line(line db.StatementLine) error
+	End() error
+}
+
+// Format is a statement file format.
+type Format interface {
+	// Name is the value of the format query param, also used as the file extension.
+	Name() string
+	ContentType() string
+	NewWriter(w io.Writer) Writer
+}
+
+// formats in order of preference when a client accepts any of them.
+var formats = []Format{
+	csvFormat{},
+	ofxFormat{},
+	camt053Format{},
+}
+
+// now is the creation time written into the statement files.
+var now = time.Now
+
+// Lookup returns the format with the given name, case-insensitive.
+func Lookup(name string) (Format, error) {
+	for _, format := range formats {
+		if strings.EqualFold(format.Name(), name) {
+			return format, nil
+		}
+	}
+
+	return nil, ErrUnknownFormat
+}
+
+// ForContentType returns the format with the given content type.
+func ForContentType(contentType string) (Format, error) {
+	for _, format := range formats {
+		if format.ContentType() == contentType {
+			return format, nil
+		}
+	}
+
+	return nil, ErrUnknownFormat
+}
+
+// ContentTypes returns the content types of all formats, to negotiate with the Accept header.
+func ContentTypes() []string {
+	contentTypes := make([]string, 0, len(formats))
+	for _, format := range formats {
+		contentTypes = append(contentTypes, format.ContentType())
+	}
+
+	return contentTypes
+}
+
+// startElement, endElement and encodeElement write the XML formats piece by piece,
+// so that the entries can be encoded in between as they are read.
+func startElement(encoder *xml.Encoder, name string, attrs ...xml.Attr) error {
+	return encoder.EncodeToken(xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs})
+}
+
+func endElement(encoder *xml.Encoder, name string) error {
+	return encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+func encodeElement(encoder *xml.Encoder, value interface{}, name string) error {
+	return encoder.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: name}})
+}
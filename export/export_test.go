@@ -0,0 +1,166 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"csv", "OFX", "camt053"} {
+		format, err := Lookup(name)
+		require.NoError(t, err)
+		require.Equal(t, strings.ToLower(name), format.Name())
+
+		byContentType, err := ForContentType(format.ContentType())
+		require.NoError(t, err)
+		require.Equal(t, format, byContentType)
+	}
+
+	_, err := Lookup("pdf")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+	_, err = ForContentType("application/pdf")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+
+	require.Equal(t, []string{"text/csv", "application/x-ofx", "application/xml"}, ContentTypes())
+}
+
+func TestCSV(t *testing.T) {
+	summary, lines := randomStatement()
+	data := writeStatement(t, "csv", summary, lines)
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		csvHeader,
+		{"1", "7", "2023-01-01T10:00:00Z", "100", util.EUR, "150"},
+		{"2", "7", "2023-01-02T10:00:00Z", "-200", util.EUR, "-50"},
+	}, records)
+}
+
+func TestOFX(t *testing.T) {
+	summary, lines := randomStatement()
+	data := writeStatement(t, "ofx", summary, lines)
+
+	var ofx struct {
+		Currency     string           `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>CURDEF"`
+		Account      ofxBankAccount   `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>BANKACCTFROM"`
+		Start        string           `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>BANKTRANLIST>DTSTART"`
+		End          string           `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>BANKTRANLIST>DTEND"`
+		Transactions []ofxTransaction `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>BANKTRANLIST>STMTTRN"`
+		Balance      ofxLedgerBalance `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>LEDGERBAL"`
+	}
+	err := xml.Unmarshal(data, &ofx)
+	require.NoError(t, err)
+
+	require.Equal(t, util.EUR, ofx.Currency)
+	require.Equal(t, "7", ofx.Account.AcctID)
+	require.Equal(t, "20230101000000.000[0:GMT]", ofx.Start)
+	require.Equal(t, "20230201000000.000[0:GMT]", ofx.End)
+	require.Len(t, ofx.Transactions, 2)
+	require.Equal(t, "CREDIT", ofx.Transactions[0].TrnType)
+	require.Equal(t, "100", ofx.Transactions[0].TrnAmt)
+	require.Equal(t, "DEBIT", ofx.Transactions[1].TrnType)
+	require.Equal(t, "-200", ofx.Transactions[1].TrnAmt)
+	require.Equal(t, "2", ofx.Transactions[1].FITID)
+	require.Equal(t, "-50", ofx.Balance.BalAmt)
+}
+
+func TestCamt053(t *testing.T) {
+	summary, lines := randomStatement()
+	data := writeStatement(t, "camt053", summary, lines)
+
+	var document struct {
+		XMLName  xml.Name      `xml:"urn:iso:std:iso:20022:tech:xsd:camt.053.001.02 Document"`
+		ID       string        `xml:"BkToCstmrStmt>Stmt>Id"`
+		Account  camtAccount   `xml:"BkToCstmrStmt>Stmt>Acct"`
+		Balances []camtBalance `xml:"BkToCstmrStmt>Stmt>Bal"`
+		Entries  []camtEntry   `xml:"BkToCstmrStmt>Stmt>Ntry"`
+	}
+	err := xml.Unmarshal(data, &document)
+	require.NoError(t, err)
+
+	require.Equal(t, "7-20230101000000", document.ID)
+	require.Equal(t, "7", document.Account.ID)
+	require.Equal(t, "owner", document.Account.Ownr)
+
+	require.Len(t, document.Balances, 2)
+	require.Equal(t, "OPBD", document.Balances[0].Type)
+	require.Equal(t, camtAmount{Currency: util.EUR, Value: "0.50"}, document.Balances[0].Amt)
+	require.Equal(t, "CRDT", document.Balances[0].CdtDbtInd)
+	require.Equal(t, "CLBD", document.Balances[1].Type)
+	require.Equal(t, camtAmount{Currency: util.EUR, Value: "0.50"}, document.Balances[1].Amt)
+	require.Equal(t, "DBIT", document.Balances[1].CdtDbtInd)
+
+	require.Len(t, document.Entries, 2)
+	require.Equal(t, "1", document.Entries[0].NtryRef)
+	require.Equal(t, "CRDT", document.Entries[0].CdtDbtInd)
+	require.Equal(t, camtAmount{Currency: util.EUR, Value: "2.00"}, document.Entries[1].Amt)
+	require.Equal(t, "DBIT", document.Entries[1].CdtDbtInd)
+	require.Equal(t, "2023-01-02T10:00:00Z", document.Entries[1].BookgDtTm)
+
+	// the amounts are decimals in the currency, not minor units
+	require.Contains(t, string(data), `<Amt Ccy="EUR">1.00</Amt>`)
+}
+
+func TestCamtDecimal(t *testing.T) {
+	testCases := []struct {
+		amount   int64
+		exponent int
+		expected string
+	}{
+		{1050, 2, "10.50"},
+		{100, 2, "1.00"},
+		{5, 2, "0.05"},
+		{0, 2, "0.00"},
+		{123456789, 2, "1234567.89"},
+		{1050, 3, "1.050"},
+		{1050, 0, "1050"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, camtDecimal(tc.amount, tc.exponent))
+	}
+}
+
+func randomStatement() (db.StatementSummary, []db.StatementLine) {
+	from := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	summary := db.StatementSummary{
+		Account:        db.Account{ID: 7, Owner: "owner", Balance: -50, Currency: util.EUR},
+		From:           from,
+		To:             from.AddDate(0, 1, 0),
+		OpeningBalance: 50,
+		ClosingBalance: -50,
+	}
+	lines := []db.StatementLine{
+		{Entry: db.Entry{ID: 1, AccountID: 7, Amount: 100, CreatedAt: from.Add(10 * time.Hour)}, Balance: 150},
+		{Entry: db.Entry{ID: 2, AccountID: 7, Amount: -200, CreatedAt: from.Add(34 * time.Hour)}, Balance: -50},
+	}
+
+	return summary, lines
+}
+
+func writeStatement(t *testing.T, name string, summary db.StatementSummary, lines []db.StatementLine) []byte {
+	now = func() time.Time { return time.Date(2023, time.February, 1, 8, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() { now = time.Now })
+
+	format, err := Lookup(name)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	writer := format.NewWriter(&buf)
+	require.NoError(t, writer.Begin(summary))
+	for _, line := range lines {
+		require.NoError(t, writer.Line(line))
+	}
+	require.NoError(t, writer.End())
+
+	return buf.Bytes()
+}
@@ -0,0 +1,178 @@
+package export
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+)
+
+// ofxBankID identifies the bank in BANKACCTFROM, as there is no routing number.
+const ofxBankID = "SIMPLEBANK"
+
+const ofxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+`
+
+type ofxFormat struct{}
+
+func (ofxFormat) Name() string {
+	return "ofx"
+}
+
+func (ofxFormat) ContentType() string {
+	return "application/x-ofx"
+}
+
+func (ofxFormat) NewWriter(w io.Writer) Writer {
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return &ofxWriter{w: w, encoder: encoder}
+}
+
+// ofxWriter writes an OFX 2.2 bank statement response.
+type ofxWriter struct {
+	w       io.Writer
+	encoder *xml.Encoder
+	summary db.StatementSummary
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+var ofxStatusOK = ofxStatus{Code: 0, Severity: "INFO"}
+
+type ofxSignOn struct {
+	XMLName  xml.Name  `xml:"SIGNONMSGSRSV1"`
+	Status   ofxStatus `xml:"SONRS>STATUS"`
+	DTServer string    `xml:"SONRS>DTSERVER"`
+	Language string    `xml:"SONRS>LANGUAGE"`
+}
+
+type ofxBankAccount struct {
+	XMLName  xml.Name `xml:"BANKACCTFROM"`
+	BankID   string   `xml:"BANKID"`
+	AcctID   string   `xml:"ACCTID"`
+	AcctType string   `xml:"ACCTTYPE"`
+}
+
+type ofxTransaction struct {
+	XMLName  xml.Name `xml:"STMTTRN"`
+	TrnType  string   `xml:"TRNTYPE"`
+	DTPosted string   `xml:"DTPOSTED"`
+	TrnAmt   string   `xml:"TRNAMT"`
+	FITID    string   `xml:"FITID"`
+}
+
+type ofxLedgerBalance struct {
+	XMLName xml.Name `xml:"LEDGERBAL"`
+	BalAmt  string   `xml:"BALAMT"`
+	DTAsOf  string   `xml:"DTASOF"`
+}
+
+func (writer *ofxWriter) Begin(summary db.StatementSummary) error {
+	writer.summary = summary
+
+	_, err := io.WriteString(writer.w, ofxHeader)
+	if err != nil {
+		return err
+	}
+
+	err = startElement(writer.encoder, "OFX")
+	if err != nil {
+		return err
+	}
+	err = writer.encoder.Encode(ofxSignOn{Status: ofxStatusOK, DTServer: ofxTime(now()), Language: "ENG"})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{"BANKMSGSRSV1", "STMTTRNRS"} {
+		err = startElement(writer.encoder, name)
+		if err != nil {
+			return err
+		}
+	}
+	err = encodeElement(writer.encoder, "0", "TRNUID")
+	if err != nil {
+		return err
+	}
+	err = encodeElement(writer.encoder, ofxStatusOK, "STATUS")
+	if err != nil {
+		return err
+	}
+
+	err = startElement(writer.encoder, "STMTRS")
+	if err != nil {
+		return err
+	}
+	err = encodeElement(writer.encoder, summary.Account.Currency, "CURDEF")
+	if err != nil {
+		return err
+	}
+	err = writer.encoder.Encode(ofxBankAccount{
+		BankID:   ofxBankID,
+		AcctID:   strconv.FormatInt(summary.Account.ID, 10),
+		AcctType: "CHECKING",
+	})
+	if err != nil {
+		return err
+	}
+
+	err = startElement(writer.encoder, "BANKTRANLIST")
+	if err != nil {
+		return err
+	}
+	err = encodeElement(writer.encoder, ofxTime(summary.From), "DTSTART")
+	if err != nil {
+		return err
+	}
+	return encodeElement(writer.encoder, ofxTime(summary.To), "DTEND")
+}
+
+func (writer *ofxWriter) Line(line db.StatementLine) error {
+	trnType := "CREDIT"
+	if line.Amount < 0 {
+		trnType = "DEBIT"
+	}
+
+	return writer.encoder.Encode(ofxTransaction{
+		TrnType:  trnType,
+		DTPosted: ofxTime(line.CreatedAt),
+		TrnAmt:   strconv.FormatInt(line.Amount, 10),
+		FITID:    strconv.FormatInt(line.ID, 10),
+	})
+}
+
+func (writer *ofxWriter) End() error {
+	err := endElement(writer.encoder, "BANKTRANLIST")
+	if err != nil {
+		return err
+	}
+
+	err = writer.encoder.Encode(ofxLedgerBalance{
+		BalAmt: strconv.FormatInt(writer.summary.ClosingBalance, 10),
+		DTAsOf: ofxTime(writer.summary.To),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{"STMTRS", "STMTTRNRS", "BANKMSGSRSV1", "OFX"} {
+		err = endElement(writer.encoder, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.encoder.Flush()
+}
+
+// ofxTime formats t as an OFX datetime in UTC.
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
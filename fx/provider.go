@@ -0,0 +1,41 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrRateNotFound is returned when a provider has no rate for a currency pair.
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// Rate is the price of one unit of From in To, as a decimal string such as "0.9215".
+type Rate struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value string `json:"value"`
+}
+
+// ExchangeRateProvider is an interface for looking up exchange rates between currencies
+type ExchangeRateProvider interface {
+	// Rate returns the rate to convert an amount in the from currency to the to currency
+	Rate(ctx context.Context, from string, to string) (Rate, error)
+}
+
+// Convert converts an amount in the rate's From currency to its To currency.
+// The result is rounded down, so the bank never credits more than the rate covers.
+func Convert(amount int64, rate Rate) (int64, error) {
+	value, ok := new(big.Rat).SetString(rate.Value)
+	if !ok || value.Sign() <= 0 {
+		return 0, fmt.Errorf("invalid exchange rate %q for %s/%s", rate.Value, rate.From, rate.To)
+	}
+
+	converted := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), value)
+	result := new(big.Int).Quo(converted.Num(), converted.Denom())
+	if !result.IsInt64() {
+		return 0, fmt.Errorf("converted amount of %d %s overflows", amount, rate.From)
+	}
+
+	return result.Int64(), nil
+}
@@ -0,0 +1,79 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// rateScale is the number of decimals kept for inverted rates.
+const rateScale = 10
+
+// StaticProvider serves exchange rates from a fixed table.
+// Rates are looked up directly, then inverted, and converting a currency into itself is always "1".
+type StaticProvider struct {
+	rates map[string]string
+}
+
+// NewStaticProvider creates a StaticProvider from rates keyed by currency pair, e.g. "USD/EUR": "0.92".
+func NewStaticProvider(rates map[string]string) (*StaticProvider, error) {
+	provider := &StaticProvider{rates: make(map[string]string, len(rates))}
+
+	for pair, value := range rates {
+		currencies := strings.Split(pair, "/")
+		if len(currencies) != 2 || currencies[0] == "" || currencies[1] == "" {
+			return nil, fmt.Errorf("invalid currency pair %q: must look like USD/EUR", pair)
+		}
+
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q for %s", value, pair)
+		}
+
+		provider.rates[pairKey(currencies[0], currencies[1])] = value
+	}
+
+	return provider, nil
+}
+
+// NewFileProvider creates a StaticProvider from a JSON file holding rates keyed by currency pair.
+func NewFileProvider(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read exchange rates file: %w", err)
+	}
+
+	var rates map[string]string
+	err = json.Unmarshal(data, &rates)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse exchange rates file %s: %w", path, err)
+	}
+
+	return NewStaticProvider(rates)
+}
+
+// Rate returns the rate to convert an amount in the from currency to the to currency
+func (provider *StaticProvider) Rate(_ context.Context, from string, to string) (Rate, error) {
+	if from == to {
+		return Rate{From: from, To: to, Value: "1"}, nil
+	}
+
+	if value, ok := provider.rates[pairKey(from, to)]; ok {
+		return Rate{From: from, To: to, Value: value}, nil
+	}
+
+	if value, ok := provider.rates[pairKey(to, from)]; ok {
+		inverse, _ := new(big.Rat).SetString(value)
+		inverse.Inv(inverse)
+		return Rate{From: from, To: to, Value: inverse.FloatString(rateScale)}, nil
+	}
+
+	return Rate{}, fmt.Errorf("%w for %s/%s", ErrRateNotFound, from, to)
+}
+
+func pairKey(from string, to string) string {
+	return from + "/" + to
+}
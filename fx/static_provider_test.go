@@ -0,0 +1,71 @@
+package fx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anilbolat/simple-bank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticProvider(t *testing.T) {
+	provider, err := NewStaticProvider(map[string]string{"USD/EUR": "0.8"})
+	require.NoError(t, err)
+
+	rate, err := provider.Rate(context.Background(), util.USD, util.EUR)
+	require.NoError(t, err)
+	require.Equal(t, Rate{From: util.USD, To: util.EUR, Value: "0.8"}, rate)
+
+	rate, err = provider.Rate(context.Background(), util.EUR, util.USD)
+	require.NoError(t, err)
+	require.Equal(t, "1.2500000000", rate.Value)
+
+	rate, err = provider.Rate(context.Background(), "CAD", "CAD")
+	require.NoError(t, err)
+	require.Equal(t, "1", rate.Value)
+
+	_, err = provider.Rate(context.Background(), util.USD, "CAD")
+	require.ErrorIs(t, err, ErrRateNotFound)
+}
+
+func TestNewStaticProviderInvalidRates(t *testing.T) {
+	_, err := NewStaticProvider(map[string]string{"USDEUR": "0.8"})
+	require.Error(t, err)
+
+	_, err = NewStaticProvider(map[string]string{"USD/EUR": "abc"})
+	require.Error(t, err)
+
+	_, err = NewStaticProvider(map[string]string{"USD/EUR": "-1"})
+	require.Error(t, err)
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	err := os.WriteFile(path, []byte(`{"EUR/CAD": "1.5"}`), 0o600)
+	require.NoError(t, err)
+
+	provider, err := NewFileProvider(path)
+	require.NoError(t, err)
+
+	rate, err := provider.Rate(context.Background(), util.EUR, "CAD")
+	require.NoError(t, err)
+	require.Equal(t, "1.5", rate.Value)
+
+	_, err = NewFileProvider(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
+
+func TestConvert(t *testing.T) {
+	amount, err := Convert(1000, Rate{From: util.USD, To: util.EUR, Value: "0.9215"})
+	require.NoError(t, err)
+	require.Equal(t, int64(921), amount)
+
+	amount, err = Convert(7, Rate{From: util.USD, To: util.USD, Value: "1"})
+	require.NoError(t, err)
+	require.Equal(t, int64(7), amount)
+
+	_, err = Convert(7, Rate{From: util.USD, To: util.EUR, Value: "0"})
+	require.Error(t, err)
+}
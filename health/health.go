@@ -0,0 +1,133 @@
+// Package health runs the checks of the dependencies the service needs to be ready.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status of a check or of all of them.
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc checks a dependency. The details, if any, are reported along with the status.
+// It must give up once ctx is done.
+type CheckFunc func(ctx context.Context) (details interface{}, err error)
+
+// Result is the outcome of one check.
+type Result struct {
+	Status   string      `json:"status"`
+	Error    string      `json:"error,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+	Duration string      `json:"duration"`
+}
+
+// Report is the outcome of all checks. Status is up only if all checks are up.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Registry holds the checks by name.
+type Registry struct {
+	timeout time.Duration
+	mu      sync.RWMutex
+	checks  map[string]CheckFunc
+}
+
+// NewRegistry creates an empty Registry, running each check with the given timeout.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		timeout: timeout,
+		checks:  make(map[string]CheckFunc),
+	}
+}
+
+// Register adds a check, replacing the one with the same name.
+func (registry *Registry) Register(name string, check CheckFunc) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.checks[name] = check
+}
+
+// Names returns the names of the registered checks, sorted.
+func (registry *Registry) Names() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	names := make([]string, 0, len(registry.checks))
+	for name := range registry.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Run runs all checks concurrently and waits for them.
+// A check still running when its timeout expires is reported down.
+func (registry *Registry) Run(ctx context.Context) Report {
+	registry.mu.RLock()
+	checks := make(map[string]CheckFunc, len(registry.checks))
+	for name, check := range registry.checks {
+		checks[name] = check
+	}
+	registry.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check CheckFunc) {
+			defer wg.Done()
+			result := registry.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (registry *Registry) run(ctx context.Context, check CheckFunc) Result {
+	ctx, cancel := context.WithTimeout(ctx, registry.timeout)
+	defer cancel()
+
+	type outcome struct {
+		details interface{}
+		err     error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		details, err := check(ctx)
+		done <- outcome{details: details, err: err}
+	}()
+
+	var result Result
+	select {
+	case o := <-done:
+		result = Result{Status: StatusUp, Details: o.details}
+		if o.err != nil {
+			result.Status, result.Error = StatusDown, o.err.Error()
+		}
+	case <-ctx.Done():
+		result = Result{Status: StatusDown, Error: fmt.Sprintf("check did not complete: %v", ctx.Err())}
+	}
+	result.Duration = time.Since(start).String()
+
+	return result
+}
@@ -0,0 +1,83 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Run(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register("db", func(ctx context.Context) (interface{}, error) {
+		return map[string]int{"open_connections": 1}, nil
+	})
+	registry.Register("cache", func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+
+	require.Equal(t, []string{"cache", "db"}, registry.Names())
+
+	report := registry.Run(context.Background())
+	require.Equal(t, StatusUp, report.Status)
+	require.Len(t, report.Checks, 2)
+	require.Equal(t, StatusUp, report.Checks["db"].Status)
+	require.Equal(t, map[string]int{"open_connections": 1}, report.Checks["db"].Details)
+	require.Empty(t, report.Checks["db"].Error)
+	require.NotEmpty(t, report.Checks["db"].Duration)
+}
+
+func TestRegistry_RunFailingCheck(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register("db", func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+	registry.Register("broker", func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	report := registry.Run(context.Background())
+	require.Equal(t, StatusDown, report.Status)
+	require.Equal(t, StatusUp, report.Checks["db"].Status)
+	require.Equal(t, StatusDown, report.Checks["broker"].Status)
+	require.Equal(t, "connection refused", report.Checks["broker"].Error)
+}
+
+func TestRegistry_RunTimeout(t *testing.T) {
+	registry := NewRegistry(10 * time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+	registry.Register("slow", func(ctx context.Context) (interface{}, error) {
+		// ignores ctx on purpose
+		<-block
+		return nil, nil
+	})
+
+	start := time.Now()
+	report := registry.Run(context.Background())
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, StatusDown, report.Status)
+	require.Contains(t, report.Checks["slow"].Error, context.DeadlineExceeded.Error())
+}
+
+func TestRegistry_RegisterReplaces(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register("db", func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("down")
+	})
+	registry.Register("db", func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+
+	report := registry.Run(context.Background())
+	require.Equal(t, StatusUp, report.Status)
+	require.Len(t, report.Checks, 1)
+}
+
+func TestRegistry_RunEmpty(t *testing.T) {
+	report := NewRegistry(time.Second).Run(context.Background())
+	require.Equal(t, StatusUp, report.Status)
+	require.Empty(t, report.Checks)
+}
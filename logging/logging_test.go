@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name    string
+		level   string
+		format  string
+		checkFn func(t *testing.T, output string)
+	}{
+		{
+			name:   "defaults",
+			level:  "",
+			format: "",
+			checkFn: func(t *testing.T, output string) {
+				var line map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(output), &line))
+				require.Equal(t, "INFO", line["level"])
+				require.Equal(t, "transfer created", line["msg"])
+				require.Equal(t, "abc-123", line[RequestIDKey])
+				require.Equal(t, float64(1), line["transfer_id"])
+			},
+		},
+		{
+			name:   "text",
+			level:  "info",
+			format: "text",
+			checkFn: func(t *testing.T, output string) {
+				require.Contains(t, output, `level=INFO msg="transfer created" transfer_id=1 request_id=abc-123`)
+			},
+		},
+		{
+			name:   "levelAboveInfo",
+			level:  "WARN",
+			format: "json",
+			checkFn: func(t *testing.T, output string) {
+				require.Empty(t, output)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			logger, err := New(&buffer, tc.level, tc.format)
+			require.NoError(t, err)
+
+			ctx := WithRequestID(context.Background(), "abc-123")
+			logger.InfoContext(ctx, "transfer created", "transfer_id", 1)
+
+			tc.checkFn(t, buffer.String())
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "verbose", "json")
+	require.ErrorContains(t, err, "invalid log level")
+
+	_, err = New(&bytes.Buffer{}, "info", "xml")
+	require.ErrorContains(t, err, "unsupported log format")
+}
+
+func TestWithoutRequestID(t *testing.T) {
+	var buffer bytes.Buffer
+	logger, err := New(&buffer, "debug", "json")
+	require.NoError(t, err)
+
+	logger.With("component", "worker").DebugContext(context.Background(), "tick")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &line))
+	require.Equal(t, "worker", line["component"])
+	require.NotContains(t, line, RequestIDKey)
+	require.Empty(t, RequestID(context.Background()))
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests no route matched, so that unknown paths do not each get a series.
+const unmatchedRoute = "unmatched"
+
+// Middleware counts the requests and observes their latency by route pattern, not by path.
+func (metrics *Metrics) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		code := strconv.Itoa(ctx.Writer.Status())
+
+		metrics.httpRequests.WithLabelValues(ctx.Request.Method, route, code).Inc()
+		metrics.httpRequestDuration.WithLabelValues(ctx.Request.Method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
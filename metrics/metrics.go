@@ -0,0 +1,93 @@
+// Package metrics exposes Prometheus metrics of the HTTP handlers and of the store.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "simple_bank"
+
+// Metrics holds the collectors in a registry of its own, so that several instances do not clash.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+
+	storeDuration     *prometheus.HistogramVec
+	storeErrors       *prometheus.CounterVec
+	transferRetries   prometheus.Counter
+	transferConflicts prometheus.Counter
+}
+
+// New creates the metrics along with the Go runtime and process collectors.
+func New() *Metrics {
+	metrics := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "code"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests by method, route and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "code"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "store",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of store operations by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "store",
+			Name:      "errors_total",
+			Help:      "Number of store operations that failed, by method. Rows not found are not counted.",
+		}, []string{"method"}),
+		transferRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "store",
+			Name:      "transfer_tx_retries_total",
+			Help:      "Number of times a transfer tx was retried after a serialization failure or a deadlock.",
+		}),
+		transferConflicts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "store",
+			Name:      "transfer_tx_conflicts_total",
+			Help:      "Number of transfers that ran into a serialization failure or a deadlock, retried or not.",
+		}),
+	}
+
+	metrics.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metrics.httpRequests,
+		metrics.httpRequestDuration,
+		metrics.storeDuration,
+		metrics.storeErrors,
+		metrics.transferRetries,
+		metrics.transferConflicts,
+	)
+
+	return metrics
+}
+
+// Registry is where the metrics are registered, also for collectors of other packages.
+func (metrics *Metrics) Registry() *prometheus.Registry {
+	return metrics.registry
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (metrics *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{Registry: metrics.registry})
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/anilbolat/simple-bank/db/mock"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	m.Run()
+}
+
+func newMockStore(t *testing.T) (*mockdb.MockStore, *Metrics, *Store) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	store := mockdb.NewMockStore(ctrl)
+	metrics := New()
+	return store, metrics, metrics.WrapStore(store)
+}
+
+func TestMiddleware(t *testing.T) {
+	metrics := New()
+	router := gin.New()
+	router.Use(metrics.Middleware())
+	router.GET("/accounts/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/accounts/1", "/accounts/2", "/unknown"} {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	// requests are labeled by route, not by path
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.httpRequests.WithLabelValues(http.MethodGet, "/accounts/:id", "404")))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.httpRequests.WithLabelValues(http.MethodGet, unmatchedRoute, "404")))
+	require.Equal(t, 2, testutil.CollectAndCount(metrics.httpRequestDuration))
+}
+
+func TestHandler(t *testing.T) {
+	metrics := New()
+	metrics.httpRequests.WithLabelValues(http.MethodGet, "/healthz", "200").Inc()
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `simple_bank_http_requests_total{code="200",method="GET",route="/healthz"} 1`)
+	require.Contains(t, recorder.Body.String(), "go_goroutines")
+}
+
+func TestStoreObservesOperations(t *testing.T) {
+	mockStore, metrics, store := newMockStore(t)
+	mockStore.EXPECT().GetAccount(gomock.Any(), int64(1)).Return(db.Account{ID: 1}, nil)
+	mockStore.EXPECT().GetAccount(gomock.Any(), int64(2)).Return(db.Account{}, sql.ErrNoRows)
+	mockStore.EXPECT().GetAccount(gomock.Any(), int64(3)).Return(db.Account{}, sql.ErrConnDone)
+
+	account, err := store.GetAccount(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), account.ID)
+
+	_, err = store.GetAccount(context.Background(), 2)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = store.GetAccount(context.Background(), 3)
+	require.ErrorIs(t, err, sql.ErrConnDone)
+
+	require.Equal(t, 1, testutil.CollectAndCount(metrics.storeDuration, "simple_bank_store_operation_duration_seconds"))
+	// rows not found are not errors of the store
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.storeErrors.WithLabelValues("GetAccount")))
+}
+
+func TestStoreTransferTx(t *testing.T) {
+	mockStore, metrics, store := newMockStore(t)
+	arg := db.TransferTxParams{FromAccountID: 1, ToAccountID: 2, Amount: 10}
+
+	gomock.InOrder(
+		mockStore.EXPECT().TransferTx(gomock.Any(), gomock.Eq(arg)).Return(db.TransferTxResult{}, nil),
+		mockStore.EXPECT().TransferTx(gomock.Any(), gomock.Eq(arg)).Return(db.TransferTxResult{Retries: 2}, nil),
+		mockStore.EXPECT().TransferTx(gomock.Any(), gomock.Eq(arg)).
+			Return(db.TransferTxResult{Retries: 3}, &pq.Error{Code: "40001"}),
+		mockStore.EXPECT().TransferTx(gomock.Any(), gomock.Eq(arg)).Return(db.TransferTxResult{}, errors.New("insufficient funds")),
+	)
+
+	for i := 0; i < 4; i++ {
+		_, _ = store.TransferTx(context.Background(), arg)
+	}
+
+	require.Equal(t, 5.0, testutil.ToFloat64(metrics.transferRetries))
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.transferConflicts))
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.storeErrors.WithLabelValues("TransferTx")))
+}
+
+func TestDBStatsCollector(t *testing.T) {
+	mockStore, metrics, _ := newMockStore(t)
+	mockStore.EXPECT().Stats().AnyTimes().Return(sql.DBStats{
+		MaxOpenConnections: 10,
+		OpenConnections:    4,
+		InUse:              3,
+		Idle:               1,
+		WaitCount:          7,
+		WaitDuration:       1500 * time.Millisecond,
+	})
+
+	expected := `
+# HELP simple_bank_db_in_use_connections Number of connections currently in use.
+# TYPE simple_bank_db_in_use_connections gauge
+simple_bank_db_in_use_connections 3
+# HELP simple_bank_db_wait_duration_seconds_total Total time blocked waiting for a new connection.
+# TYPE simple_bank_db_wait_duration_seconds_total counter
+simple_bank_db_wait_duration_seconds_total 1.5
+`
+	err := testutil.GatherAndCompare(metrics.Registry(), strings.NewReader(expected),
+		"simple_bank_db_in_use_connections", "simple_bank_db_wait_duration_seconds_total")
+	require.NoError(t, err)
+}
@@ -0,0 +1,709 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/google/uuid"
+)
+
+// Store is a db.Store that records the latency and the errors of every operation of the store it wraps.
+type Store struct {
+	store   db.Store
+	metrics *Metrics
+}
+
+var _ db.Store = (*Store)(nil)
+
+// WrapStore returns the store with its operations instrumented,
+// and registers the gauges of its connection pool.
+func (metrics *Metrics) WrapStore(store db.Store) *Store {
+	metrics.registry.MustRegister(newDBStatsCollector(store.Stats))
+
+	return &Store{store: store, metrics: metrics}
+}
+
+func (store *Store) observe(method string, start time.Time, err error) {
+	store.metrics.storeDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		store.metrics.storeErrors.WithLabelValues(method).Inc()
+	}
+}
+
+func (store *Store) TransferTx(ctx context.Context, arg db.TransferTxParams) (db.TransferTxResult, error) {
+	start := time.Now()
+	result, err := store.store.TransferTx(ctx, arg)
+	store.observe("TransferTx", start, err)
+
+	store.metrics.transferRetries.Add(float64(result.Retries))
+	if result.Retries > 0 || db.IsRetryableTxError(err) {
+		store.metrics.transferConflicts.Inc()
+	}
+
+	return result, err
+}
+
+func (store *Store) CreateAccountTx(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
+	start := time.Now()
+	result, err := store.store.CreateAccountTx(ctx, arg)
+	store.observe("CreateAccountTx", start, err)
+	return result, err
+}
+
+func (store *Store) ChangeAccountStatusTx(ctx context.Context, arg db.ChangeAccountStatusTxParams) (db.ChangeAccountStatusTxResult, error) {
+	start := time.Now()
+	result, err := store.store.ChangeAccountStatusTx(ctx, arg)
+	store.observe("ChangeAccountStatusTx", start, err)
+	return result, err
+}
+
+func (store *Store) AuthorizeHoldTx(ctx context.Context, arg db.AuthorizeHoldTxParams) (db.HoldTxResult, error) {
+	start := time.Now()
+	result, err := store.store.AuthorizeHoldTx(ctx, arg)
+	store.observe("AuthorizeHoldTx", start, err)
+	return result, err
+}
+
+func (store *Store) CaptureHoldTx(ctx context.Context, arg db.CaptureHoldTxParams) (db.CaptureHoldTxResult, error) {
+	start := time.Now()
+	result, err := store.store.CaptureHoldTx(ctx, arg)
+	store.observe("CaptureHoldTx", start, err)
+	return result, err
+}
+
+func (store *Store) VoidHoldTx(ctx context.Context, holdID int64) (db.HoldTxResult, error) {
+	start := time.Now()
+	result, err := store.store.VoidHoldTx(ctx, holdID)
+	store.observe("VoidHoldTx", start, err)
+	return result, err
+}
+
+func (store *Store) ReverseTransferTx(ctx context.Context, arg db.ReverseTransferTxParams) (db.ReverseTransferTxResult, error) {
+	start := time.Now()
+	result, err := store.store.ReverseTransferTx(ctx, arg)
+	store.observe("ReverseTransferTx", start, err)
+	return result, err
+}
+
+func (store *Store) ExpireHolds(ctx context.Context, now time.Time) (int, error) {
+	start := time.Now()
+	result, err := store.store.ExpireHolds(ctx, now)
+	store.observe("ExpireHolds", start, err)
+	return result, err
+}
+
+func (store *Store) RunScheduledTransfers(ctx context.Context, arg db.RunScheduledTransfersParams) (int, error) {
+	start := time.Now()
+	result, err := store.store.RunScheduledTransfers(ctx, arg)
+	store.observe("RunScheduledTransfers", start, err)
+	return result, err
+}
+
+func (store *Store) RelayOutboxEvents(ctx context.Context, batchSize int32,
+	publishFn func(ctx context.Context, event db.OutboxEvent) error,
+) (int, error) {
+	start := time.Now()
+	published, err := store.store.RelayOutboxEvents(ctx, batchSize, publishFn)
+	store.observe("RelayOutboxEvents", start, err)
+	return published, err
+}
+
+func (store *Store) EnqueueWebhookDeliveries(ctx context.Context, event db.OutboxEvent) (int, error) {
+	start := time.Now()
+	enqueued, err := store.store.EnqueueWebhookDeliveries(ctx, event)
+	store.observe("EnqueueWebhookDeliveries", start, err)
+	return enqueued, err
+}
+
+func (store *Store) DeliverWebhooks(ctx context.Context, arg db.DeliverWebhooksParams,
+	deliverFn func(ctx context.Context, delivery db.WebhookDelivery, subscription db.WebhookSubscription) db.WebhookAttempt,
+) (int, error) {
+	start := time.Now()
+	attempts, err := store.store.DeliverWebhooks(ctx, arg, deliverFn)
+	store.observe("DeliverWebhooks", start, err)
+	return attempts, err
+}
+
+func (store *Store) ChainAuditLog(ctx context.Context, batchSize int32) (int, error) {
+	start := time.Now()
+	chained, err := store.store.ChainAuditLog(ctx, batchSize)
+	store.observe("ChainAuditLog", start, err)
+	return chained, err
+}
+
+func (store *Store) VerifyAuditLog(ctx context.Context) (db.AuditLogVerification, error) {
+	start := time.Now()
+	result, err := store.store.VerifyAuditLog(ctx)
+	store.observe("VerifyAuditLog", start, err)
+	return result, err
+}
+
+func (store *Store) ReconcileLedger(ctx context.Context) (db.ReconciliationReport, error) {
+	start := time.Now()
+	result, err := store.store.ReconcileLedger(ctx)
+	store.observe("ReconcileLedger", start, err)
+	return result, err
+}
+
+func (store *Store) StatementTx(ctx context.Context, arg db.StatementTxParams) (db.StatementTxResult, error) {
+	start := time.Now()
+	result, err := store.store.StatementTx(ctx, arg)
+	store.observe("StatementTx", start, err)
+	return result, err
+}
+
+func (store *Store) StreamStatementTx(ctx context.Context, arg db.StatementTxParams,
+	summaryFn func(summary db.StatementSummary) error,
+	lineFn func(line db.StatementLine) error,
+) error {
+	start := time.Now()
+	err := store.store.StreamStatementTx(ctx, arg, summaryFn, lineFn)
+	store.observe("StreamStatementTx", start, err)
+	return err
+}
+
+func (store *Store) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := store.store.Ping(ctx)
+	store.observe("Ping", start, err)
+	return err
+}
+
+func (store *Store) Stats() sql.DBStats {
+	return store.store.Stats()
+}
+
+func (store *Store) MigrationVersion(ctx context.Context) (int64, bool, error) {
+	start := time.Now()
+	version, dirty, err := store.store.MigrationVersion(ctx)
+	store.observe("MigrationVersion", start, err)
+	return version, dirty, err
+}
+
+func (store *Store) AddAccountBalance(ctx context.Context, arg db.AddAccountBalanceParams) (db.Account, error) {
+	start := time.Now()
+	result, err := store.store.AddAccountBalance(ctx, arg)
+	store.observe("AddAccountBalance", start, err)
+	return result, err
+}
+
+func (store *Store) AddAccountHeldBalance(ctx context.Context, arg db.AddAccountHeldBalanceParams) (db.Account, error) {
+	start := time.Now()
+	result, err := store.store.AddAccountHeldBalance(ctx, arg)
+	store.observe("AddAccountHeldBalance", start, err)
+	return result, err
+}
+
+func (store *Store) AddTransferReversedAmount(ctx context.Context, arg db.AddTransferReversedAmountParams) (db.Transfer, error) {
+	start := time.Now()
+	result, err := store.store.AddTransferReversedAmount(ctx, arg)
+	store.observe("AddTransferReversedAmount", start, err)
+	return result, err
+}
+
+func (store *Store) BlockUserSessions(ctx context.Context, username string) (int64, error) {
+	start := time.Now()
+	result, err := store.store.BlockUserSessions(ctx, username)
+	store.observe("BlockUserSessions", start, err)
+	return result, err
+}
+
+func (store *Store) CancelScheduledTransfer(ctx context.Context, id int64) (db.ScheduledTransfer, error) {
+	start := time.Now()
+	result, err := store.store.CancelScheduledTransfer(ctx, id)
+	store.observe("CancelScheduledTransfer", start, err)
+	return result, err
+}
+
+func (store *Store) ChainAuditLogEntry(ctx context.Context, arg db.ChainAuditLogEntryParams) error {
+	start := time.Now()
+	err := store.store.ChainAuditLogEntry(ctx, arg)
+	store.observe("ChainAuditLogEntry", start, err)
+	return err
+}
+
+func (store *Store) ClaimDueScheduledTransfer(ctx context.Context, dueAt time.Time) (db.ScheduledTransfer, error) {
+	start := time.Now()
+	result, err := store.store.ClaimDueScheduledTransfer(ctx, dueAt)
+	store.observe("ClaimDueScheduledTransfer", start, err)
+	return result, err
+}
+
+func (store *Store) ClaimDueWebhookDelivery(ctx context.Context, dueAt time.Time) (db.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := store.store.ClaimDueWebhookDelivery(ctx, dueAt)
+	store.observe("ClaimDueWebhookDelivery", start, err)
+	return result, err
+}
+
+func (store *Store) ClaimUnpublishedOutboxEvents(ctx context.Context, limit int32) ([]db.OutboxEvent, error) {
+	start := time.Now()
+	result, err := store.store.ClaimUnpublishedOutboxEvents(ctx, limit)
+	store.observe("ClaimUnpublishedOutboxEvents", start, err)
+	return result, err
+}
+
+func (store *Store) CountUnchainedAuditLog(ctx context.Context) (int64, error) {
+	start := time.Now()
+	result, err := store.store.CountUnchainedAuditLog(ctx)
+	store.observe("CountUnchainedAuditLog", start, err)
+	return result, err
+}
+
+func (store *Store) CreateAccount(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
+	start := time.Now()
+	result, err := store.store.CreateAccount(ctx, arg)
+	store.observe("CreateAccount", start, err)
+	return result, err
+}
+
+func (store *Store) CreateAccountStatusChange(ctx context.Context, arg db.CreateAccountStatusChangeParams) (db.AccountStatusChange, error) {
+	start := time.Now()
+	result, err := store.store.CreateAccountStatusChange(ctx, arg)
+	store.observe("CreateAccountStatusChange", start, err)
+	return result, err
+}
+
+func (store *Store) CreateAuditLog(ctx context.Context, arg db.CreateAuditLogParams) (db.AuditLog, error) {
+	start := time.Now()
+	result, err := store.store.CreateAuditLog(ctx, arg)
+	store.observe("CreateAuditLog", start, err)
+	return result, err
+}
+
+func (store *Store) CreateEntry(ctx context.Context, arg db.CreateEntryParams) (db.Entry, error) {
+	start := time.Now()
+	result, err := store.store.CreateEntry(ctx, arg)
+	store.observe("CreateEntry", start, err)
+	return result, err
+}
+
+func (store *Store) CreateHold(ctx context.Context, arg db.CreateHoldParams) (db.Hold, error) {
+	start := time.Now()
+	result, err := store.store.CreateHold(ctx, arg)
+	store.observe("CreateHold", start, err)
+	return result, err
+}
+
+func (store *Store) CreateIdempotencyKey(ctx context.Context, arg db.CreateIdempotencyKeyParams) (db.IdempotencyKey, error) {
+	start := time.Now()
+	result, err := store.store.CreateIdempotencyKey(ctx, arg)
+	store.observe("CreateIdempotencyKey", start, err)
+	return result, err
+}
+
+func (store *Store) CreateOutboxEvent(ctx context.Context, arg db.CreateOutboxEventParams) (db.OutboxEvent, error) {
+	start := time.Now()
+	result, err := store.store.CreateOutboxEvent(ctx, arg)
+	store.observe("CreateOutboxEvent", start, err)
+	return result, err
+}
+
+func (store *Store) CreateScheduledTransfer(ctx context.Context, arg db.CreateScheduledTransferParams) (db.ScheduledTransfer, error) {
+	start := time.Now()
+	result, err := store.store.CreateScheduledTransfer(ctx, arg)
+	store.observe("CreateScheduledTransfer", start, err)
+	return result, err
+}
+
+func (store *Store) CreateScheduledTransferRun(ctx context.Context, arg db.CreateScheduledTransferRunParams) (db.ScheduledTransferRun, error) {
+	start := time.Now()
+	result, err := store.store.CreateScheduledTransferRun(ctx, arg)
+	store.observe("CreateScheduledTransferRun", start, err)
+	return result, err
+}
+
+func (store *Store) CreateSession(ctx context.Context, arg db.CreateSessionParams) (db.Session, error) {
+	start := time.Now()
+	result, err := store.store.CreateSession(ctx, arg)
+	store.observe("CreateSession", start, err)
+	return result, err
+}
+
+func (store *Store) CreateTransfer(ctx context.Context, arg db.CreateTransferParams) (db.Transfer, error) {
+	start := time.Now()
+	result, err := store.store.CreateTransfer(ctx, arg)
+	store.observe("CreateTransfer", start, err)
+	return result, err
+}
+
+func (store *Store) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	start := time.Now()
+	result, err := store.store.CreateUser(ctx, arg)
+	store.observe("CreateUser", start, err)
+	return result, err
+}
+
+func (store *Store) CreateWebhookDelivery(ctx context.Context, arg db.CreateWebhookDeliveryParams) (db.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := store.store.CreateWebhookDelivery(ctx, arg)
+	store.observe("CreateWebhookDelivery", start, err)
+	return result, err
+}
+
+func (store *Store) CreateWebhookSubscription(ctx context.Context, arg db.CreateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
+	start := time.Now()
+	result, err := store.store.CreateWebhookSubscription(ctx, arg)
+	store.observe("CreateWebhookSubscription", start, err)
+	return result, err
+}
+
+func (store *Store) DeleteAccount(ctx context.Context, id int64) error {
+	start := time.Now()
+	err := store.store.DeleteAccount(ctx, id)
+	store.observe("DeleteAccount", start, err)
+	return err
+}
+
+func (store *Store) DeleteWebhookSubscription(ctx context.Context, id int64) error {
+	start := time.Now()
+	err := store.store.DeleteWebhookSubscription(ctx, id)
+	store.observe("DeleteWebhookSubscription", start, err)
+	return err
+}
+
+func (store *Store) GetAccount(ctx context.Context, id int64) (db.Account, error) {
+	start := time.Now()
+	result, err := store.store.GetAccount(ctx, id)
+	store.observe("GetAccount", start, err)
+	return result, err
+}
+
+func (store *Store) GetAccountForUpdate(ctx context.Context, id int64) (db.Account, error) {
+	start := time.Now()
+	result, err := store.store.GetAccountForUpdate(ctx, id)
+	store.observe("GetAccountForUpdate", start, err)
+	return result, err
+}
+
+func (store *Store) GetEntry(ctx context.Context, id int64) (db.Entry, error) {
+	start := time.Now()
+	result, err := store.store.GetEntry(ctx, id)
+	store.observe("GetEntry", start, err)
+	return result, err
+}
+
+func (store *Store) GetHold(ctx context.Context, id int64) (db.Hold, error) {
+	start := time.Now()
+	result, err := store.store.GetHold(ctx, id)
+	store.observe("GetHold", start, err)
+	return result, err
+}
+
+func (store *Store) GetHoldForUpdate(ctx context.Context, id int64) (db.Hold, error) {
+	start := time.Now()
+	result, err := store.store.GetHoldForUpdate(ctx, id)
+	store.observe("GetHoldForUpdate", start, err)
+	return result, err
+}
+
+func (store *Store) GetIdempotencyKey(ctx context.Context, arg db.GetIdempotencyKeyParams) (db.IdempotencyKey, error) {
+	start := time.Now()
+	result, err := store.store.GetIdempotencyKey(ctx, arg)
+	store.observe("GetIdempotencyKey", start, err)
+	return result, err
+}
+
+func (store *Store) GetLastChainedAuditLog(ctx context.Context) (db.GetLastChainedAuditLogRow, error) {
+	start := time.Now()
+	result, err := store.store.GetLastChainedAuditLog(ctx)
+	store.observe("GetLastChainedAuditLog", start, err)
+	return result, err
+}
+
+func (store *Store) GetScheduledTransfer(ctx context.Context, id int64) (db.ScheduledTransfer, error) {
+	start := time.Now()
+	result, err := store.store.GetScheduledTransfer(ctx, id)
+	store.observe("GetScheduledTransfer", start, err)
+	return result, err
+}
+
+func (store *Store) GetScheduledTransferForUpdate(ctx context.Context, id int64) (db.ScheduledTransfer, error) {
+	start := time.Now()
+	result, err := store.store.GetScheduledTransferForUpdate(ctx, id)
+	store.observe("GetScheduledTransferForUpdate", start, err)
+	return result, err
+}
+
+func (store *Store) GetSession(ctx context.Context, id uuid.UUID) (db.Session, error) {
+	start := time.Now()
+	result, err := store.store.GetSession(ctx, id)
+	store.observe("GetSession", start, err)
+	return result, err
+}
+
+func (store *Store) GetTransfer(ctx context.Context, id int64) (db.Transfer, error) {
+	start := time.Now()
+	result, err := store.store.GetTransfer(ctx, id)
+	store.observe("GetTransfer", start, err)
+	return result, err
+}
+
+func (store *Store) GetTransferForUpdate(ctx context.Context, id int64) (db.Transfer, error) {
+	start := time.Now()
+	result, err := store.store.GetTransferForUpdate(ctx, id)
+	store.observe("GetTransferForUpdate", start, err)
+	return result, err
+}
+
+func (store *Store) GetUser(ctx context.Context, username string) (db.User, error) {
+	start := time.Now()
+	result, err := store.store.GetUser(ctx, username)
+	store.observe("GetUser", start, err)
+	return result, err
+}
+
+func (store *Store) GetWebhookDelivery(ctx context.Context, id int64) (db.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := store.store.GetWebhookDelivery(ctx, id)
+	store.observe("GetWebhookDelivery", start, err)
+	return result, err
+}
+
+func (store *Store) GetWebhookDeliveryForUpdate(ctx context.Context, id int64) (db.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := store.store.GetWebhookDeliveryForUpdate(ctx, id)
+	store.observe("GetWebhookDeliveryForUpdate", start, err)
+	return result, err
+}
+
+func (store *Store) GetWebhookSubscription(ctx context.Context, id int64) (db.WebhookSubscription, error) {
+	start := time.Now()
+	result, err := store.store.GetWebhookSubscription(ctx, id)
+	store.observe("GetWebhookSubscription", start, err)
+	return result, err
+}
+
+func (store *Store) LeaseScheduledTransfer(ctx context.Context, arg db.LeaseScheduledTransferParams) (db.ScheduledTransfer, error) {
+	start := time.Now()
+	result, err := store.store.LeaseScheduledTransfer(ctx, arg)
+	store.observe("LeaseScheduledTransfer", start, err)
+	return result, err
+}
+
+func (store *Store) LeaseWebhookDelivery(ctx context.Context, arg db.LeaseWebhookDeliveryParams) (db.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := store.store.LeaseWebhookDelivery(ctx, arg)
+	store.observe("LeaseWebhookDelivery", start, err)
+	return result, err
+}
+
+func (store *Store) ListAccountBalanceMismatches(ctx context.Context) ([]db.ListAccountBalanceMismatchesRow, error) {
+	start := time.Now()
+	result, err := store.store.ListAccountBalanceMismatches(ctx)
+	store.observe("ListAccountBalanceMismatches", start, err)
+	return result, err
+}
+
+func (store *Store) ListAccounts(ctx context.Context, arg db.ListAccountsParams) ([]db.Account, error) {
+	start := time.Now()
+	result, err := store.store.ListAccounts(ctx, arg)
+	store.observe("ListAccounts", start, err)
+	return result, err
+}
+
+func (store *Store) ListAccountsAfter(ctx context.Context, arg db.ListAccountsAfterParams) ([]db.Account, error) {
+	start := time.Now()
+	result, err := store.store.ListAccountsAfter(ctx, arg)
+	store.observe("ListAccountsAfter", start, err)
+	return result, err
+}
+
+func (store *Store) ListAccountStatusChanges(ctx context.Context, accountID int64) ([]db.AccountStatusChange, error) {
+	start := time.Now()
+	result, err := store.store.ListAccountStatusChanges(ctx, accountID)
+	store.observe("ListAccountStatusChanges", start, err)
+	return result, err
+}
+
+func (store *Store) ListAuditLog(ctx context.Context, arg db.ListAuditLogParams) ([]db.AuditLog, error) {
+	start := time.Now()
+	result, err := store.store.ListAuditLog(ctx, arg)
+	store.observe("ListAuditLog", start, err)
+	return result, err
+}
+
+func (store *Store) ListCurrencyTotals(ctx context.Context) ([]db.ListCurrencyTotalsRow, error) {
+	start := time.Now()
+	result, err := store.store.ListCurrencyTotals(ctx)
+	store.observe("ListCurrencyTotals", start, err)
+	return result, err
+}
+
+func (store *Store) ListChainedAuditLog(ctx context.Context, arg db.ListChainedAuditLogParams) ([]db.AuditLog, error) {
+	start := time.Now()
+	result, err := store.store.ListChainedAuditLog(ctx, arg)
+	store.observe("ListChainedAuditLog", start, err)
+	return result, err
+}
+
+func (store *Store) ListEntries(ctx context.Context, arg db.ListEntriesParams) ([]db.Entry, error) {
+	start := time.Now()
+	result, err := store.store.ListEntries(ctx, arg)
+	store.observe("ListEntries", start, err)
+	return result, err
+}
+
+func (store *Store) ListEntriesAfter(ctx context.Context, arg db.ListEntriesAfterParams) ([]db.Entry, error) {
+	start := time.Now()
+	result, err := store.store.ListEntriesAfter(ctx, arg)
+	store.observe("ListEntriesAfter", start, err)
+	return result, err
+}
+
+func (store *Store) ListEntriesBetween(ctx context.Context, arg db.ListEntriesBetweenParams) ([]db.Entry, error) {
+	start := time.Now()
+	result, err := store.store.ListEntriesBetween(ctx, arg)
+	store.observe("ListEntriesBetween", start, err)
+	return result, err
+}
+
+func (store *Store) ListExpiredHolds(ctx context.Context, arg db.ListExpiredHoldsParams) ([]int64, error) {
+	start := time.Now()
+	result, err := store.store.ListExpiredHolds(ctx, arg)
+	store.observe("ListExpiredHolds", start, err)
+	return result, err
+}
+
+func (store *Store) ListTransferEntryMismatches(ctx context.Context) ([]db.ListTransferEntryMismatchesRow, error) {
+	start := time.Now()
+	result, err := store.store.ListTransferEntryMismatches(ctx)
+	store.observe("ListTransferEntryMismatches", start, err)
+	return result, err
+}
+
+func (store *Store) ListTransferReversals(ctx context.Context, transferID int64) ([]db.Transfer, error) {
+	start := time.Now()
+	result, err := store.store.ListTransferReversals(ctx, transferID)
+	store.observe("ListTransferReversals", start, err)
+	return result, err
+}
+
+func (store *Store) ListTransfers(ctx context.Context, arg db.ListTransfersParams) ([]db.Transfer, error) {
+	start := time.Now()
+	result, err := store.store.ListTransfers(ctx, arg)
+	store.observe("ListTransfers", start, err)
+	return result, err
+}
+
+func (store *Store) ListTransfersAfter(ctx context.Context, arg db.ListTransfersAfterParams) ([]db.Transfer, error) {
+	start := time.Now()
+	result, err := store.store.ListTransfersAfter(ctx, arg)
+	store.observe("ListTransfersAfter", start, err)
+	return result, err
+}
+
+func (store *Store) ListScheduledTransferRuns(ctx context.Context, arg db.ListScheduledTransferRunsParams) ([]db.ScheduledTransferRun, error) {
+	start := time.Now()
+	result, err := store.store.ListScheduledTransferRuns(ctx, arg)
+	store.observe("ListScheduledTransferRuns", start, err)
+	return result, err
+}
+
+func (store *Store) ListScheduledTransfers(ctx context.Context, owner string) ([]db.ScheduledTransfer, error) {
+	start := time.Now()
+	result, err := store.store.ListScheduledTransfers(ctx, owner)
+	store.observe("ListScheduledTransfers", start, err)
+	return result, err
+}
+
+func (store *Store) ListUnchainedAuditLog(ctx context.Context, limit int32) ([]db.AuditLog, error) {
+	start := time.Now()
+	result, err := store.store.ListUnchainedAuditLog(ctx, limit)
+	store.observe("ListUnchainedAuditLog", start, err)
+	return result, err
+}
+
+func (store *Store) ListWebhookDeliveries(ctx context.Context, arg db.ListWebhookDeliveriesParams) ([]db.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := store.store.ListWebhookDeliveries(ctx, arg)
+	store.observe("ListWebhookDeliveries", start, err)
+	return result, err
+}
+
+func (store *Store) ListWebhookSubscriptions(ctx context.Context, owner string) ([]db.WebhookSubscription, error) {
+	start := time.Now()
+	result, err := store.store.ListWebhookSubscriptions(ctx, owner)
+	store.observe("ListWebhookSubscriptions", start, err)
+	return result, err
+}
+
+func (store *Store) ListWebhookSubscriptionsForEvent(ctx context.Context, arg db.ListWebhookSubscriptionsForEventParams) ([]db.WebhookSubscription, error) {
+	start := time.Now()
+	result, err := store.store.ListWebhookSubscriptionsForEvent(ctx, arg)
+	store.observe("ListWebhookSubscriptionsForEvent", start, err)
+	return result, err
+}
+
+func (store *Store) LockAuditLog(ctx context.Context) error {
+	start := time.Now()
+	err := store.store.LockAuditLog(ctx)
+	store.observe("LockAuditLog", start, err)
+	return err
+}
+
+func (store *Store) MarkOutboxEventPublished(ctx context.Context, id int64) error {
+	start := time.Now()
+	err := store.store.MarkOutboxEventPublished(ctx, id)
+	store.observe("MarkOutboxEventPublished", start, err)
+	return err
+}
+
+func (store *Store) ReplayWebhookDelivery(ctx context.Context, id int64) (db.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := store.store.ReplayWebhookDelivery(ctx, id)
+	store.observe("ReplayWebhookDelivery", start, err)
+	return result, err
+}
+
+func (store *Store) SumEntriesSince(ctx context.Context, arg db.SumEntriesSinceParams) (int64, error) {
+	start := time.Now()
+	result, err := store.store.SumEntriesSince(ctx, arg)
+	store.observe("SumEntriesSince", start, err)
+	return result, err
+}
+
+func (store *Store) UpdateAccount(ctx context.Context, arg db.UpdateAccountParams) (db.Account, error) {
+	start := time.Now()
+	result, err := store.store.UpdateAccount(ctx, arg)
+	store.observe("UpdateAccount", start, err)
+	return result, err
+}
+
+func (store *Store) UpdateAccountStatus(ctx context.Context, arg db.UpdateAccountStatusParams) (db.Account, error) {
+	start := time.Now()
+	result, err := store.store.UpdateAccountStatus(ctx, arg)
+	store.observe("UpdateAccountStatus", start, err)
+	return result, err
+}
+
+func (store *Store) UpdateHold(ctx context.Context, arg db.UpdateHoldParams) (db.Hold, error) {
+	start := time.Now()
+	result, err := store.store.UpdateHold(ctx, arg)
+	store.observe("UpdateHold", start, err)
+	return result, err
+}
+
+func (store *Store) UpdateIdempotencyKeyResponse(ctx context.Context, arg db.UpdateIdempotencyKeyResponseParams) error {
+	start := time.Now()
+	err := store.store.UpdateIdempotencyKeyResponse(ctx, arg)
+	store.observe("UpdateIdempotencyKeyResponse", start, err)
+	return err
+}
+
+func (store *Store) UpdateScheduledTransfer(ctx context.Context, arg db.UpdateScheduledTransferParams) (db.ScheduledTransfer, error) {
+	start := time.Now()
+	result, err := store.store.UpdateScheduledTransfer(ctx, arg)
+	store.observe("UpdateScheduledTransfer", start, err)
+	return result, err
+}
+
+func (store *Store) UpdateWebhookDelivery(ctx context.Context, arg db.UpdateWebhookDeliveryParams) (db.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := store.store.UpdateWebhookDelivery(ctx, arg)
+	store.observe("UpdateWebhookDelivery", start, err)
+	return result, err
+}
@@ -0,0 +1,93 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+)
+
+// Headers of the messages written by FilePublisher.
+// Nats-Msg-Id is the header NATS JetStream drops duplicate messages by.
+const (
+	HeaderMessageID     = "Nats-Msg-Id"
+	HeaderEventType     = "Event-Type"
+	HeaderAggregateType = "Aggregate-Type"
+	HeaderAggregateID   = "Aggregate-Id"
+)
+
+// Message is an event as written by FilePublisher, one JSON line each.
+// It has the subject, headers and data of a NATS message, so the lines can be forwarded to NATS as they are.
+type Message struct {
+	Subject   string            `json:"subject"`
+	Headers   map[string]string `json:"headers"`
+	Data      json.RawMessage   `json:"data"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// NewMessage is the message of the event.
+func NewMessage(event db.OutboxEvent) Message {
+	return Message{
+		Subject: Subject(event),
+		Headers: map[string]string{
+			HeaderMessageID:     strconv.FormatInt(event.ID, 10),
+			HeaderEventType:     event.EventType,
+			HeaderAggregateType: event.AggregateType,
+			HeaderAggregateID:   strconv.FormatInt(event.AggregateID, 10),
+		},
+		Data:      event.Payload,
+		CreatedAt: event.CreatedAt,
+	}
+}
+
+// FilePublisher appends the events to a file as JSON lines of Message.
+type FilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+var _ Publisher = (*FilePublisher)(nil)
+
+// NewFilePublisher creates a FilePublisher appending to the file at path, created if it does not exist.
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open outbox file: %w", err)
+	}
+
+	return &FilePublisher{file: file}, nil
+}
+
+// Publish writes the event and syncs the file, so the event is on disk before it is marked published.
+func (publisher *FilePublisher) Publish(ctx context.Context, event db.OutboxEvent) error {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	err := writeMessage(publisher.file, NewMessage(event))
+	if err != nil {
+		return err
+	}
+
+	return publisher.file.Sync()
+}
+
+func (publisher *FilePublisher) Close() error {
+	return publisher.file.Close()
+}
+
+// writeMessage writes the message as a single line, so a line is never split between two writes.
+func writeMessage(w io.Writer, message Message) error {
+	line, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,46 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+)
+
+// MemoryPublisher keeps the latest published events in memory, for tests and local runs.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	events   []db.OutboxEvent
+	capacity int
+}
+
+var _ Publisher = (*MemoryPublisher)(nil)
+
+// NewMemoryPublisher creates a MemoryPublisher keeping the latest capacity events, all of them if it is not positive.
+func NewMemoryPublisher(capacity int) *MemoryPublisher {
+	return &MemoryPublisher{capacity: capacity}
+}
+
+func (publisher *MemoryPublisher) Publish(ctx context.Context, event db.OutboxEvent) error {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	publisher.events = append(publisher.events, event)
+	if publisher.capacity > 0 && len(publisher.events) > publisher.capacity {
+		publisher.events = publisher.events[len(publisher.events)-publisher.capacity:]
+	}
+
+	return nil
+}
+
+// Events returns the events kept, oldest first.
+func (publisher *MemoryPublisher) Events() []db.OutboxEvent {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	return append([]db.OutboxEvent(nil), publisher.events...)
+}
+
+func (publisher *MemoryPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,35 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+)
+
+// MultiPublisher publishes every event with each of its publishers, in order.
+// An event is published again with all of them if one fails, so they all get it at least once.
+type MultiPublisher []Publisher
+
+var _ Publisher = MultiPublisher(nil)
+
+func (publishers MultiPublisher) Publish(ctx context.Context, event db.OutboxEvent) error {
+	for _, publisher := range publishers {
+		err := publisher.Publish(ctx, event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes all the publishers, also if some of them fail to.
+func (publishers MultiPublisher) Close() error {
+	var errs []error
+	for _, publisher := range publishers {
+		errs = append(errs, publisher.Close())
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,90 @@
+// Package outbox relays the domain events written to the outbox table by the db txs to a Publisher,
+// so downstream systems learn about accounts and money movements without the txs depending on them.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+)
+
+// DefaultBatchSize is how many events a relay claims at a time if no batch size is given.
+const DefaultBatchSize = 100
+
+// SubjectPrefix starts the subjects of all events.
+const SubjectPrefix = "simple_bank"
+
+// Publishers supported by NewPublisher.
+const (
+	// PublisherNone publishes nothing, the events stay in the outbox.
+	PublisherNone = "none"
+	// PublisherMemory keeps the latest events in memory.
+	PublisherMemory = "memory"
+	// PublisherFile appends the events as JSON lines to a file.
+	PublisherFile = "file"
+)
+
+// memoryPublisherCapacity is how many events the memory publisher created by NewPublisher keeps.
+const memoryPublisherCapacity = 1000
+
+// Publisher delivers the events of the outbox to downstream systems.
+// Publish must return only once the event is delivered, the event is marked published right after.
+// An event may be published more than once, so receivers should tell duplicates apart by the event ID.
+type Publisher interface {
+	Publish(ctx context.Context, event db.OutboxEvent) error
+	io.Closer
+}
+
+// NewPublisher creates the named publisher, nil for none. The file publisher appends to the file at path.
+func NewPublisher(publisher string, path string) (Publisher, error) {
+	switch strings.ToLower(publisher) {
+	case "", PublisherNone:
+		return nil, nil
+	case PublisherMemory:
+		return NewMemoryPublisher(memoryPublisherCapacity), nil
+	case PublisherFile:
+		if path == "" {
+			return nil, errors.New("file publisher needs OUTBOX_FILE")
+		}
+		return NewFilePublisher(path)
+	default:
+		return nil, fmt.Errorf("unsupported outbox publisher %q", publisher)
+	}
+}
+
+// Subject is the NATS-style subject of the event, like "simple_bank.transfer.TransferCompleted",
+// so that receivers can subscribe to all events of a kind with wildcards.
+func Subject(event db.OutboxEvent) string {
+	return SubjectPrefix + "." + event.AggregateType + "." + event.EventType
+}
+
+// Relay publishes the events of the outbox with its publisher.
+type Relay struct {
+	store     db.Store
+	publisher Publisher
+	batchSize int32
+}
+
+// NewRelay creates a relay claiming up to batchSize events at a time, DefaultBatchSize if it is not positive.
+func NewRelay(store db.Store, publisher Publisher, batchSize int32) *Relay {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	return &Relay{store: store, publisher: publisher, batchSize: batchSize}
+}
+
+// Run publishes the events not published yet, oldest first, and returns the number of events published.
+// It stops at the first event the publisher fails to publish, the next run starts over with it.
+func (relay *Relay) Run(ctx context.Context) (int, error) {
+	published, err := relay.store.RelayOutboxEvents(ctx, relay.batchSize, relay.publisher.Publish)
+	if err != nil {
+		return published, fmt.Errorf("cannot relay outbox events: %w", err)
+	}
+
+	return published, nil
+}
@@ -0,0 +1,197 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mockdb "github.com/anilbolat/simple-bank/db/mock"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func randomEvent(id int64) db.OutboxEvent {
+	return db.OutboxEvent{
+		ID:            id,
+		EventType:     db.EventTransferCompleted,
+		AggregateType: db.AggregateTransfer,
+		AggregateID:   id * 10,
+		Payload:       json.RawMessage(fmt.Sprintf(`{"transfer_id":%d}`, id*10)),
+		CreatedAt:     time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func TestNewPublisher(t *testing.T) {
+	publisher, err := NewPublisher("", "")
+	require.NoError(t, err)
+	require.Nil(t, publisher)
+
+	publisher, err = NewPublisher("memory", "")
+	require.NoError(t, err)
+	require.IsType(t, &MemoryPublisher{}, publisher)
+
+	publisher, err = NewPublisher("file", filepath.Join(t.TempDir(), "outbox.jsonl"))
+	require.NoError(t, err)
+	require.IsType(t, &FilePublisher{}, publisher)
+	require.NoError(t, publisher.Close())
+
+	_, err = NewPublisher("file", "")
+	require.Error(t, err)
+
+	_, err = NewPublisher("kafka", "")
+	require.Error(t, err)
+}
+
+func TestSubject(t *testing.T) {
+	require.Equal(t, "simple_bank.transfer.TransferCompleted", Subject(randomEvent(1)))
+}
+
+func TestMemoryPublisher(t *testing.T) {
+	publisher := NewMemoryPublisher(2)
+
+	for id := int64(1); id <= 3; id++ {
+		require.NoError(t, publisher.Publish(context.Background(), randomEvent(id)))
+	}
+
+	// only the latest events are kept
+	events := publisher.Events()
+	require.Len(t, events, 2)
+	require.Equal(t, int64(2), events[0].ID)
+	require.Equal(t, int64(3), events[1].ID)
+}
+
+type failingPublisher struct {
+	err error
+}
+
+func (publisher failingPublisher) Publish(ctx context.Context, event db.OutboxEvent) error {
+	return publisher.err
+}
+
+func (publisher failingPublisher) Close() error {
+	return publisher.err
+}
+
+func TestMultiPublisher(t *testing.T) {
+	first := NewMemoryPublisher(10)
+	last := NewMemoryPublisher(10)
+
+	err := MultiPublisher{first, last}.Publish(context.Background(), randomEvent(1))
+	require.NoError(t, err)
+	require.Len(t, first.Events(), 1)
+	require.Len(t, last.Events(), 1)
+
+	// the publishers after a failing one do not get the event
+	errPublish := errors.New("publisher is down")
+	publisher := MultiPublisher{first, failingPublisher{err: errPublish}, last}
+	err = publisher.Publish(context.Background(), randomEvent(2))
+	require.ErrorIs(t, err, errPublish)
+	require.Len(t, first.Events(), 2)
+	require.Len(t, last.Events(), 1)
+
+	require.ErrorIs(t, publisher.Close(), errPublish)
+}
+
+func TestFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	// the file is appended to, also when opened again
+	for id := int64(1); id <= 2; id++ {
+		publisher, err := NewFilePublisher(path)
+		require.NoError(t, err)
+		require.NoError(t, publisher.Publish(context.Background(), randomEvent(id)))
+		require.NoError(t, publisher.Close())
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var messages []Message
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var message Message
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &message))
+		messages = append(messages, message)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, messages, 2)
+	for i, message := range messages {
+		event := randomEvent(int64(i + 1))
+		require.Equal(t, Subject(event), message.Subject)
+		require.Equal(t, map[string]string{
+			HeaderMessageID:     fmt.Sprint(event.ID),
+			HeaderEventType:     db.EventTransferCompleted,
+			HeaderAggregateType: db.AggregateTransfer,
+			HeaderAggregateID:   fmt.Sprint(event.AggregateID),
+		}, message.Headers)
+		require.JSONEq(t, string(event.Payload), string(message.Data))
+	}
+}
+
+func TestRelayRun(t *testing.T) {
+	events := []db.OutboxEvent{randomEvent(1), randomEvent(2)}
+	errPublish := errors.New("publisher is down")
+
+	testCases := []struct {
+		name        string
+		publishErr  error
+		checkResult func(t *testing.T, published int, err error, publisher *MemoryPublisher)
+	}{
+		{
+			name: "OK",
+			checkResult: func(t *testing.T, published int, err error, publisher *MemoryPublisher) {
+				require.NoError(t, err)
+				require.Equal(t, 2, published)
+				require.Equal(t, events, publisher.Events())
+			},
+		},
+		{
+			name:       "PublishError",
+			publishErr: errPublish,
+			checkResult: func(t *testing.T, published int, err error, publisher *MemoryPublisher) {
+				require.ErrorIs(t, err, errPublish)
+				require.Zero(t, published)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			publisher := NewMemoryPublisher(0)
+
+			// stub
+			store.EXPECT().
+				RelayOutboxEvents(gomock.Any(), gomock.Eq(int32(DefaultBatchSize)), gomock.Any()).
+				Times(1).
+				DoAndReturn(func(ctx context.Context, batchSize int32, publishFn func(ctx context.Context, event db.OutboxEvent) error) (int, error) {
+					if tc.publishErr != nil {
+						return 0, tc.publishErr
+					}
+					for _, event := range events {
+						if err := publishFn(ctx, event); err != nil {
+							return 0, err
+						}
+					}
+					return len(events), nil
+				})
+
+			// test
+			published, err := NewRelay(store, publisher, 0).Run(context.Background())
+
+			// assert
+			tc.checkResult(t, published, err, publisher)
+		})
+	}
+}
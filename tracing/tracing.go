@@ -0,0 +1,58 @@
+// Package tracing sets up OpenTelemetry tracing, exporting the spans with the configured exporter
+// and propagating the W3C trace context.
+package tracing
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// InstrumentationName names the tracers of the service.
+const InstrumentationName = "github.com/anilbolat/simple-bank"
+
+const serviceName = "simple-bank"
+
+// Exporters supported by NewProvider.
+const (
+	// ExporterNone creates the spans, so that the trace context is propagated, but does not export them.
+	ExporterNone = "none"
+	// ExporterStdout writes the spans as JSON with the OpenTelemetry stdout exporter, to the writer given to NewProvider.
+	ExporterStdout = "stdout"
+)
+
+// NewProvider creates a tracer provider exporting the spans with the named exporter, none being the default.
+// The spans written by the stdout exporter go to w.
+// Spans are sampled as their parent, and always if there is no parent.
+// The provider must be shut down to flush the spans still buffered.
+func NewProvider(exporter string, w io.Writer) (*sdktrace.TracerProvider, error) {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	}
+
+	switch strings.ToLower(exporter) {
+	case "", ExporterNone:
+	case ExporterStdout:
+		stdoutExporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, fmt.Errorf("cannot create stdout exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(stdoutExporter))
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter %q", exporter)
+	}
+
+	return sdktrace.NewTracerProvider(options...), nil
+}
+
+// Propagator reads and writes the W3C trace context and baggage headers.
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewProvider(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exporter string
+		checkFn  func(t *testing.T, output []byte)
+	}{
+		{
+			name:     "stdout",
+			exporter: "stdout",
+			checkFn: func(t *testing.T, output []byte) {
+				var span struct {
+					Name string
+				}
+				require.NoError(t, json.Unmarshal(output, &span))
+				require.Equal(t, "TransferTx", span.Name)
+				require.Contains(t, string(output), `"Key":"service.name","Value":{"Type":"STRING","Value":"simple-bank"}`)
+			},
+		},
+		{
+			name:     "none",
+			exporter: "",
+			checkFn: func(t *testing.T, output []byte) {
+				require.Empty(t, output)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			provider, err := NewProvider(tc.exporter, &buffer)
+			require.NoError(t, err)
+
+			_, span := provider.Tracer(InstrumentationName).Start(context.Background(), "TransferTx")
+			require.True(t, span.SpanContext().IsValid())
+			span.End()
+
+			require.NoError(t, provider.Shutdown(context.Background()))
+			tc.checkFn(t, buffer.Bytes())
+		})
+	}
+}
+
+func TestNewProviderUnsupportedExporter(t *testing.T) {
+	_, err := NewProvider("zipkin", &bytes.Buffer{})
+	require.ErrorContains(t, err, "unsupported tracing exporter")
+}
+
+func TestPropagator(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx := Propagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+	spanContext := trace.SpanContextFromContext(ctx)
+
+	require.True(t, spanContext.IsRemote())
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanContext.TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", spanContext.SpanID().String())
+}
@@ -1,13 +1,46 @@
 package util
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 // Config stores all configuration of the application.
 // The values are read by viper from a config file or env vars.
 type Config struct {
-	DBDriver      string `mapstructure:"DB_DRIVER"`
-	DBSource      string `mapstructure:"DB_SOURCE"`
-	ServerAddress string `mapstructure:"SERVER_ADDRESS"`
+	DBDriver                  string        `mapstructure:"DB_DRIVER"`
+	DBSource                  string        `mapstructure:"DB_SOURCE"`
+	ServerAddress             string        `mapstructure:"SERVER_ADDRESS"`
+	MetricsAddress            string        `mapstructure:"METRICS_ADDRESS"`
+	TokenType                 string        `mapstructure:"TOKEN_TYPE"`
+	TokenSymmetricKey         string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	AccessTokenDuration       time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration      time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	ExchangeRatesFile         string        `mapstructure:"EXCHANGE_RATES_FILE"`
+	CursorSigningKey          string        `mapstructure:"CURSOR_SIGNING_KEY"`
+	HTTPReadTimeout           time.Duration `mapstructure:"HTTP_READ_TIMEOUT"`
+	HTTPWriteTimeout          time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT"`
+	HTTPIdleTimeout           time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT"`
+	StatementExportTimeout    time.Duration `mapstructure:"STATEMENT_EXPORT_TIMEOUT"`
+	ShutdownTimeout           time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	ShutdownDrainDelay        time.Duration `mapstructure:"SHUTDOWN_DRAIN_DELAY"`
+	HealthCheckTimeout        time.Duration `mapstructure:"HEALTH_CHECK_TIMEOUT"`
+	LogLevel                  string        `mapstructure:"LOG_LEVEL"`
+	LogFormat                 string        `mapstructure:"LOG_FORMAT"`
+	TracingExporter           string        `mapstructure:"TRACING_EXPORTER"`
+	HoldExpiryInterval        time.Duration `mapstructure:"HOLD_EXPIRY_INTERVAL"`
+	ScheduledTransferInterval time.Duration `mapstructure:"SCHEDULED_TRANSFER_INTERVAL"`
+	ScheduledTransferCatchUp  string        `mapstructure:"SCHEDULED_TRANSFER_CATCH_UP"`
+	OutboxPublisher           string        `mapstructure:"OUTBOX_PUBLISHER"`
+	OutboxFile                string        `mapstructure:"OUTBOX_FILE"`
+	OutboxRelayInterval       time.Duration `mapstructure:"OUTBOX_RELAY_INTERVAL"`
+	WebhookDeliveryInterval   time.Duration `mapstructure:"WEBHOOK_DELIVERY_INTERVAL"`
+	WebhookTimeout            time.Duration `mapstructure:"WEBHOOK_TIMEOUT"`
+	AuditChainInterval        time.Duration `mapstructure:"AUDIT_CHAIN_INTERVAL"`
+	ReconciliationEnabled     bool          `mapstructure:"RECONCILIATION_ENABLED"`
+	ReconciliationInterval    time.Duration `mapstructure:"RECONCILIATION_INTERVAL"`
+	ReconciliationReportFile  string        `mapstructure:"RECONCILIATION_REPORT_FILE"`
 }
 
 func LoadConfig(path string) (Config, error) {
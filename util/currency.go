@@ -0,0 +1,32 @@
+package util
+
+// Constants for all supported currencies
+const (
+	USD = "USD"
+	EUR = "EUR"
+)
+
+// IsSupportedCurrency returns true if the currency is supported
+func IsSupportedCurrency(currency string) bool {
+	switch currency {
+	case USD, EUR:
+		return true
+	}
+	return false
+}
+
+// currencyExponents are the ISO 4217 exponents of the supported currencies.
+var currencyExponents = map[string]int{
+	USD: 2,
+	EUR: 2,
+}
+
+// CurrencyExponent returns the number of digits after the decimal separator of the currency, its ISO 4217 exponent.
+// Amounts are stored as integers of the minor unit, e.g. 1050 is 10.50 EUR.
+func CurrencyExponent(currency string) int {
+	exponent, ok := currencyExponents[currency]
+	if !ok {
+		return 2
+	}
+	return exponent
+}
@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrInsecureURL is returned by CheckURL when a URL is not https.
+	ErrInsecureURL = errors.New("webhook URL must be an https URL")
+	// ErrForbiddenAddress is returned when a webhook URL points to a loopback, link-local or private address,
+	// which would let a subscriber reach the internal network of the bank.
+	ErrForbiddenAddress = errors.New("webhook URL must not point to a loopback, link-local or private address")
+)
+
+// CheckURL tells whether rawURL may be subscribed to: an https URL whose host is not a forbidden address.
+// A host name is not resolved here, as it may resolve to another address by the time of a delivery.
+// The address it resolves to is checked when the dispatcher connects to it instead.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return ErrInsecureURL
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && isForbiddenAddr(addr) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// forbiddenPrefixes are the ranges not of the internet that the methods of netip.Addr do not tell about.
+var forbiddenPrefixes = []netip.Prefix{
+	// carrier-grade NAT, often reachable from within the network
+	netip.MustParsePrefix("100.64.0.0/10"),
+	// NAT64, which can wrap any IPv4 address, private ones included
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// isForbiddenAddr tells whether addr is of the bank rather than of a partner on the internet.
+func isForbiddenAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return true
+	}
+
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDialAddress refuses to connect to a forbidden address. It runs once the host name of a request is resolved, so a name resolving to a forbidden address cannot get around CheckURL.
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if isForbiddenAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr)
+	}
+
+	return nil
+}
+
+// newHTTPClient creates the client the deliveries are sent with, which only connects to the addresses
+// allowed by CheckURL. It ignores the proxy env vars, the proxy being the address connected to otherwise.
+// It does not follow redirects, which would send the signed body to a URL that was never checked,
+// the redirect response fails the attempt instead.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: checkDialAddress,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,173 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+)
+
+// RetryPolicy controls how failed deliveries are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a delivery is attempted at most, including the first attempt.
+	MaxAttempts int32
+	// BaseDelay is the backoff before the first retry, it doubles with every further retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between two attempts.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy retries a delivery for about a day.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 10,
+	BaseDelay:   time.Minute,
+	MaxDelay:    6 * time.Hour,
+}
+
+// backoff returns the delay before the attempt after the given number of attempts.
+func (policy RetryPolicy) backoff(attempts int32) time.Duration {
+	delay := policy.BaseDelay
+	for i := int32(1); i < attempts && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	return delay
+}
+
+// leaseMargin is added to the timeout of the requests for the lease of a delivery,
+// so that the attempt is recorded before another worker takes the delivery over.
+const leaseMargin = 30 * time.Second
+
+// Dispatcher sends the pending webhook deliveries.
+type Dispatcher struct {
+	store       db.Store
+	client      *http.Client
+	lease       time.Duration
+	retryPolicy RetryPolicy
+	now         func() time.Time
+}
+
+// DispatcherOption configures a Dispatcher created by NewDispatcher.
+type DispatcherOption func(dispatcher *Dispatcher)
+
+// WithRetryPolicy sets the policy for retrying failed deliveries.
+func WithRetryPolicy(policy RetryPolicy) DispatcherOption {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.retryPolicy = policy
+	}
+}
+
+// WithHTTPClient sets the client the requests are sent with, in place of the one that only connects
+// to the addresses allowed by CheckURL.
+func WithHTTPClient(client *http.Client) DispatcherOption {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.client = client
+	}
+}
+
+// NewDispatcher creates a dispatcher sending the requests with a timeout of timeout each.
+// It does not connect to loopback, link-local or private addresses, whatever the URL of the subscription,
+// and does not follow redirects.
+func NewDispatcher(store db.Store, timeout time.Duration, opts ...DispatcherOption) *Dispatcher {
+	dispatcher := &Dispatcher{
+		store:       store,
+		client:      newHTTPClient(timeout),
+		lease:       timeout + leaseMargin,
+		retryPolicy: DefaultRetryPolicy,
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(dispatcher)
+	}
+
+	return dispatcher
+}
+
+// Run attempts the deliveries due by now and returns the number of attempts made.
+func (dispatcher *Dispatcher) Run(ctx context.Context) (int, error) {
+	attempts, err := dispatcher.store.DeliverWebhooks(ctx, db.DeliverWebhooksParams{
+		Now:   dispatcher.now(),
+		Lease: dispatcher.lease,
+	}, dispatcher.deliver)
+	if err != nil {
+		return attempts, fmt.Errorf("cannot deliver webhooks: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// deliver sends the delivery to the URL of the subscription. A delivery succeeds with any 2xx response,
+// otherwise it is retried after a backoff until it runs out of attempts.
+func (dispatcher *Dispatcher) deliver(ctx context.Context, delivery db.WebhookDelivery, subscription db.WebhookSubscription) db.WebhookAttempt {
+	responseStatus, err := dispatcher.send(ctx, delivery, subscription)
+	if err == nil {
+		return db.WebhookAttempt{
+			Status:         db.WebhookDeliverySucceeded,
+			NextAttemptAt:  delivery.NextAttemptAt,
+			ResponseStatus: responseStatus,
+		}
+	}
+
+	attempt := db.WebhookAttempt{
+		Status:         db.WebhookDeliveryPending,
+		NextAttemptAt:  dispatcher.now().Add(dispatcher.retryPolicy.backoff(delivery.Attempts + 1)),
+		ResponseStatus: responseStatus,
+		Error:          err.Error(),
+	}
+	if delivery.Attempts+1 >= dispatcher.retryPolicy.MaxAttempts {
+		attempt.Status, attempt.NextAttemptAt = db.WebhookDeliveryFailed, delivery.NextAttemptAt
+	}
+
+	return attempt
+}
+
+// send makes the signed request of the delivery and returns the status of the response, zero if there was none.
+func (dispatcher *Dispatcher) send(ctx context.Context, delivery db.WebhookDelivery, subscription db.WebhookSubscription) (int32, error) {
+	body, err := json.Marshal(Event{
+		ID:   delivery.EventID,
+		Type: delivery.EventType,
+		Data: delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	// subscriptions made before the URLs were checked may have an http URL,
+	// the address connected to is checked by the client
+	if request.URL.Scheme != "https" {
+		return 0, ErrInsecureURL
+	}
+
+	timestamp := dispatcher.now()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	request.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, body))
+	request.Header.Set(HeaderEventType, delivery.EventType)
+	request.Header.Set(HeaderDeliveryID, strconv.FormatInt(delivery.ID, 10))
+
+	response, err := dispatcher.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	// the body is not kept, the owner of the subscription can read the delivery log
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return int32(response.StatusCode), fmt.Errorf("receiver responded with %s", response.Status)
+	}
+
+	return int32(response.StatusCode), nil
+}
@@ -0,0 +1,291 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/anilbolat/simple-bank/db/mock"
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+// newReceiver starts a webhook receiver verifying the requests like a partner would, and answering with status.
+func newReceiver(t *testing.T, secret string, status int, events chan<- Event) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		err = Verify(secret, r.Header.Get(HeaderSignature), r.Header.Get(HeaderTimestamp), body, time.Now(), time.Minute)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var event Event
+		require.NoError(t, json.Unmarshal(body, &event))
+		require.Equal(t, event.Type, r.Header.Get(HeaderEventType))
+		require.NotEmpty(t, r.Header.Get(HeaderDeliveryID))
+		events <- event
+
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("receiver says hi"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDispatcherRun(t *testing.T) {
+	secret := "whsec_test"
+	now := time.Now().Truncate(time.Second)
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}
+
+	delivery := db.WebhookDelivery{
+		ID:             7,
+		SubscriptionID: 3,
+		EventID:        42,
+		EventType:      db.EventBalanceChanged,
+		Payload:        json.RawMessage(`{"account_id":1,"amount":10}`),
+		Status:         db.WebhookDeliveryPending,
+		NextAttemptAt:  now,
+	}
+
+	testCases := []struct {
+		name            string
+		secret          string
+		status          int
+		attempts        int32
+		closed          bool
+		checkResponseFn func(t *testing.T, attempt db.WebhookAttempt, events []Event)
+	}{
+		{
+			name:   "OK",
+			secret: secret,
+			status: http.StatusNoContent,
+			checkResponseFn: func(t *testing.T, attempt db.WebhookAttempt, events []Event) {
+				require.Equal(t, db.WebhookDeliverySucceeded, attempt.Status)
+				require.Equal(t, int32(http.StatusNoContent), attempt.ResponseStatus)
+				require.Empty(t, attempt.Error)
+
+				require.Len(t, events, 1)
+				require.Equal(t, int64(42), events[0].ID)
+				require.Equal(t, db.EventBalanceChanged, events[0].Type)
+				require.JSONEq(t, string(delivery.Payload), string(events[0].Data))
+			},
+		},
+		{
+			name:     "Retried",
+			secret:   secret,
+			status:   http.StatusInternalServerError,
+			attempts: 1,
+			checkResponseFn: func(t *testing.T, attempt db.WebhookAttempt, events []Event) {
+				require.Equal(t, db.WebhookDeliveryPending, attempt.Status)
+				require.Equal(t, int32(http.StatusInternalServerError), attempt.ResponseStatus)
+				// the body of the response is not kept
+				require.Equal(t, "receiver responded with 500 Internal Server Error", attempt.Error)
+				// the second retry waits twice as long as the first one
+				require.Equal(t, now.Add(2*time.Minute), attempt.NextAttemptAt)
+			},
+		},
+		{
+			name:     "LastAttempt",
+			secret:   secret,
+			status:   http.StatusInternalServerError,
+			attempts: 2,
+			checkResponseFn: func(t *testing.T, attempt db.WebhookAttempt, events []Event) {
+				require.Equal(t, db.WebhookDeliveryFailed, attempt.Status)
+				require.Equal(t, int32(http.StatusInternalServerError), attempt.ResponseStatus)
+			},
+		},
+		{
+			name:   "WrongSecret",
+			secret: "whsec_other",
+			status: http.StatusOK,
+			checkResponseFn: func(t *testing.T, attempt db.WebhookAttempt, events []Event) {
+				require.Equal(t, db.WebhookDeliveryPending, attempt.Status)
+				require.Equal(t, int32(http.StatusUnauthorized), attempt.ResponseStatus)
+				require.Empty(t, events)
+			},
+		},
+		{
+			name:   "Unreachable",
+			secret: secret,
+			closed: true,
+			checkResponseFn: func(t *testing.T, attempt db.WebhookAttempt, events []Event) {
+				require.Equal(t, db.WebhookDeliveryPending, attempt.Status)
+				require.Zero(t, attempt.ResponseStatus)
+				require.NotEmpty(t, attempt.Error)
+				require.Equal(t, now.Add(time.Minute), attempt.NextAttemptAt)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			events := make(chan Event, 1)
+			receiver := newReceiver(t, secret, tc.status, events)
+			if tc.closed {
+				receiver.Close()
+			}
+
+			subscription := db.WebhookSubscription{ID: 3, Url: receiver.URL, Secret: tc.secret}
+			delivery := delivery
+			delivery.Attempts = tc.attempts
+
+			// stub
+			var attempt db.WebhookAttempt
+			store.EXPECT().
+				DeliverWebhooks(gomock.Any(), gomock.Eq(db.DeliverWebhooksParams{Now: now, Lease: time.Second + leaseMargin}), gomock.Any()).
+				Times(1).
+				DoAndReturn(func(ctx context.Context, arg db.DeliverWebhooksParams,
+					deliverFn func(ctx context.Context, delivery db.WebhookDelivery, subscription db.WebhookSubscription) db.WebhookAttempt,
+				) (int, error) {
+					attempt = deliverFn(ctx, delivery, subscription)
+					return 1, nil
+				})
+
+			// test
+			// the receiver listens on a loopback address, which the client of the dispatcher does not connect to
+			dispatcher := NewDispatcher(store, time.Second, WithRetryPolicy(policy), WithHTTPClient(receiver.Client()))
+			dispatcher.now = func() time.Time { return now }
+			attempts, err := dispatcher.Run(context.Background())
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, 1, attempts)
+
+			close(events)
+			var received []Event
+			for event := range events {
+				received = append(received, event)
+			}
+			tc.checkResponseFn(t, attempt, received)
+		})
+	}
+}
+
+func TestDispatcherForbiddenAddress(t *testing.T) {
+	events := make(chan Event, 1)
+	receiver := newReceiver(t, "whsec_test", http.StatusNoContent, events)
+
+	testCases := []struct {
+		name        string
+		url         string
+		expectedErr error
+	}{
+		{"Insecure", "http://partner.example.com/hooks", ErrInsecureURL},
+		{"Loopback", receiver.URL, ErrForbiddenAddress},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dispatcher := NewDispatcher(nil, time.Second)
+			dispatcher.now = time.Now
+
+			attempt := dispatcher.deliver(context.Background(), db.WebhookDelivery{ID: 7, EventID: 42}, db.WebhookSubscription{Url: tc.url})
+
+			require.Equal(t, db.WebhookDeliveryPending, attempt.Status)
+			require.Zero(t, attempt.ResponseStatus)
+			require.Contains(t, attempt.Error, tc.expectedErr.Error())
+		})
+	}
+
+	require.Empty(t, events)
+}
+
+func TestHTTPClientForbiddenAddress(t *testing.T) {
+	events := make(chan Event, 1)
+	receiver := newReceiver(t, "whsec_test", http.StatusNoContent, events)
+
+	// the host name resolves to the loopback address of the receiver
+	_, port, err := net.SplitHostPort(receiver.Listener.Addr().String())
+	require.NoError(t, err)
+	response, err := newHTTPClient(time.Second).Get("https://localhost:" + port)
+	if err == nil {
+		response.Body.Close()
+	}
+
+	require.ErrorIs(t, err, ErrForbiddenAddress)
+	require.Empty(t, events)
+}
+
+func TestHTTPClientRedirect(t *testing.T) {
+	request, err := http.NewRequest(http.MethodPost, "http://partner.example.com/hooks", nil)
+	require.NoError(t, err)
+
+	// the redirect response is returned as it is, and fails the attempt as any non-2xx response
+	err = newHTTPClient(time.Second).CheckRedirect(request, nil)
+	require.ErrorIs(t, err, http.ErrUseLastResponse)
+}
+
+func TestCheckURL(t *testing.T) {
+	testCases := []struct {
+		url         string
+		expectedErr error
+	}{
+		{"https://partner.example.com/hooks", nil},
+		{"https://203.0.113.10:8443/hooks", nil},
+		{"http://partner.example.com/hooks", ErrInsecureURL},
+		{"ftp://partner.example.com/hooks", ErrInsecureURL},
+		{"https:///hooks", ErrInsecureURL},
+		{"https://localhost/hooks", ErrForbiddenAddress},
+		{"https://api.LOCALHOST./hooks", ErrForbiddenAddress},
+		{"https://127.0.0.1/hooks", ErrForbiddenAddress},
+		{"https://[::1]/hooks", ErrForbiddenAddress},
+		{"https://0.0.0.0/hooks", ErrForbiddenAddress},
+		{"https://10.1.2.3/hooks", ErrForbiddenAddress},
+		{"https://172.16.0.1/hooks", ErrForbiddenAddress},
+		{"https://192.168.1.1/hooks", ErrForbiddenAddress},
+		{"https://169.254.169.254/latest/meta-data", ErrForbiddenAddress},
+		{"https://[fe80::1]/hooks", ErrForbiddenAddress},
+		{"https://[fd00::1]/hooks", ErrForbiddenAddress},
+		{"https://[::ffff:127.0.0.1]/hooks", ErrForbiddenAddress},
+		{"https://100.64.0.1/hooks", ErrForbiddenAddress},
+		{"https://100.127.255.254/hooks", ErrForbiddenAddress},
+		{"https://[64:ff9b::a00:1]/hooks", ErrForbiddenAddress},
+		{"https://[64:ff9b:1::a00:1]/hooks", ErrForbiddenAddress},
+	}
+
+	for _, tc := range testCases {
+		err := CheckURL(tc.url)
+		if tc.expectedErr == nil {
+			require.NoError(t, err, tc.url)
+			continue
+		}
+		require.ErrorIs(t, err, tc.expectedErr, tc.url)
+	}
+}
+
+func TestDispatcherRunError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	errDB := errors.New("db is down")
+	store.EXPECT().DeliverWebhooks(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(0, errDB)
+
+	_, err := NewDispatcher(store, time.Second).Run(context.Background())
+	require.ErrorIs(t, err, errDB)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Minute, MaxDelay: 5 * time.Minute}
+
+	var delays []time.Duration
+	for attempts := int32(1); attempts <= 5; attempts++ {
+		delays = append(delays, policy.backoff(attempts))
+	}
+	require.Equal(t, []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}, delays)
+}
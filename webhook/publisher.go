@@ -0,0 +1,29 @@
+package webhook
+
+import (
+	"context"
+
+	db "github.com/anilbolat/simple-bank/db/sqlc"
+	"github.com/anilbolat/simple-bank/outbox"
+)
+
+// Publisher is the outbox publisher enqueuing a delivery of every event for the webhook subscriptions to it.
+// The deliveries are sent by a Dispatcher.
+type Publisher struct {
+	store db.Store
+}
+
+var _ outbox.Publisher = (*Publisher)(nil)
+
+func NewPublisher(store db.Store) *Publisher {
+	return &Publisher{store: store}
+}
+
+func (publisher *Publisher) Publish(ctx context.Context, event db.OutboxEvent) error {
+	_, err := publisher.store.EnqueueWebhookDeliveries(ctx, event)
+	return err
+}
+
+func (publisher *Publisher) Close() error {
+	return nil
+}
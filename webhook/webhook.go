@@ -0,0 +1,86 @@
+// Package webhook delivers the domain events to the URLs the account owners subscribed to them,
+// signed with HMAC-SHA256, and retries failed deliveries with exponential backoff.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Headers of a webhook request.
+const (
+	// HeaderSignature is the HMAC-SHA256 of the timestamp and the body, like "sha256=5257a8...".
+	HeaderSignature = "Webhook-Signature"
+	// HeaderTimestamp is the time the request was signed at, in seconds since the epoch.
+	HeaderTimestamp = "Webhook-Timestamp"
+	// HeaderEventType is the type of the event delivered.
+	HeaderEventType = "Webhook-Event-Type"
+	// HeaderDeliveryID identifies the delivery, it is the same for every attempt of it.
+	HeaderDeliveryID = "Webhook-Delivery-Id"
+)
+
+const (
+	signaturePrefix = "sha256="
+	secretPrefix    = "whsec_"
+	// secretSize is the number of random bytes of a secret.
+	secretSize = 32
+)
+
+// ErrInvalidSignature is returned by Verify when a request was not signed with the secret, or too long ago.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Event is the body of a webhook request.
+type Event struct {
+	// ID is the ID of the event, the same in every delivery of it, so receivers can tell duplicates apart.
+	ID   int64           `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// NewSecret creates a random secret for signing the deliveries of a subscription.
+func NewSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return secretPrefix + hex.EncodeToString(secret), nil
+}
+
+// Sign returns the signature of a request with the body sent at timestamp.
+// The timestamp is signed with the body, so a captured request cannot be replayed later with a new timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and the timestamp headers of a request with the body, as a receiver would.
+// Requests signed more than tolerance before or after now are rejected.
+func Verify(secret, signature, timestamp string, body []byte, now time.Time, tolerance time.Duration) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	signedAt := time.Unix(seconds, 0)
+	if signedAt.Before(now.Add(-tolerance)) || signedAt.After(now.Add(tolerance)) {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, signedAt, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSecret(t *testing.T) {
+	secret1, err := NewSecret()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(secret1, secretPrefix))
+	require.Len(t, secret1, len(secretPrefix)+2*secretSize)
+
+	secret2, err := NewSecret()
+	require.NoError(t, err)
+	require.NotEqual(t, secret1, secret2)
+}
+
+func TestVerify(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"id":1,"type":"TransferCompleted","data":{}}`)
+	now := time.Now()
+	signedAt := now.Add(-time.Minute)
+	signature := Sign(secret, signedAt, body)
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+
+	require.True(t, strings.HasPrefix(signature, signaturePrefix))
+
+	testCases := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		body      []byte
+		valid     bool
+	}{
+		{name: "OK", secret: secret, signature: signature, timestamp: timestamp, body: body, valid: true},
+		{name: "WrongSecret", secret: "whsec_other", signature: signature, timestamp: timestamp, body: body},
+		{name: "TamperedBody", secret: secret, signature: signature, timestamp: timestamp, body: []byte(`{"id":2}`)},
+		{
+			name:      "OtherTimestamp",
+			secret:    secret,
+			signature: signature,
+			timestamp: strconv.FormatInt(now.Unix(), 10),
+			body:      body,
+		},
+		{
+			name:      "TooOld",
+			secret:    secret,
+			signature: Sign(secret, now.Add(-time.Hour), body),
+			timestamp: strconv.FormatInt(now.Add(-time.Hour).Unix(), 10),
+			body:      body,
+		},
+		{name: "InvalidTimestamp", secret: secret, signature: signature, timestamp: "yesterday", body: body},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify(tc.secret, tc.signature, tc.timestamp, tc.body, now, 5*time.Minute)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrInvalidSignature)
+			}
+		})
+	}
+}
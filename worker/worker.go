@@ -0,0 +1,31 @@
+// Package worker runs the background jobs of the app next to the server.
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job is a unit of background work run again and again by Run.
+type Job func(ctx context.Context) error
+
+// Run calls job every interval until ctx is done, the first time right away.
+// An error of the job is logged and does not stop it, the next tick runs it again.
+func Run(ctx context.Context, name string, interval time.Duration, job Job) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := job(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "background job failed", "job", name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Run(ctx, "test", time.Millisecond, func(ctx context.Context) error {
+			// errors do not stop the job
+			if runs.Add(1) == 3 {
+				cancel()
+			}
+			return errors.New("failed")
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after ctx was done")
+	}
+	require.Equal(t, int32(3), runs.Load())
+}
+
+func TestRunStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	runs := 0
+	Run(ctx, "test", time.Hour, func(ctx context.Context) error {
+		runs++
+		return nil
+	})
+
+	// the first run is right away, then Run returns instead of waiting for the next tick
+	require.Equal(t, 1, runs)
+}